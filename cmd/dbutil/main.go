@@ -0,0 +1,205 @@
+// Command dbutil provides database administration commands built on the dbutil package.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, dburl string, args []string) error
+}
+
+var commands = map[string]command{
+	"migrate":      {"migrate <dir>", runMigrate},
+	"create-db":    {"create-db <name>", runCreateDB},
+	"table-exists": {"table-exists <table>", runTableExists},
+	"copy-export":  {"copy-export <table>  (CSV with header to stdout)", runCopyExport},
+	"copy-import":  {"copy-import <table>  (CSV with header from stdin)", runCopyImport},
+	"truncate":     {"truncate [-cascade] [-restart-identity] <table>...", runTruncate},
+	"stats":        {"stats", runStats},
+}
+
+func main() {
+	flags := flag.NewFlagSet("dbutil", flag.ExitOnError)
+	dburl := flags.String("dburl", os.Getenv("TL_DATABASE_URL"), "database URL (default: $TL_DATABASE_URL)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: dbutil [-dburl url] <command> [args]\n\ncommands:\n")
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+		}
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *dburl == "" {
+		fmt.Fprintln(os.Stderr, "no database URL; set -dburl or TL_DATABASE_URL")
+		os.Exit(2)
+	}
+	if err := cmd.run(context.Background(), *dburl, flags.Args()[1:]); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+// exitCode is returned by commands to exit with a status code but no error message,
+// after deferred cleanup in the command has run.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
+func requireArgs(args []string, n int) error {
+	if len(args) < n {
+		return errors.New("missing arguments")
+	}
+	return nil
+}
+
+func runMigrate(ctx context.Context, dburl string, args []string) error {
+	if err := requireArgs(args, 1); err != nil {
+		return err
+	}
+	db, err := dbutil.OpenDB(dburl)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	applied, err := dbutil.Migrate(ctx, db, args[0])
+	for _, v := range applied {
+		fmt.Println(v)
+	}
+	return err
+}
+
+func runCreateDB(ctx context.Context, dburl string, args []string) error {
+	if err := requireArgs(args, 1); err != nil {
+		return err
+	}
+	db, err := dbutil.OpenDB(dburl)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = dbutil.Exec(dbutil.AllowDDL(ctx), db, dbutil.RawSQL("CREATE DATABASE "+dbutil.QuoteIdentifier(args[0])))
+	return err
+}
+
+func runTableExists(ctx context.Context, dburl string, args []string) error {
+	if err := requireArgs(args, 1); err != nil {
+		return err
+	}
+	db, err := dbutil.OpenDB(dburl)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	exists, err := dbutil.TableExists(ctx, db, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(exists)
+	if !exists {
+		return exitCode(1)
+	}
+	return nil
+}
+
+func runCopyExport(ctx context.Context, dburl string, args []string) error {
+	if err := requireArgs(args, 1); err != nil {
+		return err
+	}
+	pool, db, err := dbutil.OpenDBPool(ctx, dburl)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	defer db.Close()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Conn().PgConn().CopyTo(ctx, os.Stdout, fmt.Sprintf("COPY %s TO STDOUT WITH (FORMAT csv, HEADER)", dbutil.QuoteIdentifier(args[0])))
+	return err
+}
+
+func runCopyImport(ctx context.Context, dburl string, args []string) error {
+	if err := requireArgs(args, 1); err != nil {
+		return err
+	}
+	pool, db, err := dbutil.OpenDBPool(ctx, dburl)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	defer db.Close()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	tag, err := conn.Conn().PgConn().CopyFrom(ctx, os.Stdin, fmt.Sprintf("COPY %s FROM STDIN WITH (FORMAT csv, HEADER)", dbutil.QuoteIdentifier(args[0])))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported %d rows\n", tag.RowsAffected())
+	return nil
+}
+
+func runTruncate(ctx context.Context, dburl string, args []string) error {
+	flags := flag.NewFlagSet("truncate", flag.ExitOnError)
+	opts := dbutil.TruncateOptions{}
+	flags.BoolVar(&opts.Cascade, "cascade", false, "also truncate tables with foreign keys to these tables")
+	flags.BoolVar(&opts.RestartIdentity, "restart-identity", false, "reset sequences owned by these tables")
+	flags.Parse(args)
+	if err := requireArgs(flags.Args(), 1); err != nil {
+		return err
+	}
+	db, err := dbutil.OpenDB(dburl)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return dbutil.TruncateTables(dbutil.AllowDDL(ctx), db, opts, flags.Args()...)
+}
+
+func runStats(ctx context.Context, dburl string, args []string) error {
+	db, err := dbutil.OpenDB(dburl)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	stats, err := dbutil.GetTableStats(ctx, db)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
@@ -0,0 +1,128 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Transform replaces a column value in an anonymized dump. Values are decoded from JSON,
+// so numbers are json.Number and NULL is nil.
+type Transform func(value interface{}) interface{}
+
+// NullTransform replaces every value with NULL.
+func NullTransform(value interface{}) interface{} {
+	return nil
+}
+
+// HashTransform replaces values with a salted SHA-256 hash, so equal values still match
+// each other, e.g. for joins or uniqueness, without revealing the original. NULL is kept.
+func HashTransform(salt string) Transform {
+	return func(value interface{}) interface{} {
+		if value == nil {
+			return nil
+		}
+		h := sha256.Sum256([]byte(fmt.Sprintf("%s%v", salt, value)))
+		return hex.EncodeToString(h[:])
+	}
+}
+
+// FakeTransform replaces each distinct value with format applied to a sequence number,
+// e.g. "operator-%d@example.com". Equal values get the same substitute. NULL is kept.
+func FakeTransform(format string) Transform {
+	m := NewIDMap()
+	return func(value interface{}) interface{} {
+		if value == nil {
+			return nil
+		}
+		return fmt.Sprintf(format, m.Map(value))
+	}
+}
+
+// IDMap assigns sequential ids, starting at 1, to distinct values in the order they are seen.
+// Use the same IDMap for a table's id column and the columns that reference it,
+// so references still point to the right rows in an anonymized dump.
+type IDMap struct {
+	ids  map[string]int64
+	lock sync.Mutex
+}
+
+func NewIDMap() *IDMap {
+	return &IDMap{ids: map[string]int64{}}
+}
+
+// Map returns the id assigned to value.
+func (m *IDMap) Map(value interface{}) int64 {
+	key := fmt.Sprintf("%v", value)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id, ok := m.ids[key]
+	if !ok {
+		id = int64(len(m.ids) + 1)
+		m.ids[key] = id
+	}
+	return id
+}
+
+// Transform returns a Transform that replaces values with their mapped ids. NULL is kept.
+func (m *IDMap) Transform() Transform {
+	return func(value interface{}) interface{} {
+		if value == nil {
+			return nil
+		}
+		return m.Map(value)
+	}
+}
+
+// Anonymizer writes dumps with column values transformed, e.g. to create test fixtures
+// from production data without copying contact details.
+type Anonymizer struct {
+	rules map[string]map[string]Transform
+}
+
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{rules: map[string]map[string]Transform{}}
+}
+
+// Column sets the Transform for a column of table.
+func (a *Anonymizer) Column(table string, column string, t Transform) *Anonymizer {
+	if a.rules[table] == nil {
+		a.rules[table] = map[string]Transform{}
+	}
+	a.rules[table][column] = t
+	return a
+}
+
+// DumpQuery is DumpQuery with the Transforms for table applied to each row.
+func (a *Anonymizer) DumpQuery(ctx context.Context, db sqlx.Ext, table string, q sq.SelectBuilder, w io.Writer) (int, error) {
+	rules := a.rules[table]
+	if len(rules) == 0 {
+		return DumpQuery(ctx, db, table, q, w)
+	}
+	return dumpQuery(ctx, db, table, q, w, func(row json.RawMessage) (json.RawMessage, error) {
+		return a.transformRow(rules, row)
+	})
+}
+
+func (a *Anonymizer) transformRow(rules map[string]Transform, row json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(row))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+	for col, t := range rules {
+		if v, ok := values[col]; ok {
+			values[col] = t(v)
+		}
+	}
+	return json.Marshal(values)
+}
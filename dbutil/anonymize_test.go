@@ -0,0 +1,31 @@
+package dbutil
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymizer(t *testing.T) {
+	ids := NewIDMap()
+	a := NewAnonymizer().
+		Column("agencies", "id", ids.Transform()).
+		Column("agencies", "agency_email", FakeTransform("agency-%d@example.com")).
+		Column("agencies", "agency_phone", NullTransform).
+		Column("agencies", "agency_name", HashTransform("salt"))
+	row := json.RawMessage(`{"id":1001,"agency_name":"Transit","agency_email":"ops@transit.example","agency_phone":"555-0100","agency_url":"https://transit.example"}`)
+	out, err := a.transformRow(a.rules["agencies"], row)
+	assert.NoError(t, err)
+	var values map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out, &values))
+	assert.Equal(t, float64(1), values["id"])
+	assert.Equal(t, "agency-1@example.com", values["agency_email"])
+	assert.Nil(t, values["agency_phone"])
+	assert.Equal(t, HashTransform("salt")("Transit"), values["agency_name"])
+	assert.Equal(t, "https://transit.example", values["agency_url"])
+
+	// References map to the same ids
+	assert.Equal(t, int64(1), ids.Transform()(json.Number("1001")))
+	assert.Equal(t, int64(2), ids.Transform()(json.Number("1002")))
+}
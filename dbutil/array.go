@@ -0,0 +1,50 @@
+package dbutil
+
+import (
+	"database/sql/driver"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// arrayTypeMap is shared by Array scans; pgtype.Map caches scan plans and is not safe for concurrent use.
+var (
+	arrayTypeMap     = pgtype.NewMap()
+	arrayTypeMapLock sync.Mutex
+)
+
+// Array scans a Postgres array column, e.g. from array_agg(...), into a slice of T.
+// NULL scans as Valid=false with a nil slice; an empty array scans as Valid=true with an empty, non-nil slice.
+// Note that array_agg over zero rows returns NULL, not an empty array.
+type Array[T any] struct {
+	Val   []T
+	Valid bool
+}
+
+// Scan implements sql.Scanner.
+func (r *Array[T]) Scan(src interface{}) error {
+	r.Val, r.Valid = nil, false
+	if src == nil {
+		return nil
+	}
+	var val []T
+	arrayTypeMapLock.Lock()
+	err := arrayTypeMap.SQLScanner(&val).Scan(src)
+	arrayTypeMapLock.Unlock()
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		val = []T{}
+	}
+	r.Val, r.Valid = val, true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (r Array[T]) Value() (driver.Value, error) {
+	if !r.Valid {
+		return nil, nil
+	}
+	return r.Val, nil
+}
@@ -0,0 +1,36 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArray_Scan(t *testing.T) {
+	tcs := []struct {
+		src   interface{}
+		valid bool
+		val   []int64
+	}{
+		{nil, false, nil},
+		{"{}", true, []int64{}},
+		{"{1,2,3}", true, []int64{1, 2, 3}},
+		{[]byte("{4}"), true, []int64{4}},
+	}
+	for _, tc := range tcs {
+		var v Array[int64]
+		if err := v.Scan(tc.src); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.valid, v.Valid)
+		assert.Equal(t, tc.val, v.Val)
+	}
+	var s Array[string]
+	if err := s.Scan(`{a,"b c",NULL}`); err == nil {
+		t.Error("expected error scanning NULL element into string")
+	}
+	if err := s.Scan(`{a,"b c"}`); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"a", "b c"}, s.Val)
+}
@@ -0,0 +1,88 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// BackfillProgress reports the state of a running Backfill.
+type BackfillProgress struct {
+	Updated int64
+	LastID  int64
+	MaxID   int64
+	Elapsed time.Duration
+	// ETA is estimated from the fraction of the id range processed.
+	ETA time.Duration
+}
+
+// Backfill runs UPDATE table SET setExpr on rows matching where (which may be nil) in batches of
+// batchSize rows ordered by id, sleeping between batches, so large tables are not locked and
+// WAL is not generated in one shot. Each batch commits independently unless db is a transaction.
+// If progress is not nil it is called after each batch. Progress is also reported to any WithProgress hook,
+// in ids of the table's id range. Returns the total number of rows updated.
+func Backfill(ctx context.Context, db sqlx.Ext, table string, setExpr string, where sq.Sqlizer, batchSize int, sleep time.Duration, progress func(BackfillProgress)) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("backfill batch size must be greater than 0, got %d", batchSize)
+	}
+	t := QuoteIdentifier(table)
+	var bounds struct {
+		MinID int64 `db:"min_id"`
+		MaxID int64 `db:"max_id"`
+	}
+	if err := Get(ctx, db, sq.Select("coalesce(min(id), 0) AS min_id", "coalesce(max(id), 0) AS max_id").From(t), &bounds); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	lastID := bounds.MinID - 1
+	total := int64(0)
+	for {
+		// Find the last id of the next batch with a read, then update the id range with Exec,
+		// so the update is classified, timed out, and dry run as a write
+		batch := sq.Select("id").From(t).Where("id > ?", lastID).OrderBy("id").Limit(uint64(batchSize))
+		if where != nil {
+			batch = batch.Where(where)
+		}
+		var upper *int64
+		if err := Get(ctx, db, sq.Select("max(id)").FromSelect(batch, "batch"), &upper); err != nil {
+			return total, err
+		}
+		if upper == nil {
+			return total, nil
+		}
+		cond := sq.And{sq.Expr("id > ?", lastID), sq.Expr("id <= ?", *upper)}
+		if where != nil {
+			cond = append(cond, where)
+		}
+		condSql, condArgs, err := cond.ToSql()
+		if err != nil {
+			return total, err
+		}
+		n, err := execRowsAffected(ctx, db, sq.Expr(fmt.Sprintf("UPDATE %s SET %s WHERE %s", t, setExpr, condSql), condArgs...))
+		if err != nil {
+			return total, err
+		}
+		total += n
+		lastID = *upper
+		reportProgress(ctx, int(lastID-bounds.MinID+1), int(bounds.MaxID-bounds.MinID+1))
+		if progress != nil {
+			p := BackfillProgress{Updated: total, LastID: lastID, MaxID: bounds.MaxID, Elapsed: time.Since(start)}
+			if span := bounds.MaxID - bounds.MinID + 1; span > 0 {
+				if f := float64(lastID-bounds.MinID+1) / float64(span); f > 0 && f < 1 {
+					p.ETA = time.Duration(float64(p.Elapsed) * (1 - f) / f)
+				}
+			}
+			progress(p)
+		}
+		if sleep > 0 {
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(sleep):
+			}
+		}
+	}
+}
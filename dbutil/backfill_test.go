@@ -0,0 +1,56 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfillProgress(t *testing.T) {
+	// Answer the bounds query, then two batches of updates
+	uppers := []int64{5, 10}
+	var updates []string
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			switch dest := qi.Dest.(type) {
+			case *struct {
+				MinID int64 `db:"min_id"`
+				MaxID int64 `db:"max_id"`
+			}:
+				dest.MinID, dest.MaxID = 1, 10
+			case **int64:
+				if len(uppers) > 0 {
+					*dest, uppers = &uppers[0], uppers[1:]
+				}
+			case nil:
+				updates = append(updates, qi.Query)
+				qi.Result = driver.RowsAffected(5)
+			default:
+				t.Fatalf("unexpected query: %s", qi.Query)
+			}
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	var calls [][2]int
+	ctx := WithProgress(context.Background(), func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	})
+	var updated []int64
+	n, err := Backfill(ctx, nil, "gtfs_stops", "stop_name = upper(stop_name)", nil, 5, 0, func(p BackfillProgress) {
+		updated = append(updated, p.Updated)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, []int64{5, 10}, updated)
+	assert.Equal(t, [][2]int{{5, 10}, {10, 10}}, calls)
+	assert.Equal(t, []string{
+		`UPDATE "gtfs_stops" SET stop_name = upper(stop_name) WHERE (id > $1 AND id <= $2)`,
+		`UPDATE "gtfs_stops" SET stop_name = upper(stop_name) WHERE (id > $1 AND id <= $2)`,
+	}, updates)
+
+	_, err = Backfill(ctx, nil, "gtfs_stops", "stop_name = upper(stop_name)", nil, 0, 0, nil)
+	assert.Error(t, err)
+}
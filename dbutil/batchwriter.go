@@ -0,0 +1,219 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrWriterClosed is returned when writing to a closed BatchWriter.
+var ErrWriterClosed = errors.New("batch writer is closed")
+
+// BatchWriter buffers items and writes them in batches in a background goroutine,
+// when maxBatch items are buffered or wait has passed since the first buffered item.
+// Write blocks while maxBatch items are already queued, so slow writes apply backpressure.
+// Write errors are logged, and the first error since the last Flush or Close is returned by it.
+type BatchWriter[T any] struct {
+	maxBatch int
+	wait     time.Duration
+	write    func(context.Context, []T) error
+	items    chan T
+	flushes  chan chan error
+	stop     chan struct{}
+	stopped  chan struct{}
+	closed   bool
+	err      error
+	lock     sync.RWMutex
+	errLock  sync.Mutex
+}
+
+// NewBatchWriter returns a BatchWriter that passes batches to write. Call Start before writing.
+func NewBatchWriter[T any](maxBatch int, wait time.Duration, write func(context.Context, []T) error) *BatchWriter[T] {
+	if maxBatch <= 0 {
+		maxBatch = 1000
+	}
+	return &BatchWriter[T]{
+		maxBatch: maxBatch,
+		wait:     wait,
+		write:    write,
+		items:    make(chan T, maxBatch),
+		flushes:  make(chan chan error),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// NewInsertWriter returns a BatchWriter that inserts entity structs of T into table
+// with multi-row inserts. If no columns are given, all mapped columns are inserted.
+// Returns an error if there are no columns to insert.
+func NewInsertWriter[T any](db sqlx.Ext, table string, maxBatch int, wait time.Duration, cols ...string) (*BatchWriter[T], error) {
+	if len(cols) == 0 {
+		for _, fi := range entColumns(reflect.TypeOf((*T)(nil)).Elem()) {
+			cols = append(cols, fi.Name)
+		}
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%T has no mapped columns to insert", *new(T))
+	}
+	if len(cols) > MaxBindParams {
+		return nil, fmt.Errorf("%d insert columns exceeds the bind parameter limit of %d", len(cols), MaxBindParams)
+	}
+	return NewBatchWriter(maxBatch, wait, func(ctx context.Context, ents []T) error {
+		var items []interface{}
+		for _, ent := range ents {
+			items = append(items, ent)
+		}
+		for _, chunk := range Chunks(items, MaxBindParams/len(cols)) {
+			q := sq.Insert(QuoteIdentifier(table)).Columns(cols...).PlaceholderFormat(sq.Dollar)
+			for _, ent := range chunk {
+				vals := entValues(ent)
+				var row []interface{}
+				for _, col := range cols {
+					row = append(row, vals[col])
+				}
+				q = q.Values(row...)
+			}
+			if _, err := Exec(ctx, db, q); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// Start writes batches until Close is called or the context is done.
+func (w *BatchWriter[T]) Start(ctx context.Context) {
+	go w.run(ctx)
+}
+
+// Write queues an item to be written.
+func (w *BatchWriter[T]) Write(ctx context.Context, item T) error {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	select {
+	case w.items <- item:
+		return nil
+	case <-w.stopped:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush writes all queued items and returns the first write error since the last Flush.
+func (w *BatchWriter[T]) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case w.flushes <- reply:
+	case <-w.stopped:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting items, writes all queued items, and waits for the writer to stop.
+// Returns the first write error since the last Flush.
+func (w *BatchWriter[T]) Close(ctx context.Context) error {
+	w.lock.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	w.lock.Unlock()
+	select {
+	case <-w.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return w.takeErr()
+}
+
+func (w *BatchWriter[T]) run(ctx context.Context) {
+	defer close(w.stopped)
+	var buf []T
+	timer := time.NewTimer(w.wait)
+	timer.Stop()
+	defer timer.Stop()
+	flush := func() {
+		timer.Stop()
+		if len(buf) == 0 {
+			return
+		}
+		if err := w.write(ctx, buf); err != nil {
+			logger(ctx).Error().Err(err).Int("count", len(buf)).Msg("batch writer: could not write batch")
+			w.setErr(err)
+		}
+		buf = nil
+	}
+	add := func(item T) {
+		if len(buf) == 0 && w.wait > 0 {
+			timer.Reset(w.wait)
+		}
+		buf = append(buf, item)
+		if len(buf) >= w.maxBatch {
+			flush()
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case item := <-w.items:
+				add(item)
+			default:
+				flush()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case item := <-w.items:
+			add(item)
+		case <-timer.C:
+			flush()
+		case reply := <-w.flushes:
+			drain()
+			reply <- w.takeErr()
+		case <-w.stop:
+			drain()
+			return
+		case <-ctx.Done():
+			if len(buf) > 0 || len(w.items) > 0 {
+				w.setErr(ctx.Err())
+			}
+			return
+		}
+	}
+}
+
+func (w *BatchWriter[T]) setErr(err error) {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *BatchWriter[T]) takeErr() error {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
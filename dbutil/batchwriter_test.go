@@ -0,0 +1,96 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBatches struct {
+	batches [][]int
+	lock    sync.Mutex
+}
+
+func (b *testBatches) write(ctx context.Context, items []int) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.batches = append(b.batches, append([]int(nil), items...))
+	return nil
+}
+
+func (b *testBatches) get() [][]int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.batches
+}
+
+func TestBatchWriter(t *testing.T) {
+	ctx := context.Background()
+	t.Run("batch size", func(t *testing.T) {
+		b := &testBatches{}
+		w := NewBatchWriter(2, 0, b.write)
+		w.Start(ctx)
+		for i := 1; i <= 5; i++ {
+			assert.NoError(t, w.Write(ctx, i))
+		}
+		assert.NoError(t, w.Close(ctx))
+		assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, b.get())
+		assert.ErrorIs(t, w.Write(ctx, 6), ErrWriterClosed)
+	})
+	t.Run("flush", func(t *testing.T) {
+		b := &testBatches{}
+		w := NewBatchWriter(100, 0, b.write)
+		w.Start(ctx)
+		assert.NoError(t, w.Write(ctx, 1))
+		assert.NoError(t, w.Flush(ctx))
+		assert.Equal(t, [][]int{{1}}, b.get())
+		assert.NoError(t, w.Close(ctx))
+	})
+	t.Run("wait", func(t *testing.T) {
+		b := &testBatches{}
+		w := NewBatchWriter(100, 10*time.Millisecond, b.write)
+		w.Start(ctx)
+		assert.NoError(t, w.Write(ctx, 1))
+		assert.Eventually(t, func() bool { return len(b.get()) == 1 }, time.Second, time.Millisecond)
+		assert.NoError(t, w.Close(ctx))
+	})
+	t.Run("error", func(t *testing.T) {
+		errWrite := errors.New("write failed")
+		w := NewBatchWriter(1, 0, func(ctx context.Context, items []int) error { return errWrite })
+		w.Start(ctx)
+		assert.NoError(t, w.Write(ctx, 1))
+		assert.ErrorIs(t, w.Flush(ctx), errWrite)
+		assert.NoError(t, w.Flush(ctx))
+		assert.NoError(t, w.Close(ctx))
+	})
+}
+
+func TestNewInsertWriter(t *testing.T) {
+	type stop struct {
+		ID       int
+		StopName string
+	}
+	var queries []string
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			queries = append(queries, qi.Query)
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := context.Background()
+	w, err := NewInsertWriter[stop](nil, "gtfs_stops", 10, 0)
+	if assert.NoError(t, err) {
+		w.Start(ctx)
+		assert.NoError(t, w.Write(ctx, stop{ID: 1, StopName: "a"}))
+		assert.NoError(t, w.Close(ctx))
+		assert.Equal(t, []string{`INSERT INTO "gtfs_stops" (id,stop_name) VALUES ($1,$2)`}, queries)
+	}
+
+	_, err = NewInsertWriter[struct{}](nil, "gtfs_stops", 10, 0)
+	assert.Error(t, err)
+}
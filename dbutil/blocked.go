@@ -0,0 +1,64 @@
+package dbutil
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// BlockedQuery is a query waiting on a lock held by another backend.
+type BlockedQuery struct {
+	PID             int           `json:"pid"`
+	Query           string        `json:"query"`
+	WaitDuration    time.Duration `json:"wait_duration"`
+	BlockingPID     int           `json:"blocking_pid"`
+	BlockingQuery   string        `json:"blocking_query"`
+	BlockingState   string        `json:"blocking_state"`
+	BlockingXactAge time.Duration `json:"blocking_xact_age"`
+}
+
+// BlockedQueries returns each query that is waiting on a lock, paired with each backend blocking it,
+// longest waiting first. A blocking backend that is "idle in transaction" usually points at
+// an application transaction that was left open.
+func BlockedQueries(ctx context.Context, db sqlx.Ext) ([]BlockedQuery, error) {
+	type row struct {
+		PID                 int
+		Query               string
+		WaitSeconds         float64
+		BlockingPID         int
+		BlockingQuery       string
+		BlockingState       string
+		BlockingXactSeconds float64
+	}
+	q := sq.Select(
+		"blocked.pid",
+		"blocked.query",
+		"coalesce(extract(epoch FROM now() - blocked.query_start), 0) AS wait_seconds",
+		"blocking.pid AS blocking_pid",
+		"blocking.query AS blocking_query",
+		"coalesce(blocking.state, '') AS blocking_state",
+		"coalesce(extract(epoch FROM now() - blocking.xact_start), 0) AS blocking_xact_seconds",
+	).
+		From("pg_stat_activity blocked").
+		Join("pg_stat_activity blocking ON blocking.pid = ANY(pg_blocking_pids(blocked.pid))").
+		OrderBy("blocked.query_start", "blocked.pid", "blocking.pid")
+	var rows []row
+	if err := Select(ctx, db, q, &rows); err != nil {
+		return nil, err
+	}
+	var ret []BlockedQuery
+	for _, r := range rows {
+		ret = append(ret, BlockedQuery{
+			PID:             r.PID,
+			Query:           r.Query,
+			WaitDuration:    time.Duration(r.WaitSeconds * float64(time.Second)),
+			BlockingPID:     r.BlockingPID,
+			BlockingQuery:   r.BlockingQuery,
+			BlockingState:   r.BlockingState,
+			BlockingXactAge: time.Duration(r.BlockingXactSeconds * float64(time.Second)),
+		})
+	}
+	return ret, nil
+}
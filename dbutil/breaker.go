@@ -0,0 +1,156 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrCircuitOpen is returned without running the query while a CircuitBreaker is open.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreaker fails fast during database outages.
+// After Threshold consecutive connection or timeout errors, the circuit opens and
+// calls return ErrCircuitOpen for Cooldown. After the cooldown, a single call is
+// let through as a probe: if it succeeds the circuit closes, otherwise it opens again.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lock      sync.Mutex
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, state: CircuitClosed}
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.Cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Do calls fn unless the circuit is open, and records whether it failed with an outage error.
+// Calls that end because ctx is done, or that panic, are not recorded as successes or failures.
+func (b *CircuitBreaker) Do(ctx context.Context, fn func(context.Context) error) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	recorded := false
+	defer func() {
+		if !recorded {
+			b.abort()
+		}
+	}()
+	err := fn(ctx)
+	recorded = true
+	if ctx.Err() != nil {
+		// The caller gave up, which says nothing about the database
+		b.abort()
+	} else {
+		b.record(ctx, err)
+	}
+	return err
+}
+
+// abort ends a call without recording a result, so a probe can be retried.
+func (b *CircuitBreaker) abort() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == CircuitClosed {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.Cooldown {
+		return false
+	}
+	b.state = CircuitHalfOpen
+	b.probing = true
+	return true
+}
+
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	wasProbe := b.probing
+	b.probing = false
+	if !isOutageError(err) {
+		if b.state != CircuitClosed {
+			logger(ctx).Info().Msg("database circuit breaker closed")
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if wasProbe || (b.state == CircuitClosed && b.failures >= b.Threshold) {
+		logger(ctx).Error().Err(err).Int("failures", b.failures).Msg("database circuit breaker opened")
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// isOutageError returns true if err indicates the database is unreachable or overloaded,
+// as opposed to an error in the query itself. Deadline errors count, since Do only records
+// them while the caller's context is live, i.e. when a statement timeout expired.
+func isOutageError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	return IsTransient(err) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &pgErr) && pgErr.Code == "57014") // query_canceled, e.g. statement_timeout
+}
+
+// Select is Select guarded by the circuit breaker.
+func (b *CircuitBreaker) Select(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	return b.Do(ctx, func(ctx context.Context) error {
+		return Select(ctx, db, q, dest)
+	})
+}
+
+// Get is Get guarded by the circuit breaker.
+func (b *CircuitBreaker) Get(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	return b.Do(ctx, func(ctx context.Context) error {
+		return Get(ctx, db, q, dest)
+	})
+}
+
+// Exec is Exec guarded by the circuit breaker.
+func (b *CircuitBreaker) Exec(ctx context.Context, db sqlx.Ext, q sq.Sqlizer) (sql.Result, error) {
+	var res sql.Result
+	err := b.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = Exec(ctx, db, q)
+		return err
+	})
+	return res, err
+}
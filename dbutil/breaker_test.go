@@ -0,0 +1,61 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	b := NewCircuitBreaker(2, 10*time.Millisecond)
+	fail := func(context.Context) error { return driver.ErrBadConn }
+	ok := func(context.Context) error { return nil }
+
+	// Query errors do not trip the circuit
+	b.Do(ctx, func(context.Context) error { return errors.New("syntax error") })
+	b.Do(ctx, func(context.Context) error { return errors.New("syntax error") })
+	assert.Equal(t, CircuitClosed, b.State())
+
+	b.Do(ctx, fail)
+	assert.Equal(t, CircuitClosed, b.State())
+	b.Do(ctx, fail)
+	assert.Equal(t, CircuitOpen, b.State())
+	assert.ErrorIs(t, b.Do(ctx, ok), ErrCircuitOpen)
+
+	// Failed probe reopens the circuit
+	time.Sleep(15 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	assert.ErrorIs(t, b.Do(ctx, fail), driver.ErrBadConn)
+	assert.Equal(t, CircuitOpen, b.State())
+
+	// Successful probe closes the circuit
+	time.Sleep(15 * time.Millisecond)
+	assert.NoError(t, b.Do(ctx, ok))
+	assert.Equal(t, CircuitClosed, b.State())
+}
+
+func TestCircuitBreakerAbort(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Millisecond)
+	b.Do(context.Background(), func(context.Context) error { return driver.ErrBadConn })
+	assert.Equal(t, CircuitOpen, b.State())
+	time.Sleep(5 * time.Millisecond)
+
+	// A panicking probe does not leave the circuit open forever
+	assert.Panics(t, func() {
+		b.Do(context.Background(), func(context.Context) error { panic("probe failed") })
+	})
+	assert.NoError(t, b.Do(context.Background(), func(context.Context) error { return nil }))
+	assert.Equal(t, CircuitClosed, b.State())
+
+	// The caller's own deadline is not an outage
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.ErrorIs(t, b.Do(ctx, func(ctx context.Context) error { return ctx.Err() }), context.DeadlineExceeded)
+	assert.Equal(t, CircuitClosed, b.State())
+}
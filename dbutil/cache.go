@@ -0,0 +1,234 @@
+package dbutil
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/go-redis/redis/v8"
+	"github.com/jmoiron/sqlx"
+)
+
+// CacheStore stores encoded query results.
+type CacheStore interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// QueryCache caches Select and Get results by normalized SQL and arguments, including any
+// soft delete filter, the WithSchema schema, and the dest type. Results are stored as JSON, so dest types must round-trip through
+// encoding/json. A Store shared by caches for different databases, e.g. Redis, needs a distinct
+// Database for each, since queries are otherwise identical.
+type QueryCache struct {
+	Store    CacheStore
+	TTL      time.Duration
+	Database string
+}
+
+func NewQueryCache(store CacheStore, ttl time.Duration) *QueryCache {
+	return &QueryCache{Store: store, TTL: ttl}
+}
+
+// Select is a cached Select.
+func (c *QueryCache) Select(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	return c.cached(ctx, q, dest, func() error { return Select(ctx, db, q, dest) })
+}
+
+// Get is a cached Get.
+func (c *QueryCache) Get(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	return c.cached(ctx, q, dest, func() error { return Get(ctx, db, q, dest) })
+}
+
+func (c *QueryCache) cached(ctx context.Context, q sq.SelectBuilder, dest interface{}, fn func() error) error {
+	key, err := queryCacheKey(ctx, c.Database, q, dest)
+	if err != nil {
+		return err
+	}
+	if b, ok, err := c.Store.Get(ctx, key); err != nil {
+		logger(ctx).Error().Err(err).Msg("query cache: get failed")
+	} else if ok {
+		return json.Unmarshal(b, dest)
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	if b, err := json.Marshal(dest); err != nil {
+		logger(ctx).Error().Err(err).Msg("query cache: could not encode result")
+	} else if err := c.Store.Set(ctx, key, b, c.TTL); err != nil {
+		logger(ctx).Error().Err(err).Msg("query cache: set failed")
+	}
+	return nil
+}
+
+func queryCacheKey(ctx context.Context, database string, q sq.SelectBuilder, dest interface{}) (string, error) {
+	// Key on the query as Select and Get will run it, so Unscoped reads are cached separately
+	qstr, qargs, err := scopeDeleted(ctx, q, dest).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(qargs)
+	if err != nil {
+		return "", err
+	}
+	schema, _ := SchemaFromContext(ctx)
+	h := sha256.New()
+	// Separate fields with NUL so they can not run together
+	fmt.Fprintf(h, "%s\x00%s\x00%T\x00", database, schema, dest)
+	h.Write([]byte(strings.Join(strings.Fields(qstr), " ")))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// MemoryCache is an in-memory least-recently-used CacheStore.
+type MemoryCache struct {
+	size  int
+	items map[string]*list.Element
+	order *list.List
+	lock  sync.Mutex
+}
+
+type memoryCacheItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		size:  size,
+		items: map[string]*list.Element{},
+		order: list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := el.Value.(*memoryCacheItem)
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return item.value, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	item := &memoryCacheItem{key: key, value: value}
+	if ttl > 0 {
+		item.expires = time.Now().Add(ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value = item
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(item)
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*memoryCacheItem).key)
+	}
+	return nil
+}
+
+// RedisCache is a CacheStore backed by Redis.
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisCache(client *redis.Client, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.prefix+key, value, ttl).Err()
+}
+
+// DBCache is a CacheStore backed by a database table, for deployments without Redis.
+// Expired entries are ignored, and removed by Sweep or a sweeper started with StartSweeper.
+// Expected schema:
+//
+//	CREATE UNLOGGED TABLE dbutil_cache (
+//		key text PRIMARY KEY,
+//		value bytea NOT NULL,
+//		expires_at timestamptz
+//	);
+type DBCache struct {
+	db    sqlx.Ext
+	table string
+}
+
+func NewDBCache(db sqlx.Ext, table string) *DBCache {
+	return &DBCache{db: db, table: table}
+}
+
+func (c *DBCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var values [][]byte
+	q := sq.Select("value").From(QuoteIdentifier(c.table)).Where("key = ?", key).Where(notExpired)
+	if err := Select(ctx, c.db, q, &values); err != nil || len(values) == 0 {
+		return nil, false, err
+	}
+	return values[0], true, nil
+}
+
+func (c *DBCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	q := sq.Insert(QuoteIdentifier(c.table)).
+		Columns("key", "value", "expires_at").
+		Values(key, value, ttlExpiresAt(ttl)).
+		Suffix("ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at")
+	_, err := Exec(ctx, c.db, q)
+	return err
+}
+
+// Sweep removes expired entries and returns the number removed.
+func (c *DBCache) Sweep(ctx context.Context) (int64, error) {
+	return execRowsAffected(ctx, c.db, sq.Delete(QuoteIdentifier(c.table)).Where("expires_at <= now()"))
+}
+
+// StartSweeper removes expired entries every interval until the context is done.
+func (c *DBCache) StartSweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			n, err := c.Sweep(ctx)
+			if err != nil {
+				logger(ctx).Error().Err(err).Str("table", c.table).Msg("db cache: could not sweep expired entries")
+			} else if n > 0 {
+				logger(ctx).Trace().Int64("count", n).Str("table", c.table).Msg("db cache: swept expired entries")
+			}
+		}
+	}()
+}
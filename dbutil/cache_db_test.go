@@ -0,0 +1,71 @@
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBCacheDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	// Statements run outside a transaction, so now() advances for TTL expiry
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS dbutil_cache_test CASCADE",
+		"CREATE SCHEMA dbutil_cache_test",
+		`CREATE UNLOGGED TABLE dbutil_cache_test."Cache" (key text PRIMARY KEY, value bytea NOT NULL, expires_at timestamptz)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.ExecContext(ctx, "DROP SCHEMA dbutil_cache_test CASCADE")
+	c := dbutil.NewDBCache(db, "dbutil_cache_test.Cache")
+
+	t.Run("ttl", func(t *testing.T) {
+		assert.NoError(t, c.Set(ctx, "short", []byte("1"), 50*time.Millisecond))
+		assert.NoError(t, c.Set(ctx, "forever", []byte("2"), 0))
+		v, ok, err := c.Get(ctx, "short")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), v)
+		time.Sleep(100 * time.Millisecond)
+		_, ok, err = c.Get(ctx, "short")
+		assert.NoError(t, err)
+		assert.False(t, ok, "expired entry should not be returned")
+		n, err := c.Sweep(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		_, ok, _ = c.Get(ctx, "forever")
+		assert.True(t, ok)
+		// Setting an existing key replaces its value and expiry
+		assert.NoError(t, c.Set(ctx, "forever", []byte("3"), time.Hour))
+		v, _, _ = c.Get(ctx, "forever")
+		assert.Equal(t, []byte("3"), v)
+	})
+
+	t.Run("query cache", func(t *testing.T) {
+		qc := dbutil.NewQueryCache(c, time.Hour)
+		q := sq.Select("i").From("generate_series(1, 3) i").OrderBy("i")
+		for i := 0; i < 2; i++ {
+			var rows []int
+			assert.NoError(t, qc.Select(ctx, db, q, &rows))
+			assert.Equal(t, []int{1, 2, 3}, rows)
+		}
+		var n int
+		if err := db.GetContext(ctx, &n, `SELECT count(*) FROM dbutil_cache_test."Cache" WHERE key NOT IN ('forever')`); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, n)
+	})
+}
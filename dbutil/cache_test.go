@@ -0,0 +1,87 @@
+package dbutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0)
+	_, ok, _ := c.Get(ctx, "b")
+	assert.False(t, ok, "least recently used key should be evicted")
+	v, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+	c.Set(ctx, "d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	_, ok, _ = c.Get(ctx, "d")
+	assert.False(t, ok, "expired key should not be returned")
+}
+
+func Test_queryCacheKey(t *testing.T) {
+	ctx := context.Background()
+	q := sq.Select("*").From("routes").Where("id = ?", 1)
+	var rows []int
+	var row int
+	k1, _ := queryCacheKey(ctx, "", q, &rows)
+	k2, _ := queryCacheKey(ctx, "", sq.Select("*").From("routes").Where("id = ?", 1), &rows)
+	k3, _ := queryCacheKey(ctx, "", sq.Select("*").From("routes").Where("id = ?", 2), &rows)
+	assert.Equal(t, k1, k2)
+	assert.NotEqual(t, k1, k3)
+	k4, _ := queryCacheKey(WithSchema(ctx, "tenant_1"), "", q, &rows)
+	k5, _ := queryCacheKey(WithSchema(ctx, "tenant_2"), "", q, &rows)
+	assert.NotEqual(t, k1, k4, "schema should be part of the key")
+	assert.NotEqual(t, k4, k5, "schema should be part of the key")
+	k6, _ := queryCacheKey(ctx, "", q, &row)
+	assert.NotEqual(t, k1, k6, "dest type should be part of the key")
+	k7, _ := queryCacheKey(ctx, "staging", q, &rows)
+	assert.NotEqual(t, k1, k7, "database should be part of the key")
+}
+
+func TestQueryCacheUnscoped(t *testing.T) {
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			ents := qi.Dest.(*[]testSoftDeleteEnt)
+			*ents = []testSoftDeleteEnt{{ID: 1}}
+			if !strings.Contains(qi.Query, "deleted_at IS NULL") {
+				*ents = append(*ents, testSoftDeleteEnt{ID: 2})
+			}
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := context.Background()
+	c := NewQueryCache(NewMemoryCache(10), 0)
+	q := sq.Select("*").From("gtfs_stops")
+	for i := 0; i < 2; i++ {
+		var scoped, unscoped []testSoftDeleteEnt
+		assert.NoError(t, c.Select(ctx, nil, q, &scoped))
+		assert.NoError(t, c.Select(Unscoped(ctx), nil, q, &unscoped))
+		assert.Equal(t, []testSoftDeleteEnt{{ID: 1}}, scoped)
+		assert.Equal(t, []testSoftDeleteEnt{{ID: 1}, {ID: 2}}, unscoped)
+	}
+}
+
+func TestDBCache(t *testing.T) {
+	ctx, d := WithDryRun(context.Background())
+	c := NewDBCache(nil, "dbutil_cache")
+	assert.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+	_, err := c.Sweep(ctx)
+	assert.NoError(t, err)
+	stmts := d.Statements()
+	if assert.Equal(t, 2, len(stmts)) {
+		assert.Equal(t, `INSERT INTO "dbutil_cache" (key,value,expires_at) VALUES ($1,$2,$3) ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`, stmts[0].Query)
+		assert.Equal(t, []interface{}{"key", []byte("value"), nil}, stmts[0].Args)
+		assert.Equal(t, `DELETE FROM "dbutil_cache" WHERE expires_at <= now()`, stmts[1].Query)
+	}
+}
@@ -0,0 +1,359 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jmoiron/sqlx"
+	"github.com/lann/builder"
+)
+
+// ChangeEvent is a row change decoded from a logical replication slot.
+// Action is I (insert), U (update), D (delete), or T (truncate).
+// Columns are the new row values for inserts and updates; Identity are the replica identity
+// (by default, primary key) values of the old row for updates and deletes.
+type ChangeEvent struct {
+	LSN      string                 `json:"lsn"`
+	Action   string                 `json:"action"`
+	Schema   string                 `json:"schema"`
+	Table    string                 `json:"table"`
+	Columns  map[string]interface{} `json:"columns,omitempty"`
+	Identity map[string]interface{} `json:"identity,omitempty"`
+}
+
+// Decode unmarshals the new row values into dest, e.g. an entity struct with json tags.
+func (e ChangeEvent) Decode(dest interface{}) error {
+	b, err := json.Marshal(e.Columns)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dest)
+}
+
+// ChangeConsumer reads row changes for selected tables from a logical replication slot
+// using the wal2json output plugin, which must be installed on the server.
+// Stream and Changes receive changes as they are decoded over the streaming replication protocol.
+// Consume and ConsumeBatch instead poll with pg_logical_slot_peek_changes over a regular connection,
+// e.g. through a connection pooler that does not support replication connections.
+// Either way, the slot is advanced only after changes are delivered, so delivery is at least once.
+// The database user needs the REPLICATION attribute, and wal_level must be logical.
+// An unconsumed slot retains WAL, so drop slots that are no longer used.
+type ChangeConsumer struct {
+	Slot string
+	// Tables are schema-qualified table names, e.g. "public.gtfs_stops"; if empty, all tables are read.
+	Tables    []string
+	BatchSize int
+	// PollInterval is the delay between polls in Consume, and before reconnecting in Changes.
+	PollInterval time.Duration
+	// StatusInterval is how often Stream reports its position to the server.
+	StatusInterval time.Duration
+}
+
+func NewChangeConsumer(slot string, tables ...string) *ChangeConsumer {
+	return &ChangeConsumer{
+		Slot:           slot,
+		Tables:         tables,
+		BatchSize:      1000,
+		PollInterval:   time.Second,
+		StatusInterval: 10 * time.Second,
+	}
+}
+
+// CreateSlot creates the replication slot if it does not exist.
+// Changes are captured from the time the slot is created.
+func (c *ChangeConsumer) CreateSlot(ctx context.Context, db sqlx.Ext) error {
+	var exists bool
+	if err := Get(ctx, db, sq.Select().Column("EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = ?)", c.Slot), &exists); err != nil || exists {
+		return err
+	}
+	_, err := Exec(ctx, db, sq.Expr("SELECT pg_create_logical_replication_slot(?, 'wal2json')", c.Slot))
+	return err
+}
+
+// DropSlot drops the replication slot, releasing retained WAL.
+func (c *ChangeConsumer) DropSlot(ctx context.Context, db sqlx.Ext) error {
+	_, err := Exec(ctx, db, sq.Expr("SELECT pg_drop_replication_slot(?)", c.Slot))
+	return err
+}
+
+// Consume delivers changes to cb until the context is done. If cb returns an error,
+// the batch is not acknowledged and is delivered again after PollInterval.
+func (c *ChangeConsumer) Consume(ctx context.Context, db sqlx.Ext, cb func(ChangeEvent) error) error {
+	for {
+		n, err := c.ConsumeBatch(ctx, db, cb)
+		if err != nil {
+			logger(ctx).Error().Err(err).Str("slot", c.Slot).Msg("change consumer: could not consume changes")
+		}
+		if err == nil && n > 0 {
+			// More changes are likely pending
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
+
+// Changes runs Stream in the background and delivers changes on the returned channel,
+// reconnecting after PollInterval if the stream fails. The channel is closed when the context is done.
+func (c *ChangeConsumer) Changes(ctx context.Context, dburl string) <-chan ChangeEvent {
+	ch := make(chan ChangeEvent)
+	go func() {
+		defer close(ch)
+		for {
+			err := c.Stream(ctx, dburl, func(e ChangeEvent) error {
+				select {
+				case ch <- e:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			logger(ctx).Error().Err(err).Str("slot", c.Slot).Msg("change consumer: replication stream failed")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.PollInterval):
+			}
+		}
+	}()
+	return ch
+}
+
+// Stream opens a replication connection to dburl and delivers changes to cb as they are decoded,
+// until the context is done or an error occurs. The position of the last change delivered is
+// reported to the server every StatusInterval, and when the server asks, which advances the slot;
+// changes after it are delivered again by the next Stream. If cb returns an error, Stream returns it.
+func (c *ChangeConsumer) Stream(ctx context.Context, dburl string, cb func(ChangeEvent) error) error {
+	cfg, err := pgconn.ParseConfig(dburl)
+	if err != nil {
+		return err
+	}
+	cfg.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if err := c.startReplication(ctx, conn); err != nil {
+		return err
+	}
+	statusInterval := c.StatusInterval
+	if statusInterval <= 0 {
+		statusInterval = 10 * time.Second
+	}
+	var delivered uint64
+	nextStatus := time.Now().Add(statusInterval)
+	for {
+		if !time.Now().Before(nextStatus) {
+			if err := sendStandbyStatus(conn, delivered); err != nil {
+				return err
+			}
+			nextStatus = time.Now().Add(statusInterval)
+		}
+		rctx, cancel := context.WithDeadline(ctx, nextStatus)
+		msg, err := conn.ReceiveMessage(rctx)
+		cancel()
+		if ctx.Err() != nil {
+			// Report the final position on a best effort basis; if it is lost, changes are delivered again
+			sendStandbyStatus(conn, delivered)
+			return ctx.Err()
+		} else if pgconn.Timeout(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyDone:
+			return errors.New("replication stream ended by server")
+		case *pgproto3.CopyData:
+			if len(msg.Data) == 0 {
+				continue
+			}
+			switch msg.Data[0] {
+			case 'k':
+				// Primary keepalive: end of WAL, server time, and whether a reply is requested
+				if len(msg.Data) < 18 {
+					return errors.New("replication stream: short keepalive message")
+				}
+				if msg.Data[17] == 1 {
+					nextStatus = time.Time{}
+				}
+			case 'w':
+				walStart, data, err := parseXLogData(msg.Data)
+				if err != nil {
+					return err
+				}
+				e, ok, err := parseWal2JSON(formatLSN(walStart), string(data))
+				if err != nil {
+					return err
+				}
+				if ok {
+					if err := cb(e); err != nil {
+						return err
+					}
+				}
+				delivered = walStart
+			}
+		}
+	}
+}
+
+// startReplication starts streaming from the slot's confirmed position and waits for the server to switch to copy mode.
+func (c *ChangeConsumer) startReplication(ctx context.Context, conn *pgconn.PgConn) error {
+	var opts []string
+	for _, opt := range c.pluginOptions() {
+		opts = append(opts, fmt.Sprintf("%s %s", QuoteIdentifier(opt[0]), QuoteLiteral(opt[1])))
+	}
+	sql := fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL 0/0 (%s)", QuoteIdentifier(c.Slot), strings.Join(opts, ", "))
+	conn.Frontend().SendQuery(&pgproto3.Query{String: sql})
+	if err := conn.Frontend().Flush(); err != nil {
+		return err
+	}
+	for {
+		msg, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		}
+	}
+}
+
+// pluginOptions returns the wal2json option names and values.
+func (c *ChangeConsumer) pluginOptions() [][2]string {
+	opts := [][2]string{{"format-version", "2"}, {"include-lsn", "false"}}
+	if len(c.Tables) > 0 {
+		opts = append(opts, [2]string{"add-tables", strings.Join(c.Tables, ",")})
+	}
+	return opts
+}
+
+// ConsumeBatch delivers up to BatchSize pending changes to cb, then advances the slot past them.
+// Returns the number of slot entries read, including transaction boundaries.
+func (c *ChangeConsumer) ConsumeBatch(ctx context.Context, db sqlx.Ext, cb func(ChangeEvent) error) (int, error) {
+	args := []interface{}{c.Slot, c.BatchSize}
+	for _, opt := range c.pluginOptions() {
+		args = append(args, opt[0], opt[1])
+	}
+	var rows []struct {
+		LSN  string
+		Data string
+	}
+	peek := sq.Expr(fmt.Sprintf("pg_logical_slot_peek_changes(?, NULL, ?, %s)", sq.Placeholders(len(args)-2)), args...)
+	// From only takes a table name, so set the function call with its arguments directly
+	q := builder.Set(sq.Select("lsn::text AS lsn", "data"), "From", peek).(sq.SelectBuilder)
+	if err := Select(ctx, db, q, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	for _, row := range rows {
+		e, ok, err := parseWal2JSON(row.LSN, row.Data)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if err := cb(e); err != nil {
+			return 0, err
+		}
+	}
+	last := rows[len(rows)-1].LSN
+	if _, err := Exec(ctx, db, sq.Expr("SELECT pg_replication_slot_advance(?, ?::pg_lsn)", c.Slot, last)); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// parseXLogData returns the WAL start position and data of an XLogData message:
+// 'w', the WAL start and end positions, the server time, and the data.
+func parseXLogData(msg []byte) (uint64, []byte, error) {
+	if len(msg) < 25 || msg[0] != 'w' {
+		return 0, nil, errors.New("replication stream: invalid XLogData message")
+	}
+	return binary.BigEndian.Uint64(msg[1:9]), msg[25:], nil
+}
+
+// pgEpoch is the epoch of replication protocol timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// standbyStatusUpdate returns a Standby Status Update message reporting lsn as written, flushed, and applied.
+func standbyStatusUpdate(lsn uint64, t time.Time) []byte {
+	msg := make([]byte, 34)
+	msg[0] = 'r'
+	binary.BigEndian.PutUint64(msg[1:], lsn)
+	binary.BigEndian.PutUint64(msg[9:], lsn)
+	binary.BigEndian.PutUint64(msg[17:], lsn)
+	binary.BigEndian.PutUint64(msg[25:], uint64(t.Sub(pgEpoch).Microseconds()))
+	return msg
+}
+
+func sendStandbyStatus(conn *pgconn.PgConn, lsn uint64) error {
+	conn.Frontend().Send(&pgproto3.CopyData{Data: standbyStatusUpdate(lsn, time.Now())})
+	return conn.Frontend().Flush()
+}
+
+// formatLSN formats a WAL position in the text form of pg_lsn, e.g. "0/16B3748".
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
+
+// parseWal2JSON decodes a wal2json format-version 2 entry. Entries that are not row changes,
+// such as transaction boundaries and messages, return false.
+func parseWal2JSON(lsn string, data string) (ChangeEvent, bool, error) {
+	type column struct {
+		Name  string      `json:"name"`
+		Value interface{} `json:"value"`
+	}
+	var entry struct {
+		Action   string   `json:"action"`
+		Schema   string   `json:"schema"`
+		Table    string   `json:"table"`
+		Columns  []column `json:"columns"`
+		Identity []column `json:"identity"`
+	}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return ChangeEvent{}, false, err
+	}
+	switch entry.Action {
+	case "I", "U", "D", "T":
+	default:
+		return ChangeEvent{}, false, nil
+	}
+	e := ChangeEvent{LSN: lsn, Action: entry.Action, Schema: entry.Schema, Table: entry.Table}
+	if len(entry.Columns) > 0 {
+		e.Columns = map[string]interface{}{}
+		for _, col := range entry.Columns {
+			e.Columns[col.Name] = col.Value
+		}
+	}
+	if len(entry.Identity) > 0 {
+		e.Identity = map[string]interface{}{}
+		for _, col := range entry.Identity {
+			e.Identity[col.Name] = col.Value
+		}
+	}
+	return e, true, nil
+}
@@ -0,0 +1,79 @@
+package dbutil_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeConsumerStreamDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS dbutil_cdc_test CASCADE",
+		"CREATE SCHEMA dbutil_cdc_test",
+		"CREATE TABLE dbutil_cdc_test.stops (id int PRIMARY KEY, stop_name text)",
+		"CREATE TABLE dbutil_cdc_test.other (id int PRIMARY KEY)",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.ExecContext(ctx, "DROP SCHEMA dbutil_cdc_test CASCADE")
+	c := dbutil.NewChangeConsumer("dbutil_cdc_test", "dbutil_cdc_test.stops")
+	c.StatusInterval = 10 * time.Millisecond
+	if err := c.CreateSlot(ctx, db); err != nil {
+		t.Skipf("could not create slot, requires wal_level logical and wal2json: %s", err)
+		return
+	}
+	defer c.DropSlot(ctx, db)
+	exec := func(stmt string) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// stream receives n changes, then reports its position and stops
+	dburl := os.Getenv("TL_TEST_SERVER_DATABASE_URL")
+	stream := func(n int) []dbutil.ChangeEvent {
+		sctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		var events []dbutil.ChangeEvent
+		err := c.Stream(sctx, dburl, func(e dbutil.ChangeEvent) error {
+			events = append(events, e)
+			if len(events) == n {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		return events
+	}
+
+	exec("INSERT INTO dbutil_cdc_test.stops VALUES (1, 'a'), (2, 'b')")
+	exec("INSERT INTO dbutil_cdc_test.other VALUES (1)")
+	exec("UPDATE dbutil_cdc_test.stops SET stop_name = 'bb' WHERE id = 2")
+	events := stream(3)
+	if assert.Equal(t, 3, len(events)) {
+		assert.Equal(t, []string{"I", "I", "U"}, []string{events[0].Action, events[1].Action, events[2].Action})
+		assert.Equal(t, "stops", events[2].Table)
+		assert.Equal(t, map[string]interface{}{"id": float64(2), "stop_name": "bb"}, events[2].Columns)
+	}
+
+	// Delivered changes were acknowledged, so the next stream starts after them
+	exec("DELETE FROM dbutil_cdc_test.stops WHERE id = 1")
+	events = stream(1)
+	if assert.Equal(t, 1, len(events)) {
+		assert.Equal(t, "D", events[0].Action)
+		assert.Equal(t, map[string]interface{}{"id": float64(1)}, events[0].Identity)
+	}
+}
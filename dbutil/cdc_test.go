@@ -0,0 +1,79 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseWal2JSON(t *testing.T) {
+	e, ok, err := parseWal2JSON("0/16B3748", `{"action":"U","schema":"public","table":"gtfs_stops","columns":[{"name":"id","type":"integer","value":1},{"name":"stop_name","type":"text","value":"Main St"}],"identity":[{"name":"id","type":"integer","value":1}]}`)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ChangeEvent{
+		LSN:      "0/16B3748",
+		Action:   "U",
+		Schema:   "public",
+		Table:    "gtfs_stops",
+		Columns:  map[string]interface{}{"id": float64(1), "stop_name": "Main St"},
+		Identity: map[string]interface{}{"id": float64(1)},
+	}, e)
+	var stop struct {
+		ID       int    `json:"id"`
+		StopName string `json:"stop_name"`
+	}
+	assert.NoError(t, e.Decode(&stop))
+	assert.Equal(t, "Main St", stop.StopName)
+
+	_, ok, err = parseWal2JSON("0/16B3748", `{"action":"B"}`)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestChangeConsumer_ConsumeBatch(t *testing.T) {
+	var queries []*QueryInfo
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			queries = append(queries, qi)
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	c := NewChangeConsumer("search", "public.gtfs_stops", "public.gtfs_routes")
+	n, err := c.ConsumeBatch(context.Background(), nil, func(ChangeEvent) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	if assert.Equal(t, 1, len(queries)) {
+		assert.Equal(t, "SELECT lsn::text AS lsn, data FROM pg_logical_slot_peek_changes($1, NULL, $2, $3,$4,$5,$6,$7,$8)", queries[0].Query)
+		assert.Equal(t, []interface{}{"search", 1000, "format-version", "2", "include-lsn", "false", "add-tables", "public.gtfs_stops,public.gtfs_routes"}, queries[0].Args)
+	}
+}
+
+func Test_parseXLogData(t *testing.T) {
+	msg := make([]byte, 25)
+	msg[0] = 'w'
+	binary.BigEndian.PutUint64(msg[1:], 0x116B3748)
+	msg = append(msg, `{"action":"B"}`...)
+	lsn, data, err := parseXLogData(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "0/116B3748", formatLSN(lsn))
+	assert.Equal(t, `{"action":"B"}`, string(data))
+	assert.Equal(t, "1/A", formatLSN(1<<32+10))
+
+	_, _, err = parseXLogData(msg[:10])
+	assert.Error(t, err)
+}
+
+func Test_standbyStatusUpdate(t *testing.T) {
+	msg := standbyStatusUpdate(0x16B3748, pgEpoch.Add(time.Second))
+	assert.Equal(t, 34, len(msg))
+	assert.Equal(t, byte('r'), msg[0])
+	for _, i := range []int{1, 9, 17} {
+		assert.Equal(t, uint64(0x16B3748), binary.BigEndian.Uint64(msg[i:]))
+	}
+	assert.Equal(t, uint64(1000000), binary.BigEndian.Uint64(msg[25:]))
+	assert.Equal(t, byte(0), msg[33], "no reply requested")
+}
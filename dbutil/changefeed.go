@@ -0,0 +1,195 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// FeedChange is a row change captured by ChangeFeed triggers.
+// Action is I (insert), U (update), or D (delete). RowData is the new row for inserts and updates,
+// and OldData is the old row for updates and deletes, both encoded as JSON objects.
+type FeedChange struct {
+	ID        int64
+	Txid      string
+	TableName string
+	Action    string
+	RowData   []byte
+	OldData   []byte
+	CreatedAt time.Time
+}
+
+// Decode unmarshals the new row into dest, e.g. an entity struct with json tags.
+func (c FeedChange) Decode(dest interface{}) error {
+	return json.Unmarshal(c.RowData, dest)
+}
+
+// feedCheckpoint is the last change read by a ChangeFeed consumer.
+type feedCheckpoint struct {
+	Txid string `json:"txid"`
+	ID   int64  `json:"id"`
+}
+
+// ChangeFeed captures row changes with triggers that write to a changes table, for servers
+// where logical replication is not available. Consumers read changes in order and store their
+// position in Checkpoints, so each consumer sees every change at least once.
+// Changes are read only once every transaction that could precede them has finished,
+// so changes committed out of id order are not skipped. Requires Postgres 13. Expected schema:
+//
+//	CREATE TABLE dbutil_changes (
+//		id bigserial PRIMARY KEY,
+//		txid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+//		table_name text NOT NULL,
+//		action text NOT NULL,
+//		row_data jsonb,
+//		old_data jsonb,
+//		created_at timestamptz NOT NULL DEFAULT now()
+//	);
+//	CREATE INDEX ON dbutil_changes (txid, id);
+type ChangeFeed struct {
+	Table string
+	// Channel, if set, is notified by the triggers; pass a Listener on the same channel to Consume to wake it.
+	Channel      string
+	Checkpoints  *KVStore
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// NewChangeFeed returns a ChangeFeed for the changes table, storing consumer checkpoints in checkpoints.
+func NewChangeFeed(table string, checkpoints *KVStore) *ChangeFeed {
+	return &ChangeFeed{
+		Table:        table,
+		Checkpoints:  checkpoints,
+		BatchSize:    1000,
+		PollInterval: 5 * time.Second,
+	}
+}
+
+func (f *ChangeFeed) triggerFunction() string {
+	return QuoteIdentifier(f.Table + "_capture")
+}
+
+func (f *ChangeFeed) triggerName() string {
+	name := f.Table
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return QuoteIdentifier(name + "_capture")
+}
+
+// InstallTriggers creates the capture trigger function and adds a trigger to each table.
+// Installing again replaces the function and triggers.
+func (f *ChangeFeed) InstallTriggers(ctx context.Context, db sqlx.Ext, tables ...string) error {
+	notify := ""
+	if f.Channel != "" {
+		notify = fmt.Sprintf("PERFORM pg_notify(%s, TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);", QuoteLiteral(f.Channel))
+	}
+	fn := fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO %s (table_name, action, row_data, old_data) VALUES (
+		TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME,
+		left(TG_OP, 1),
+		CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END,
+		CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END
+	);
+	%s
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql`, f.triggerFunction(), QuoteIdentifier(f.Table), notify)
+	if _, err := Exec(ctx, db, RawSQL(fn)); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		stmts := []string{
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", f.triggerName(), QuoteIdentifier(table)),
+			fmt.Sprintf("CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s()", f.triggerName(), QuoteIdentifier(table), f.triggerFunction()),
+		}
+		for _, stmt := range stmts {
+			if _, err := Exec(ctx, db, RawSQL(stmt)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveTriggers removes the capture trigger from each table.
+func (f *ChangeFeed) RemoveTriggers(ctx context.Context, db sqlx.Ext, tables ...string) error {
+	for _, table := range tables {
+		if _, err := Exec(ctx, db, RawSQL(fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", f.triggerName(), QuoteIdentifier(table)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Consume delivers changes to cb until the context is done. If cb returns an error,
+// the batch is not checkpointed and is delivered again after PollInterval.
+// If listener is not nil, Consume also wakes on its notifications instead of waiting for the next poll.
+func (f *ChangeFeed) Consume(ctx context.Context, db *sqlx.DB, consumer string, listener *Listener, cb func(FeedChange) error) error {
+	var wake <-chan Notification
+	if listener != nil {
+		wake = listener.Notifications(ctx)
+	}
+	for {
+		n, err := f.ConsumeBatch(ctx, db, consumer, cb)
+		if err != nil {
+			logger(ctx).Error().Err(err).Str("table", f.Table).Str("consumer", consumer).Msg("change feed: could not consume changes")
+		}
+		if err == nil && n == f.BatchSize {
+			// More changes are likely pending
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-time.After(f.PollInterval):
+		}
+	}
+}
+
+// ConsumeBatch delivers up to BatchSize changes after the consumer's checkpoint to cb,
+// then advances the checkpoint. Returns the number of changes delivered.
+func (f *ChangeFeed) ConsumeBatch(ctx context.Context, db *sqlx.DB, consumer string, cb func(FeedChange) error) (int, error) {
+	key := "changefeed:" + f.Table + ":" + consumer
+	var cp feedCheckpoint
+	if _, err := f.Checkpoints.Get(ctx, db, key, &cp); err != nil {
+		return 0, err
+	}
+	q := sq.Select("id", "txid::text AS txid", "table_name", "action", "row_data", "old_data", "created_at").
+		From(QuoteIdentifier(f.Table)).
+		Where("txid < pg_snapshot_xmin(pg_current_snapshot())").
+		OrderBy("txid", "id").
+		Limit(uint64(f.BatchSize))
+	if cp.Txid != "" {
+		q = q.Where("(txid, id) > (?::xid8, ?)", cp.Txid, cp.ID)
+	}
+	var changes []FeedChange
+	if err := Select(ctx, db, q, &changes); err != nil {
+		return 0, err
+	}
+	for _, change := range changes {
+		if err := cb(change); err != nil {
+			return 0, err
+		}
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	last := changes[len(changes)-1]
+	if err := f.Checkpoints.Set(ctx, db, key, feedCheckpoint{Txid: last.Txid, ID: last.ID}, 0); err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+// Prune removes changes older than age, e.g. once all consumers have read them.
+func (f *ChangeFeed) Prune(ctx context.Context, db sqlx.Ext, age time.Duration) (int64, error) {
+	return execRowsAffected(ctx, db, sq.Delete(QuoteIdentifier(f.Table)).Where("created_at < now() - make_interval(secs => ?)", age.Seconds()))
+}
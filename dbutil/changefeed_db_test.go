@@ -0,0 +1,94 @@
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeFeedDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS dbutil_feed_test CASCADE",
+		"CREATE SCHEMA dbutil_feed_test",
+		`CREATE TABLE dbutil_feed_test."Changes" (
+			id bigserial PRIMARY KEY,
+			txid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+			table_name text NOT NULL,
+			action text NOT NULL,
+			row_data jsonb,
+			old_data jsonb,
+			created_at timestamptz NOT NULL DEFAULT now()
+		)`,
+		"CREATE TABLE dbutil_feed_test.kv (key text PRIMARY KEY, value jsonb NOT NULL, expires_at timestamptz, updated_at timestamptz NOT NULL DEFAULT now())",
+		"CREATE TABLE dbutil_feed_test.stops (id int PRIMARY KEY, stop_name text)",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.ExecContext(ctx, "DROP SCHEMA dbutil_feed_test CASCADE")
+	f := dbutil.NewChangeFeed("dbutil_feed_test.Changes", dbutil.NewKVStore("dbutil_feed_test.kv"))
+	if err := f.InstallTriggers(ctx, db, "dbutil_feed_test.stops"); err != nil {
+		t.Fatal(err)
+	}
+	var changes []dbutil.FeedChange
+	consume := func() int {
+		n, err := f.ConsumeBatch(ctx, db, "search", func(c dbutil.FeedChange) error {
+			changes = append(changes, c)
+			return nil
+		})
+		assert.NoError(t, err)
+		return n
+	}
+
+	// The first transaction writes its change, and so takes its txid, before the second,
+	// but commits after it, so its change has the lower txid and is not yet visible
+	txa, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txa.Rollback()
+	if _, err := txa.ExecContext(ctx, "INSERT INTO dbutil_feed_test.stops VALUES (1, 'a')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO dbutil_feed_test.stops VALUES (2, 'b')"); err != nil {
+		t.Fatal(err)
+	}
+	// The committed change follows a running transaction, so it is held back rather than skipped
+	assert.Equal(t, 0, consume())
+	if err := txa.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, consume())
+	if assert.Equal(t, 2, len(changes)) {
+		var stop struct {
+			ID int `json:"id"`
+		}
+		assert.NoError(t, changes[0].Decode(&stop))
+		assert.Equal(t, 1, stop.ID, "changes are ordered by txid")
+		assert.NoError(t, changes[1].Decode(&stop))
+		assert.Equal(t, 2, stop.ID)
+		assert.Equal(t, "dbutil_feed_test.stops", changes[0].TableName)
+		assert.Equal(t, "I", changes[0].Action)
+	}
+
+	// The checkpoint is stored, so changes are not delivered again
+	if _, err := db.ExecContext(ctx, "UPDATE dbutil_feed_test.stops SET stop_name = 'bb' WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, consume())
+	if assert.Equal(t, 3, len(changes)) {
+		assert.Equal(t, "U", changes[2].Action)
+	}
+	assert.Equal(t, 0, consume())
+}
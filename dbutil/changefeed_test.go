@@ -0,0 +1,36 @@
+package dbutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeFeedInstallTriggers(t *testing.T) {
+	ctx, d := WithDryRun(context.Background())
+	f := NewChangeFeed("public.dbutil_changes", NewKVStore("dbutil_kv"))
+	f.Channel = "changes"
+	assert.NoError(t, f.InstallTriggers(ctx, nil, "gtfs_stops"))
+	stmts := d.Statements()
+	if assert.Equal(t, 3, len(stmts)) {
+		assert.True(t, strings.HasPrefix(stmts[0].Query, `CREATE OR REPLACE FUNCTION "public"."dbutil_changes_capture"() RETURNS trigger`))
+		assert.Contains(t, stmts[0].Query, `INSERT INTO "public"."dbutil_changes" (table_name, action, row_data, old_data)`)
+		assert.Contains(t, stmts[0].Query, `PERFORM pg_notify('changes', TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);`)
+		assert.Equal(t, `DROP TRIGGER IF EXISTS "dbutil_changes_capture" ON "gtfs_stops"`, stmts[1].Query)
+		assert.Equal(t, `CREATE TRIGGER "dbutil_changes_capture" AFTER INSERT OR UPDATE OR DELETE ON "gtfs_stops" FOR EACH ROW EXECUTE FUNCTION "public"."dbutil_changes_capture"()`, stmts[2].Query)
+	}
+}
+
+func TestChangeFeedPrune(t *testing.T) {
+	ctx, d := WithDryRun(context.Background())
+	f := NewChangeFeed("public.dbutil_changes", NewKVStore("dbutil_kv"))
+	_, err := f.Prune(ctx, nil, time.Hour)
+	assert.NoError(t, err)
+	stmts := d.Statements()
+	if assert.Equal(t, 1, len(stmts)) {
+		assert.Equal(t, `DELETE FROM "public"."dbutil_changes" WHERE created_at < now() - make_interval(secs => $1)`, stmts[0].Query)
+	}
+}
@@ -0,0 +1,71 @@
+package dbutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ChangeSet snapshots an entity's column values so that a later update writes only the
+// columns that changed, instead of clobbering concurrent changes to other columns.
+type ChangeSet struct {
+	snapshot map[string]interface{}
+}
+
+// NewChangeSet snapshots the current column values of ent, typically right after it was loaded.
+// Values are copied deeply, so in-place changes to pointer, slice, and map fields are detected.
+func NewChangeSet(ent interface{}) *ChangeSet {
+	return &ChangeSet{snapshot: snapshotValues(ent)}
+}
+
+// Changed returns the columns of ent that differ from the snapshot, with their new values.
+func (c *ChangeSet) Changed(ent interface{}) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for col, val := range entValues(ent) {
+		if old, ok := c.snapshot[col]; !ok || !reflect.DeepEqual(old, val) {
+			ret[col] = val
+		}
+	}
+	return ret
+}
+
+// Update returns an UPDATE of table setting only the changed columns of ent, for the row
+// matching ent's id column. Returns false if nothing changed, and an error if ent has no id.
+func (c *ChangeSet) Update(table string, ent interface{}) (sq.UpdateBuilder, bool, error) {
+	id, ok := entValues(ent)["id"]
+	if !ok || id == nil {
+		return sq.UpdateBuilder{}, false, fmt.Errorf("%T has no id column value", ent)
+	}
+	changed := c.Changed(ent)
+	delete(changed, "id")
+	q := sq.Update(QuoteIdentifier(table)).Where("id = ?", id)
+	if len(changed) == 0 {
+		return q, false, nil
+	}
+	var cols []string
+	for col := range changed {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		q = q.Set(QuoteIdentifier(col), changed[col])
+	}
+	return q, true, nil
+}
+
+// Reset takes a new snapshot of ent, e.g. after a successful update.
+func (c *ChangeSet) Reset(ent interface{}) {
+	c.snapshot = snapshotValues(ent)
+}
+
+func snapshotValues(ent interface{}) map[string]interface{} {
+	ret := entValues(ent)
+	for col, val := range ret {
+		if val != nil {
+			ret[col] = deepCopy(reflect.ValueOf(val)).Interface()
+		}
+	}
+	return ret
+}
@@ -0,0 +1,58 @@
+package dbutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeSet(t *testing.T) {
+	type ent struct {
+		ID        int
+		StopName  string
+		StopCode  string `db:"code"`
+		UpdatedAt time.Time
+	}
+	e := &ent{ID: 1, StopName: "a", StopCode: "x"}
+	cs := NewChangeSet(e)
+	_, ok, err := cs.Update("stops", e)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	e.StopName = "b"
+	e.StopCode = "y"
+	assert.Equal(t, map[string]interface{}{"stop_name": "b", "code": "y"}, cs.Changed(e))
+	q, ok, err := cs.Update("stops", e)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	qstr, qargs, err := q.ToSql()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `UPDATE "stops" SET "code" = ?, "stop_name" = ? WHERE id = ?`, qstr)
+	assert.Equal(t, []interface{}{"y", "b", 1}, qargs)
+
+	cs.Reset(e)
+	assert.Equal(t, 0, len(cs.Changed(e)))
+}
+
+func TestChangeSetDeep(t *testing.T) {
+	type ent struct {
+		ID       int
+		StopDesc *string
+		Tags     []string
+	}
+	desc := "a"
+	e := &ent{ID: 1, StopDesc: &desc, Tags: []string{"x"}}
+	cs := NewChangeSet(e)
+	*e.StopDesc = "b"
+	e.Tags[0] = "y"
+	assert.Equal(t, map[string]interface{}{"stop_desc": e.StopDesc, "tags": e.Tags}, cs.Changed(e))
+
+	type noID struct {
+		StopName string
+	}
+	_, _, err := NewChangeSet(&noID{}).Update("stops", &noID{StopName: "a"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultChunkSize is the number of keys per query used by SelectChunked when no chunk size is given.
+const DefaultChunkSize = 10000
+
+// Any returns a "col = ANY(?)" condition that passes values as a single array parameter,
+// unlike sq.Eq with a slice, which uses one bind parameter per value.
+func Any[K any](col string, values []K) sq.Sqlizer {
+	return sq.Expr(col+" = ANY(?)", values)
+}
+
+// SelectChunked runs q once for each chunk of at most chunkSize keys, with an added
+// "col = ANY(?)" condition, and returns the combined results. Use it for key lists large
+// enough that a single query would be slow to plan or hold locks for too long.
+// Ordering, limits, and aggregates in q apply within each chunk, not across all results.
+// Progress is reported in keys to any WithProgress hook.
+func SelectChunked[T any, K any](ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, col string, keys []K, chunkSize int) ([]T, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	var ret []T
+	done := 0
+	for _, chunk := range Chunks(keys, chunkSize) {
+		var rows []T
+		if err := Select(ctx, db, q.Where(Any(col, chunk)), &rows); err != nil {
+			return nil, err
+		}
+		ret = append(ret, rows...)
+		done += len(chunk)
+		reportProgress(ctx, done, len(keys))
+	}
+	return ret, nil
+}
+
+// Chunks splits values into consecutive slices of at most size values.
+// It panics if size is not greater than 0.
+func Chunks[K any](values []K, size int) [][]K {
+	if size <= 0 {
+		panic(fmt.Sprintf("dbutil: chunk size must be greater than 0, got %d", size))
+	}
+	var ret [][]K
+	for i := 0; i < len(values); i += size {
+		end := i + size
+		if end > len(values) {
+			end = len(values)
+		}
+		ret = append(ret, values[i:end])
+	}
+	return ret
+}
@@ -0,0 +1,23 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunks(t *testing.T) {
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, Chunks([]int{1, 2, 3, 4, 5}, 2))
+	assert.Equal(t, [][]int{{1, 2}}, Chunks([]int{1, 2}, 10))
+	assert.Nil(t, Chunks([]int{}, 10))
+	assert.PanicsWithValue(t, "dbutil: chunk size must be greater than 0, got 0", func() { Chunks([]int{1}, 0) })
+}
+
+func TestAny(t *testing.T) {
+	ids := make([]int, MaxBindParams+1)
+	qstr, qargs, err := sq.Select("*").From("t").Where(Any("id", ids)).PlaceholderFormat(sq.Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE id = ANY($1)", qstr)
+	assert.Equal(t, 1, len(qargs))
+}
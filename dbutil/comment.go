@@ -0,0 +1,89 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// GetTableComment returns the comment on a table, or an empty string.
+func GetTableComment(ctx context.Context, db sqlx.Ext, table string) (string, error) {
+	var comment string
+	q := sq.Select().Column("coalesce(obj_description(?::regclass, 'pg_class'), '')", QuoteIdentifier(table))
+	err := Get(ctx, db, q, &comment)
+	return comment, err
+}
+
+// SetTableComment sets the comment on a table. An empty comment removes it.
+func SetTableComment(ctx context.Context, db sqlx.Ext, table string, comment string) error {
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf("COMMENT ON TABLE %s IS %s", QuoteIdentifier(table), commentLiteral(comment))))
+	return err
+}
+
+// GetColumnComments returns the comments on a table's columns, keyed by column name.
+// Columns without comments are omitted.
+func GetColumnComments(ctx context.Context, db sqlx.Ext, table string) (map[string]string, error) {
+	var rows []struct {
+		Name    string `db:"name"`
+		Comment string `db:"comment"`
+	}
+	q := sq.Select("attname AS name", "col_description(attrelid, attnum) AS comment").
+		From("pg_attribute").
+		Where("attrelid = ?::regclass", QuoteIdentifier(table)).
+		Where("attnum > 0").
+		Where("NOT attisdropped").
+		Where("col_description(attrelid, attnum) IS NOT NULL")
+	if err := Select(ctx, db, q, &rows); err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	for _, row := range rows {
+		ret[row.Name] = row.Comment
+	}
+	return ret, nil
+}
+
+// SetColumnComment sets the comment on a column. An empty comment removes it.
+func SetColumnComment(ctx context.Context, db sqlx.Ext, table string, column string, comment string) error {
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf(
+		"COMMENT ON COLUMN %s.%s IS %s",
+		QuoteIdentifier(table),
+		QuoteIdentifier(column),
+		commentLiteral(comment),
+	)))
+	return err
+}
+
+// SetColumnCommentsFromStruct sets column comments from `comment` struct tags on ent.
+// Column names follow the same rules as OpenDB: the db tag, or the snake_case field name.
+func SetColumnCommentsFromStruct(ctx context.Context, db sqlx.Ext, table string, ent interface{}) error {
+	for col, comment := range StructComments(ent) {
+		if err := SetColumnComment(ctx, db, table, col, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StructComments returns the `comment` struct tags on ent, keyed by column name.
+func StructComments(ent interface{}) map[string]string {
+	ret := map[string]string{}
+	for _, fi := range entColumns(reflect.TypeOf(ent)) {
+		if comment := fi.Field.Tag.Get("comment"); comment != "" {
+			ret[fi.Name] = comment
+		}
+	}
+	return ret
+}
+
+// commentLiteral quotes a comment as a string literal, or NULL if empty.
+// COMMENT ON does not accept bind parameters.
+func commentLiteral(comment string) string {
+	if comment == "" {
+		return "NULL"
+	}
+	return QuoteLiteral(comment)
+}
@@ -0,0 +1,41 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructComments(t *testing.T) {
+	type base struct {
+		ID int `comment:"Primary key"`
+	}
+	type ent struct {
+		base
+		StopName string `comment:"Name of the stop"`
+		StopCode string `db:"code" comment:"Short code"`
+		StopDesc string
+	}
+	assert.Equal(t, map[string]string{
+		"id":        "Primary key",
+		"stop_name": "Name of the stop",
+		"code":      "Short code",
+	}, StructComments(&ent{}))
+	assert.Equal(t, "NULL", commentLiteral(""))
+	assert.Equal(t, "'O''Hare'", commentLiteral("O'Hare"))
+}
+
+func TestSetTableComment(t *testing.T) {
+	ctx, d := WithDryRun(AllowDDL(context.Background()))
+	assert.NoError(t, SetTableComment(ctx, nil, "gtfs_stops", "Is it accessible? Yes ?? no"))
+	assert.NoError(t, SetColumnComment(ctx, nil, "gtfs_stops", "wheelchair_boarding", "Accessible?"))
+	var stmts []string
+	for _, qi := range d.Statements() {
+		stmts = append(stmts, qi.Query)
+	}
+	assert.Equal(t, []string{
+		`COMMENT ON TABLE "gtfs_stops" IS 'Is it accessible? Yes ?? no'`,
+		`COMMENT ON COLUMN "gtfs_stops"."wheelchair_boarding" IS 'Accessible?'`,
+	}, stmts)
+}
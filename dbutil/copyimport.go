@@ -0,0 +1,135 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CopySource is the rows to COPY into one table, e.g. from pgx.CopyFromRows or pgx.CopyFromSlice.
+type CopySource struct {
+	Table   string
+	Columns []string
+	Rows    pgx.CopyFromSource
+}
+
+// CopyImportResult reports the rows copied into one table.
+type CopyImportResult struct {
+	Table   string        `json:"table"`
+	Rows    int64         `json:"rows"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// CopyImportError reports the tables that failed in CopyImport, keyed by table name.
+type CopyImportError struct {
+	Errs map[string]error
+}
+
+func (e *CopyImportError) Error() string {
+	var keys []string
+	for k := range e.Errs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var msgs []string
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", k, e.Errs[k].Error()))
+	}
+	return fmt.Sprintf("%d tables failed: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+// CopyImport copies independent tables in parallel, each in its own transaction,
+// with at most concurrency tables copying at once. After all tables are copied,
+// validate is called if not nil. If any table fails, the remaining copies are canceled
+// and the returned error is a *CopyImportError; tables that already committed are not rolled back,
+// so callers should import into staging tables or clean up on failure.
+// Statement timeouts and schemas from ctx are applied to each transaction, as in Tx,
+// and copies are recorded and skipped in a dry run context.
+func CopyImport(ctx context.Context, pool *pgxpool.Pool, concurrency int, sources []CopySource, validate func(context.Context) error) ([]CopyImportResult, error) {
+	return copyImport(ctx, concurrency, sources, validate, func(ctx context.Context, src CopySource) (int64, error) {
+		return copyTable(ctx, pool, src)
+	})
+}
+
+func copyImport(ctx context.Context, concurrency int, sources []CopySource, validate func(context.Context) error, copyFn func(context.Context, CopySource) (int64, error)) ([]CopyImportResult, error) {
+	if concurrency <= 0 {
+		concurrency = len(sources)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	sem := make(chan struct{}, concurrency)
+	errs := map[string]error{}
+	results := make([]CopyImportResult, len(sources))
+	for i, src := range sources {
+		wg.Add(1)
+		go func(i int, src CopySource) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				lock.Lock()
+				errs[src.Table] = ctx.Err()
+				lock.Unlock()
+				return
+			}
+			t := time.Now()
+			n, err := copyFn(ctx, src)
+			results[i] = CopyImportResult{Table: src.Table, Rows: n, Elapsed: time.Since(t)}
+			if err != nil {
+				lock.Lock()
+				errs[src.Table] = err
+				lock.Unlock()
+				cancel()
+				return
+			}
+			logger(ctx).Info().Str("table", src.Table).Int64("rows", n).Dur("elapsed", results[i].Elapsed).Msg("copy import: copied table")
+		}(i, src)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return results, &CopyImportError{Errs: errs}
+	}
+	if validate != nil {
+		if err := validate(ctx); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func copyTable(ctx context.Context, pool *pgxpool.Pool, src CopySource) (int64, error) {
+	if dryRunCopy(ctx, src.Table, src.Columns) {
+		return 0, nil
+	}
+	ctx, cancel := withQueryTimeout(ctx, CopyStatement)
+	defer cancel()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+	if d, ok := statementTimeoutFromContext(ctx); ok {
+		if _, err := tx.Exec(ctx, statementTimeoutStatement(d)); err != nil {
+			return 0, err
+		}
+	}
+	if schema, ok := SchemaFromContext(ctx); ok {
+		if _, err := tx.Exec(ctx, searchPathStatement(schema)); err != nil {
+			return 0, err
+		}
+	}
+	n, err := tx.CopyFrom(ctx, pgx.Identifier(strings.Split(src.Table, ".")), src.Columns, src.Rows)
+	if err != nil {
+		return n, err
+	}
+	return n, tx.Commit(ctx)
+}
@@ -0,0 +1,69 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyImportError(t *testing.T) {
+	err := &CopyImportError{Errs: map[string]error{
+		"gtfs_trips": errors.New("b"),
+		"gtfs_stops": errors.New("a"),
+	}}
+	assert.Equal(t, "2 tables failed: gtfs_stops: a; gtfs_trips: b", err.Error())
+}
+
+func TestCopyImport(t *testing.T) {
+	ctx := context.Background()
+	sources := []CopySource{{Table: "gtfs_stops"}, {Table: "gtfs_trips"}, {Table: "gtfs_routes"}}
+	t.Run("ok", func(t *testing.T) {
+		validated := false
+		results, err := copyImport(ctx, 2, sources, func(context.Context) error {
+			validated = true
+			return nil
+		}, func(ctx context.Context, src CopySource) (int64, error) {
+			return int64(len(src.Table)), nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, validated)
+		if assert.Len(t, results, 3) {
+			assert.Equal(t, "gtfs_trips", results[1].Table)
+			assert.Equal(t, int64(10), results[1].Rows)
+		}
+	})
+	t.Run("validate", func(t *testing.T) {
+		invalid := errors.New("invalid")
+		_, err := copyImport(ctx, 2, sources, func(context.Context) error {
+			return invalid
+		}, func(ctx context.Context, src CopySource) (int64, error) {
+			return 0, nil
+		})
+		assert.ErrorIs(t, err, invalid)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		failed := errors.New("failed")
+		validated := false
+		_, err := copyImport(ctx, 3, sources, func(context.Context) error {
+			validated = true
+			return nil
+		}, func(ctx context.Context, src CopySource) (int64, error) {
+			if src.Table == "gtfs_trips" {
+				return 0, failed
+			}
+			// Other copies run until canceled
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		assert.False(t, validated)
+		var importErr *CopyImportError
+		if assert.ErrorAs(t, err, &importErr) {
+			assert.Len(t, importErr.Errs, 3)
+			assert.ErrorIs(t, importErr.Errs["gtfs_trips"], failed)
+			assert.ErrorIs(t, importErr.Errs["gtfs_stops"], context.Canceled)
+			assert.ErrorIs(t, importErr.Errs["gtfs_routes"], context.Canceled)
+		}
+	})
+}
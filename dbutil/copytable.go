@@ -0,0 +1,232 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CopyTable copies the rows of table matching where, or all rows if where is nil, from src to dst,
+// e.g. to seed a staging database from a subset of production. Rows are streamed from COPY TO on src
+// into COPY FROM on dst in the text format, without decoding values, so types pgx does not know,
+// such as PostGIS geometries and enums, are copied as is. If batchSize is greater than zero, rows
+// are copied in ranges of batchSize values of the table's id column, each committed separately;
+// otherwise in a single COPY. The columns of the table on dst are copied, excluding generated columns,
+// and must exist on src. COPY does not accept bind parameters, so arguments of where are inlined
+// as literals. Progress is reported in rows to any WithProgress hook. Returns the number of rows copied.
+func CopyTable(ctx context.Context, src *pgxpool.Pool, dst *pgxpool.Pool, table string, where sq.Sqlizer, batchSize int) (int64, error) {
+	cols, err := copyColumns(ctx, dst, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(cols) == 0 {
+		return 0, fmt.Errorf("table %s has no columns to copy", table)
+	}
+	var quoted []string
+	for _, col := range cols {
+		quoted = append(quoted, QuoteIdentifier(col))
+	}
+	q := sq.Select(quoted...).From(QuoteIdentifier(table))
+	countq := sq.Select("count(*)").From(QuoteIdentifier(table)).PlaceholderFormat(sq.Dollar)
+	if where != nil {
+		q = q.Where(where)
+		countq = countq.Where(where)
+	}
+	var total int64
+	if err := poolGet(ctx, src, countq, &total); err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		n, err := copyRows(ctx, src, dst, table, cols, q)
+		reportProgress(ctx, int(n), int(total))
+		return n, err
+	}
+	if !hasColumn(cols, "id") {
+		return 0, fmt.Errorf("table %s has no id column to copy in batches", table)
+	}
+	var minID, maxID *int64
+	boundsq := sq.Select("min(id)", "max(id)").From(QuoteIdentifier(table)).PlaceholderFormat(sq.Dollar)
+	if where != nil {
+		boundsq = boundsq.Where(where)
+	}
+	if err := poolGet(ctx, src, boundsq, &minID, &maxID); err != nil || minID == nil {
+		return 0, err
+	}
+	done := int64(0)
+	for start := *minID; start <= *maxID; start += int64(batchSize) {
+		n, err := copyRows(ctx, src, dst, table, cols, q.Where("id >= ? AND id < ?", start, start+int64(batchSize)))
+		done += n
+		if err != nil {
+			return done, err
+		}
+		reportProgress(ctx, int(done), int(total))
+	}
+	return done, nil
+}
+
+// copyRows pipes COPY TO of the rows of q on src into COPY FROM into table on dst.
+func copyRows(ctx context.Context, src *pgxpool.Pool, dst *pgxpool.Pool, table string, cols []string, q sq.SelectBuilder) (int64, error) {
+	if dryRunCopy(ctx, table, cols) {
+		return 0, nil
+	}
+	t := time.Now()
+	qstr, err := inlineArgs(q)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := withQueryTimeout(ctx, CopyStatement)
+	defer cancel()
+	srcConn, err := src.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer srcConn.Release()
+	dstConn, err := dst.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer dstConn.Release()
+
+	// Stop the source COPY if the destination fails, and fail the destination COPY if the source fails
+	ctx, cancelCopy := context.WithCancel(ctx)
+	defer cancelCopy()
+	pr, pw := io.Pipe()
+	copyTo := make(chan error, 1)
+	go func() {
+		_, err := srcConn.Conn().PgConn().CopyTo(ctx, pw, fmt.Sprintf("COPY (%s) TO STDOUT", qstr))
+		pw.CloseWithError(err)
+		copyTo <- err
+	}()
+	tag, err := dstConn.Conn().PgConn().CopyFrom(ctx, pr, copyFromStatement(table, cols))
+	if err != nil {
+		cancelCopy()
+		pr.CloseWithError(err)
+	}
+	if srcErr := <-copyTo; srcErr != nil && err == nil {
+		err = srcErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	logger(ctx).Debug().Str("table", table).Int64("rows", tag.RowsAffected()).Dur("elapsed", time.Since(t)).Msg("copy table: copied rows")
+	return tag.RowsAffected(), nil
+}
+
+func copyFromStatement(table string, cols []string) string {
+	var quoted []string
+	for _, col := range cols {
+		quoted = append(quoted, QuoteIdentifier(col))
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN", QuoteIdentifier(table), strings.Join(quoted, ", "))
+}
+
+// inlineArgs renders q with its arguments as SQL literals, for statements that do not accept bind parameters.
+// As with Dollar placeholders, "??" is an escaped "?".
+func inlineArgs(q sq.Sqlizer) (string, error) {
+	qstr, qargs, err := q.ToSql()
+	if err != nil {
+		return "", err
+	}
+	m := pgtype.NewMap()
+	var sb strings.Builder
+	for i := 0; i < len(qstr); i++ {
+		if qstr[i] != '?' {
+			sb.WriteByte(qstr[i])
+			continue
+		}
+		if i+1 < len(qstr) && qstr[i+1] == '?' {
+			sb.WriteByte('?')
+			i++
+			continue
+		}
+		if len(qargs) == 0 {
+			return "", fmt.Errorf("not enough arguments for placeholders in '%s'", qstr)
+		}
+		lit, err := sqlLiteral(m, qargs[0])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(lit)
+		qargs = qargs[1:]
+	}
+	if len(qargs) > 0 {
+		return "", fmt.Errorf("too many arguments for placeholders in '%s'", qstr)
+	}
+	return sb.String(), nil
+}
+
+// sqlLiteral renders a query argument as a SQL literal. Strings are left untyped, like bind parameters,
+// so they are resolved from context; other values are cast to the type pgx would encode them as.
+func sqlLiteral(m *pgtype.Map, v interface{}) (string, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		val, err := valuer.Value()
+		if err != nil {
+			return "", err
+		}
+		v = val
+	}
+	switch val := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return QuoteLiteral(val), nil
+	}
+	typ, ok := m.TypeForValue(v)
+	if !ok {
+		return "", fmt.Errorf("can not render %T as a SQL literal", v)
+	}
+	buf, err := m.Encode(typ.OID, pgtype.TextFormatCode, v, nil)
+	if err != nil {
+		return "", err
+	}
+	if buf == nil {
+		return "NULL", nil
+	}
+	return QuoteLiteral(string(buf)) + "::" + typ.Name, nil
+}
+
+func hasColumn(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// copyColumns returns the non-generated columns of table.
+func copyColumns(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	q := sq.Select("attname").
+		From("pg_attribute").
+		Where("attrelid = ?::regclass", QuoteIdentifier(table)).
+		Where("attnum > 0").
+		Where("NOT attisdropped").
+		Where("attgenerated = ''").
+		OrderBy("attnum").
+		PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, qstr, qargs...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func poolGet(ctx context.Context, pool *pgxpool.Pool, q sq.SelectBuilder, dest ...interface{}) error {
+	qstr, qargs, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+	return pool.QueryRow(ctx, qstr, qargs...).Scan(dest...)
+}
@@ -0,0 +1,80 @@
+package dbutil_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// openSchemaPool opens a pool whose search_path is schema, so unqualified table names resolve there.
+func openSchemaPool(t *testing.T, schema string) *pgxpool.Pool {
+	cfg, err := pgxpool.ParseConfig(os.Getenv("TL_TEST_SERVER_DATABASE_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestCopyTableDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	for _, schema := range []string{"dbutil_copy_src", "dbutil_copy_dst"} {
+		stmts := []string{
+			"DROP SCHEMA IF EXISTS " + schema + " CASCADE",
+			"CREATE SCHEMA " + schema,
+			"CREATE TYPE " + schema + ".stop_kind AS ENUM ('stop', 'station')",
+			"CREATE TABLE " + schema + `."CopyStops" (id bigint PRIMARY KEY, stop_name text, kind ` + schema + ".stop_kind, loc point)",
+		}
+		for _, stmt := range stmts {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer db.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO dbutil_copy_src."CopyStops" SELECT i, 'stop ' || i || E'\t?', 'station', point(i, i) FROM generate_series(1, 10) i`); err != nil {
+		t.Fatal(err)
+	}
+	src := openSchemaPool(t, "dbutil_copy_src")
+	dst := openSchemaPool(t, "dbutil_copy_dst")
+	count := func() (n int) {
+		if err := db.GetContext(ctx, &n, `SELECT count(*) FROM dbutil_copy_dst."CopyStops" d JOIN dbutil_copy_src."CopyStops" s ON s.id = d.id AND s.stop_name = d.stop_name AND s.kind::text = d.kind::text AND s.loc ~= d.loc`); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	n, err := dbutil.CopyTable(ctx, src, dst, "CopyStops", sq.Expr("id <= ? AND stop_name <> ?", 4, "x"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	assert.Equal(t, 4, count())
+
+	var progress [][2]int
+	pctx := dbutil.WithProgress(ctx, func(done, total int) { progress = append(progress, [2]int{done, total}) })
+	n, err = dbutil.CopyTable(pctx, src, dst, "CopyStops", sq.Expr("id > ?", 4), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, 10, count())
+	assert.Equal(t, [][2]int{{4, 6}, {6, 6}}, progress)
+
+	// Copying a duplicate key fails on the destination and stops the source
+	_, err = dbutil.CopyTable(ctx, src, dst, "CopyStops", nil, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 10, count())
+}
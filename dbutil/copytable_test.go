@@ -0,0 +1,32 @@
+package dbutil
+
+import (
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineArgs(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var missing *int
+	q := sq.Select(`"id"`).From(`"gtfs_stops"`).
+		Where("stop_name = ? AND stop_desc <> 'a??''b'", "it's").
+		Where("id >= ? AND id < ?", int64(1), 10).
+		Where("id = ANY(?)", []int{1, 2}).
+		Where("updated_at > ? AND parent_id IS NOT DISTINCT FROM ?", ts, missing).
+		Where("tags ?? 'x' AND wheelchair = ?", true)
+	qstr, err := inlineArgs(q)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT "id" FROM "gtfs_stops" WHERE stop_name = 'it''s' AND stop_desc <> 'a?''b' AND id >= '1'::int8 AND id < '10'::int8 AND id = ANY('{1,2}'::_int8) AND updated_at > '2024-01-02 03:04:05Z'::timestamptz AND parent_id IS NOT DISTINCT FROM NULL AND tags ? 'x' AND wheelchair = 't'::bool`, qstr)
+
+	_, err = inlineArgs(sq.Expr("id = ?"))
+	assert.Error(t, err)
+	_, err = inlineArgs(sq.Expr("id = 1", 2))
+	assert.Error(t, err)
+}
+
+func TestCopyFromStatement(t *testing.T) {
+	assert.Equal(t, `COPY "public"."gtfs_stops" ("id", "StopName") FROM STDIN`, copyFromStatement("public.gtfs_stops", []string{"id", "StopName"}))
+}
@@ -0,0 +1,194 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// CredentialSource provides the user and password for new database connections.
+// An empty user keeps the user from the connection url.
+// Secrets manager integrations without a source here, such as AWS Secrets Manager,
+// can implement this interface directly or use CredentialFunc.
+type CredentialSource interface {
+	Credentials(context.Context) (user string, password string, err error)
+}
+
+// StaticCredentials is a fixed user and password.
+type StaticCredentials struct {
+	User     string
+	Password string
+}
+
+func (c StaticCredentials) Credentials(ctx context.Context) (string, string, error) {
+	return c.User, c.Password, nil
+}
+
+// EnvCredentials reads the user and password from environment variables at connect time.
+type EnvCredentials struct {
+	UserVar     string
+	PasswordVar string
+}
+
+func (c EnvCredentials) Credentials(ctx context.Context) (string, string, error) {
+	user := ""
+	if c.UserVar != "" {
+		user = os.Getenv(c.UserVar)
+	}
+	password, ok := os.LookupEnv(c.PasswordVar)
+	if !ok {
+		return "", "", fmt.Errorf("environment variable %s not set", c.PasswordVar)
+	}
+	return user, password, nil
+}
+
+// FileCredentials reads the user and password from files at connect time,
+// e.g. mounted Kubernetes secrets, so rotated secrets are picked up by new connections.
+type FileCredentials struct {
+	UserFile     string
+	PasswordFile string
+}
+
+func (c FileCredentials) Credentials(ctx context.Context) (string, string, error) {
+	user := ""
+	if c.UserFile != "" {
+		b, err := os.ReadFile(c.UserFile)
+		if err != nil {
+			return "", "", err
+		}
+		user = strings.TrimSpace(string(b))
+	}
+	b, err := os.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", "", err
+	}
+	return user, strings.TrimSpace(string(b)), nil
+}
+
+// CredentialFunc adapts a function to a CredentialSource, e.g. to wrap an AWS Secrets Manager client.
+type CredentialFunc func(context.Context) (string, string, error)
+
+func (f CredentialFunc) Credentials(ctx context.Context) (string, string, error) {
+	return f(ctx)
+}
+
+// VaultCredentials reads the user and password from a HashiCorp Vault KV secret at connect time.
+// Path is the API path of the secret, e.g. "secret/data/tlv2" for a KV version 2 engine mounted at "secret".
+// Addr and Token default to the VAULT_ADDR and VAULT_TOKEN environment variables,
+// and UserKey and PasswordKey default to "username" and "password".
+type VaultCredentials struct {
+	Addr        string
+	Token       string
+	Path        string
+	UserKey     string
+	PasswordKey string
+	Client      *http.Client
+}
+
+func (c VaultCredentials) Credentials(ctx context.Context) (string, string, error) {
+	addr, token := c.Addr, c.Token
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	userKey, passwordKey := c.UserKey, c.PasswordKey
+	if userKey == "" {
+		userKey = "username"
+	}
+	if passwordKey == "" {
+		passwordKey = "password"
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(c.Path, "/"), nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("vault secret %s: %s", c.Path, resp.Status)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", "", err
+	}
+	// KV version 2 nests the secret values in data.data
+	values := secret.Data
+	if nested, ok := values["data"].(map[string]interface{}); ok {
+		values = nested
+	}
+	user, _ := values[userKey].(string)
+	password, ok := values[passwordKey].(string)
+	if !ok {
+		return "", "", fmt.Errorf("vault secret %s has no %s value", c.Path, passwordKey)
+	}
+	return user, password, nil
+}
+
+// ConfigureCredentials adds a hook to a pool config that gets credentials from src
+// each time a pool connection is opened, so the url does not need to contain a password.
+// After credentials are rotated, existing connections are replaced with connections
+// using the new credentials as they reach the pool's MaxConnLifetime.
+func ConfigureCredentials(cfg *pgxpool.Config, src CredentialSource) {
+	cfg.BeforeConnect = credentialsHook(cfg.BeforeConnect, src)
+}
+
+func credentialsHook(beforeConnect func(context.Context, *pgx.ConnConfig) error, src CredentialSource) func(context.Context, *pgx.ConnConfig) error {
+	return func(ctx context.Context, connCfg *pgx.ConnConfig) error {
+		if beforeConnect != nil {
+			if err := beforeConnect(ctx, connCfg); err != nil {
+				return err
+			}
+		}
+		user, password, err := src.Credentials(ctx)
+		if err != nil {
+			return fmt.Errorf("could not get database credentials: %w", err)
+		}
+		if user != "" {
+			connCfg.User = user
+		}
+		connCfg.Password = password
+		return nil
+	}
+}
+
+// OpenDBPoolWithCredentials is like OpenDBPool, but gets credentials from src.
+func OpenDBPoolWithCredentials(ctx context.Context, url string, src CredentialSource) (*pgxpool.Pool, *sqlx.DB, error) {
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	ConfigureCredentials(cfg, src)
+	return OpenDBPoolWithConfig(ctx, cfg)
+}
+
+// OpenDBWithCredentials is like OpenDB, but gets credentials from src each time a connection is opened.
+// Rotated credentials are used as existing connections reach their one hour lifetime.
+func OpenDBWithCredentials(url string, src CredentialSource) (*sqlx.DB, error) {
+	cfg, err := pgx.ParseConfig(url)
+	if err != nil {
+		logger(context.Background()).Error().Err(err).Msg("could not open database")
+		return nil, err
+	}
+	db := sqlx.NewDb(stdlib.OpenDB(*cfg, stdlib.OptionBeforeConnect(credentialsHook(nil, src))), "pgx")
+	return configureDB(db)
+}
@@ -0,0 +1,94 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCredentials(t *testing.T) {
+	dir := t.TempDir()
+	userFile := filepath.Join(dir, "user")
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(userFile, []byte("tlv2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	user, password, err := FileCredentials{UserFile: userFile, PasswordFile: passwordFile}.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "tlv2", user)
+	assert.Equal(t, "secret", password)
+	_, _, err = FileCredentials{PasswordFile: filepath.Join(dir, "missing")}.Credentials(context.Background())
+	assert.Error(t, err)
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("TL_TEST_DB_PASSWORD", "secret")
+	user, password, err := EnvCredentials{PasswordVar: "TL_TEST_DB_PASSWORD"}.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "", user)
+	assert.Equal(t, "secret", password)
+	_, _, err = EnvCredentials{PasswordVar: "TL_TEST_DB_PASSWORD_MISSING"}.Credentials(context.Background())
+	assert.Error(t, err)
+}
+
+func TestVaultCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/tlv2":
+			w.Write([]byte(`{"data":{"data":{"username":"tlv2","password":"secret"},"metadata":{"version":2}}}`))
+		case "/v1/kv/tlv2":
+			w.Write([]byte(`{"data":{"user":"tlv2","pass":"secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+	user, password, err := VaultCredentials{Addr: ts.URL, Token: "token", Path: "secret/data/tlv2"}.Credentials(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "tlv2", user)
+	assert.Equal(t, "secret", password)
+
+	t.Setenv("VAULT_ADDR", ts.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+	user, password, err = VaultCredentials{Path: "kv/tlv2", UserKey: "user", PasswordKey: "pass"}.Credentials(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "tlv2", user)
+	assert.Equal(t, "secret", password)
+
+	_, _, err = VaultCredentials{Path: "kv/missing"}.Credentials(ctx)
+	assert.Error(t, err)
+	_, _, err = VaultCredentials{Path: "kv/tlv2"}.Credentials(ctx)
+	assert.Error(t, err)
+}
+
+func TestCredentialsHook(t *testing.T) {
+	calls := 0
+	hook := credentialsHook(nil, CredentialFunc(func(context.Context) (string, string, error) {
+		calls++
+		return "", fmt.Sprintf("secret%d", calls), nil
+	}))
+	cfg, err := pgx.ParseConfig("postgres://tlv2@localhost/tlv2")
+	assert.NoError(t, err)
+	assert.NoError(t, hook(context.Background(), cfg))
+	assert.Equal(t, "tlv2", cfg.User)
+	assert.Equal(t, "secret1", cfg.Password)
+
+	// Rotated credentials are picked up by the next connection
+	assert.NoError(t, hook(context.Background(), cfg))
+	assert.Equal(t, "secret2", cfg.Password)
+}
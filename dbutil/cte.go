@@ -0,0 +1,61 @@
+package dbutil
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// CTEs is a WITH clause of one or more common table expressions.
+type CTEs struct {
+	recursive bool
+	names     []string
+	queries   []sq.Sqlizer
+}
+
+// WithCTE starts a WITH clause. The name may include a column list, e.g. "stops(id, name)".
+// Queries should use the default ? placeholders; SelectBuilders are converted automatically,
+// so placeholders are numbered correctly across the whole statement.
+func WithCTE(name string, q sq.Sqlizer) CTEs {
+	return CTEs{}.WithCTE(name, q)
+}
+
+// WithRecursiveCTE starts a WITH RECURSIVE clause.
+func WithRecursiveCTE(name string, q sq.Sqlizer) CTEs {
+	return CTEs{recursive: true}.WithCTE(name, q)
+}
+
+// WithCTE adds another common table expression, which can refer to earlier ones.
+func (c CTEs) WithCTE(name string, q sq.Sqlizer) CTEs {
+	c.names = append(c.names[:len(c.names):len(c.names)], name)
+	c.queries = append(c.queries[:len(c.queries):len(c.queries)], q)
+	return c
+}
+
+// Select returns q prefixed with the WITH clause.
+func (c CTEs) Select(q sq.SelectBuilder) sq.SelectBuilder {
+	return q.PrefixExpr(c)
+}
+
+// ToSql implements sq.Sqlizer, returning the WITH clause.
+func (c CTEs) ToSql() (string, []interface{}, error) {
+	var parts []string
+	var args []interface{}
+	for i, q := range c.queries {
+		if sb, ok := q.(sq.SelectBuilder); ok {
+			q = sb.PlaceholderFormat(sq.Question)
+		}
+		qstr, qargs, err := q.ToSql()
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, fmt.Sprintf("%s AS (%s)", c.names[i], qstr))
+		args = append(args, qargs...)
+	}
+	kw := "WITH"
+	if c.recursive {
+		kw = "WITH RECURSIVE"
+	}
+	return kw + " " + strings.Join(parts, ", "), args, nil
+}
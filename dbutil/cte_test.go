@@ -0,0 +1,26 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCTE(t *testing.T) {
+	active := sq.Select("id").From("feed_versions").Where("active = ?", true).PlaceholderFormat(sq.Dollar)
+	stops := sq.Select("id", "stop_name").From("gtfs_stops").Where("feed_version_id IN (SELECT id FROM active_fvs)").Where("location_type = ?", 0)
+	q := WithCTE("active_fvs", active).
+		WithCTE("stops", stops).
+		Select(sq.Select("*").From("stops").Where("stop_name = ?", "Main St")).
+		PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "WITH active_fvs AS (SELECT id FROM feed_versions WHERE active = $1), stops AS (SELECT id, stop_name FROM gtfs_stops WHERE feed_version_id IN (SELECT id FROM active_fvs) AND location_type = $2) SELECT * FROM stops WHERE stop_name = $3", qstr)
+	assert.Equal(t, []interface{}{true, 0, "Main St"}, qargs)
+
+	tree := sq.Expr("SELECT id, parent_station FROM gtfs_stops WHERE id = ? UNION ALL SELECT s.id, s.parent_station FROM gtfs_stops s JOIN tree ON s.parent_station = tree.id", 1)
+	qstr, _, err = WithRecursiveCTE("tree(id, parent_station)", tree).Select(sq.Select("id").From("tree")).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "WITH RECURSIVE tree(id, parent_station) AS (SELECT id, parent_station FROM gtfs_stops WHERE id = ? UNION ALL SELECT s.id, s.parent_station FROM gtfs_stops s JOIN tree ON s.parent_station = tree.id) SELECT id FROM tree", qstr)
+}
@@ -0,0 +1,238 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Row actions reported by DataDiff.
+const (
+	RowInserted = "insert"
+	RowUpdated  = "update"
+	RowDeleted  = "delete"
+)
+
+// DiffSource is a query on a database, compared by DataDiff.
+type DiffSource struct {
+	DB    sqlx.Ext
+	Query sq.SelectBuilder
+}
+
+// RowDiff is a row that differs between two result sets. Action is RowInserted for a row
+// only in b, RowDeleted for a row only in a, and RowUpdated for a row with changed columns.
+type RowDiff struct {
+	Key     string                 `json:"key"`
+	Action  string                 `json:"action"`
+	Changes map[string]ValueChange `json:"changes,omitempty"`
+}
+
+// ValueChange is a column value that differs between two rows. Values are decoded from JSON.
+type ValueChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// DataDiffSummary counts the rows compared by DataDiff.
+type DataDiffSummary struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+}
+
+// DataDiff compares the rows of two queries, possibly on different databases, matched on
+// the key column, e.g. a natural key such as stop_id when validating a feed re-import.
+// Columns in ignore, such as surrogate ids, are not compared. Each differing row is passed to cb.
+// Each side is read with a single query ordered by key and streamed row by row, so memory use is
+// bounded. The two sources must not share a transaction, since both queries are open at once.
+// Keys are compared as text, and must be unique and not null within each query.
+// The queries are logged, but do not pass through middleware.
+func DataDiff(ctx context.Context, a DiffSource, b DiffSource, key string, ignore []string, cb func(RowDiff) error) (DataDiffSummary, error) {
+	ra, err := openDiffReader(ctx, a, key)
+	if err != nil {
+		return DataDiffSummary{}, err
+	}
+	defer ra.close()
+	rb, err := openDiffReader(ctx, b, key)
+	if err != nil {
+		return DataDiffSummary{}, err
+	}
+	defer rb.close()
+	return diffRows(ra, rb, ignore, cb)
+}
+
+// diffRows merges two sources of rows in key order.
+func diffRows(ra diffSource, rb diffSource, ignore []string, cb func(RowDiff) error) (DataDiffSummary, error) {
+	var summary DataDiffSummary
+	ignored := map[string]bool{}
+	for _, col := range ignore {
+		ignored[col] = true
+	}
+	for {
+		rowa, err := ra.peek()
+		if err != nil {
+			return summary, err
+		}
+		rowb, err := rb.peek()
+		if err != nil {
+			return summary, err
+		}
+		var d *RowDiff
+		switch {
+		case rowa == nil && rowb == nil:
+			return summary, nil
+		case rowb == nil || (rowa != nil && rowa.Key < rowb.Key):
+			d = &RowDiff{Key: rowa.Key, Action: RowDeleted}
+			summary.Deleted++
+			ra.next()
+		case rowa == nil || rowb.Key < rowa.Key:
+			d = &RowDiff{Key: rowb.Key, Action: RowInserted}
+			summary.Inserted++
+			rb.next()
+		default:
+			changes, err := diffRowValues(rowa.Row, rowb.Row, ignored)
+			if err != nil {
+				return summary, err
+			}
+			if len(changes) > 0 {
+				d = &RowDiff{Key: rowa.Key, Action: RowUpdated, Changes: changes}
+				summary.Updated++
+			} else {
+				summary.Unchanged++
+			}
+			ra.next()
+			rb.next()
+		}
+		if d != nil {
+			if err := cb(*d); err != nil {
+				return summary, err
+			}
+		}
+	}
+}
+
+type diffRow struct {
+	Key string
+	Row string
+}
+
+// diffSource returns rows in increasing key order.
+type diffSource interface {
+	peek() (*diffRow, error)
+	next()
+}
+
+// diffReader streams the rows of a DiffSource query in key order.
+type diffReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	rows   *sqlx.Rows
+	qstr   string
+	qargs  []interface{}
+	start  time.Time
+	row    *diffRow
+	last   *diffRow
+	count  int64
+	err    error
+}
+
+func openDiffReader(ctx context.Context, src DiffSource, key string) (*diffReader, error) {
+	// Compare keys as bytes, so the database orders them as Go compares them
+	q := sq.Select(fmt.Sprintf("(t.%s)::text AS key", QuoteIdentifier(key)), "row_to_json(t)::text AS row").
+		FromSelect(src.Query.PlaceholderFormat(sq.Question), "t").
+		OrderBy(fmt.Sprintf(`(t.%s)::text COLLATE "C"`, QuoteIdentifier(key))).
+		PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	if err == nil {
+		err = checkShutdown(src.DB)
+	}
+	if err == nil {
+		err = checkSchema(ctx, src.DB)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &diffReader{qstr: qstr, qargs: qargs, start: time.Now()}
+	r.ctx, r.cancel = withQueryTimeout(ctx, ReadStatement)
+	if a, ok := src.DB.(sqlx.QueryerContext); ok {
+		r.rows, err = a.QueryxContext(r.ctx, qstr, qargs...)
+	} else {
+		r.rows, err = src.DB.Queryx(qstr, qargs...)
+	}
+	if err != nil {
+		r.cancel()
+		logQuery(ctx, qstr, qargs, r.start, 0, err)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *diffReader) peek() (*diffRow, error) {
+	if r.row != nil || r.err != nil {
+		return r.row, r.err
+	}
+	if !r.rows.Next() {
+		r.err = r.rows.Err()
+		return nil, r.err
+	}
+	row := diffRow{}
+	if err := r.rows.Scan(&row.Key, &row.Row); err != nil {
+		r.err = err
+		return nil, err
+	}
+	if r.last != nil && row.Key <= r.last.Key {
+		r.err = fmt.Errorf("duplicate or unordered key '%s'", row.Key)
+		return nil, r.err
+	}
+	r.count++
+	r.row, r.last = &row, &row
+	return r.row, nil
+}
+
+func (r *diffReader) next() {
+	r.row = nil
+}
+
+func (r *diffReader) close() {
+	r.rows.Close()
+	r.cancel()
+	logQuery(r.ctx, r.qstr, r.qargs, r.start, r.count, r.err)
+}
+
+// diffRowValues returns the columns that differ between two rows encoded as JSON objects.
+func diffRowValues(a string, b string, ignored map[string]bool) (map[string]ValueChange, error) {
+	decode := func(s string) (map[string]interface{}, error) {
+		var ret map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+		dec.UseNumber()
+		err := dec.Decode(&ret)
+		return ret, err
+	}
+	va, err := decode(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]ValueChange{}
+	for col, from := range va {
+		if to := vb[col]; !ignored[col] && !reflect.DeepEqual(from, to) {
+			changes[col] = ValueChange{From: from, To: to}
+		}
+	}
+	for col, to := range vb {
+		if _, ok := va[col]; !ok && !ignored[col] {
+			changes[col] = ValueChange{To: to}
+		}
+	}
+	return changes, nil
+}
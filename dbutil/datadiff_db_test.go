@@ -0,0 +1,56 @@
+package dbutil_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataDiffDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	stops := func(first int, last int, changed int) sq.SelectBuilder {
+		return sq.Select("i AS id", "'s' || i AS stop_id").
+			Column("CASE WHEN i = ? THEN 'changed' ELSE 'stop ' || i END AS stop_name", changed).
+			From(fmt.Sprintf("generate_series(%d, %d) i", first, last))
+	}
+	var diffs []dbutil.RowDiff
+	summary, err := dbutil.DataDiff(
+		ctx,
+		dbutil.DiffSource{DB: db, Query: stops(1, 1999, 0)},
+		dbutil.DiffSource{DB: db, Query: stops(2, 2000, 500)},
+		"stop_id",
+		[]string{"id"},
+		func(d dbutil.RowDiff) error {
+			diffs = append(diffs, d)
+			return nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, dbutil.DataDiffSummary{Inserted: 1, Updated: 1, Deleted: 1, Unchanged: 1997}, summary)
+	assert.Equal(t, []dbutil.RowDiff{
+		{Key: "s1", Action: dbutil.RowDeleted},
+		{Key: "s2000", Action: dbutil.RowInserted},
+		{Key: "s500", Action: dbutil.RowUpdated, Changes: map[string]dbutil.ValueChange{"stop_name": {From: "stop 500", To: "changed"}}},
+	}, diffs)
+
+	// Duplicate keys are an error
+	_, err = dbutil.DataDiff(
+		ctx,
+		dbutil.DiffSource{DB: db, Query: sq.Select("1 AS stop_id").From("generate_series(1, 2)")},
+		dbutil.DiffSource{DB: db, Query: sq.Select("1 AS stop_id")},
+		"stop_id",
+		nil,
+		func(dbutil.RowDiff) error { return nil },
+	)
+	assert.Error(t, err)
+}
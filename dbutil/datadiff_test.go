@@ -0,0 +1,45 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDiffSource []diffRow
+
+func (s *testDiffSource) peek() (*diffRow, error) {
+	if len(*s) == 0 {
+		return nil, nil
+	}
+	return &(*s)[0], nil
+}
+
+func (s *testDiffSource) next() {
+	*s = (*s)[1:]
+}
+
+func TestDiffRows(t *testing.T) {
+	a := &testDiffSource{
+		{Key: "a", Row: `{"id":1,"stop_id":"a","stop_name":"A"}`},
+		{Key: "b", Row: `{"id":2,"stop_id":"b","stop_name":"B"}`},
+		{Key: "c", Row: `{"id":3,"stop_id":"c","stop_name":"C"}`},
+	}
+	b := &testDiffSource{
+		{Key: "b", Row: `{"id":12,"stop_id":"b","stop_name":"B"}`},
+		{Key: "c", Row: `{"id":13,"stop_id":"c","stop_name":"C2"}`},
+		{Key: "d", Row: `{"id":14,"stop_id":"d","stop_name":"D"}`},
+	}
+	var diffs []RowDiff
+	summary, err := diffRows(a, b, []string{"id"}, func(d RowDiff) error {
+		diffs = append(diffs, d)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, DataDiffSummary{Inserted: 1, Updated: 1, Deleted: 1, Unchanged: 1}, summary)
+	assert.Equal(t, []RowDiff{
+		{Key: "a", Action: RowDeleted},
+		{Key: "c", Action: RowUpdated, Changes: map[string]ValueChange{"stop_name": {From: "C", To: "C2"}}},
+		{Key: "d", Action: RowInserted},
+	}, diffs)
+}
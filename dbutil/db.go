@@ -2,18 +2,20 @@ package dbutil
 
 import (
 	"context"
+	"database/sql"
+	"reflect"
 	"regexp"
 	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 
-	"github.com/interline-io/log"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/rs/zerolog"
 )
 
 var matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
@@ -26,7 +28,16 @@ func toSnakeCase(str string) string {
 }
 
 func OpenDBPool(ctx context.Context, url string) (*pgxpool.Pool, *sqlx.DB, error) {
-	pool, err := pgxpool.New(ctx, url)
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	return OpenDBPoolWithConfig(ctx, cfg)
+}
+
+// OpenDBPoolWithConfig is like OpenDBPool, but accepts a pool config, e.g. with hooks from ConfigureEvents.
+func OpenDBPoolWithConfig(ctx context.Context, cfg *pgxpool.Config) (*pgxpool.Pool, *sqlx.DB, error) {
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -35,7 +46,7 @@ func OpenDBPool(ctx context.Context, url string) (*pgxpool.Pool, *sqlx.DB, error
 	db.SetMaxIdleConns(10)
 	db.SetConnMaxLifetime(time.Hour)
 	if err := db.Ping(); err != nil {
-		log.Error().Err(err).Msgf("could not connect to database")
+		logger(ctx).Error().Err(err).Msgf("could not connect to database")
 		return nil, nil, err
 	}
 	db.Mapper = reflectx.NewMapperFunc("db", toSnakeCase)
@@ -45,14 +56,18 @@ func OpenDBPool(ctx context.Context, url string) (*pgxpool.Pool, *sqlx.DB, error
 func OpenDB(url string) (*sqlx.DB, error) {
 	db, err := sqlx.Open("pgx", url)
 	if err != nil {
-		log.Error().Err(err).Msg("could not open database")
+		logger(context.Background()).Error().Err(err).Msg("could not open database")
 		return nil, err
 	}
+	return configureDB(db)
+}
+
+func configureDB(db *sqlx.DB) (*sqlx.DB, error) {
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(10)
 	db.SetConnMaxLifetime(time.Hour)
 	if err := db.Ping(); err != nil {
-		log.Error().Err(err).Msgf("could not connect to database")
+		logger(context.Background()).Error().Err(err).Msgf("could not connect to database")
 		return nil, err
 	}
 	db.Mapper = reflectx.NewMapperFunc("db", toSnakeCase)
@@ -61,54 +76,199 @@ func OpenDB(url string) (*sqlx.DB, error) {
 
 // Select runs a query and reads results into dest.
 func Select(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	t := time.Now()
 	useStatement := false
+	ctx, cancel := withQueryTimeout(ctx, ReadStatement)
+	defer cancel()
+	q = scopeDeleted(ctx, q, dest)
 	q = q.PlaceholderFormat(sq.Dollar)
 	qstr, qargs, err := q.ToSql()
 	if err == nil {
-		if a, ok := db.(sqlx.PreparerContext); ok && useStatement {
-			stmt, prepareErr := sqlx.PreparexContext(ctx, a, qstr)
-			if prepareErr != nil {
-				err = prepareErr
+		err = checkBindParams(qargs)
+	}
+	if err == nil {
+		err = checkShutdown(db)
+	}
+	if err == nil {
+		err = checkSchema(ctx, db)
+	}
+	if err == nil {
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		err = runMiddleware(ctx, &QueryInfo{Query: qstr, Args: qargs, Dest: dest}, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.PreparerContext); ok && useStatement {
+				stmt, prepareErr := sqlx.PreparexContext(ctx, a, qstr)
+				if prepareErr != nil {
+					err = prepareErr
+				} else {
+					err = stmt.SelectContext(ctx, dest, qargs...)
+				}
+			} else if a, ok := db.(sqlx.QueryerContext); ok {
+				err = sqlx.SelectContext(ctx, a, dest, qstr, qargs...)
 			} else {
-				err = stmt.SelectContext(ctx, dest, qargs...)
+				err = sqlx.Select(db, dest, qstr, qargs...)
 			}
-		} else if a, ok := db.(sqlx.QueryerContext); ok {
-			err = sqlx.SelectContext(ctx, a, dest, qstr, qargs...)
-		} else {
-			err = sqlx.Select(db, dest, qstr, qargs...)
-		}
-	}
-	if ctx.Err() == context.Canceled {
-		log.Trace().Err(err).Str("query", qstr).Interface("args", qargs).Msg("query canceled")
-	} else if err != nil {
-		log.Error().Err(err).Str("query", qstr).Interface("args", qargs).Msg("query failed")
+			return err
+		})
 	}
+	logQuery(ctx, qstr, qargs, t, sliceLen(dest), err)
 	return err
 }
 
-// Select runs a query and reads results into dest.
+// Get runs a query and reads a single result into dest.
 func Get(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	t := time.Now()
 	useStatement := false
+	ctx, cancel := withQueryTimeout(ctx, ReadStatement)
+	defer cancel()
+	q = scopeDeleted(ctx, q, dest)
 	q = q.PlaceholderFormat(sq.Dollar)
 	qstr, qargs, err := q.ToSql()
 	if err == nil {
-		if a, ok := db.(sqlx.PreparerContext); ok && useStatement {
-			stmt, prepareErr := sqlx.PreparexContext(ctx, a, qstr)
-			if prepareErr != nil {
-				err = prepareErr
+		err = checkBindParams(qargs)
+	}
+	if err == nil {
+		err = checkShutdown(db)
+	}
+	if err == nil {
+		err = checkSchema(ctx, db)
+	}
+	if err == nil {
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		err = runMiddleware(ctx, &QueryInfo{Query: qstr, Args: qargs, Dest: dest}, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.PreparerContext); ok && useStatement {
+				stmt, prepareErr := sqlx.PreparexContext(ctx, a, qstr)
+				if prepareErr != nil {
+					err = prepareErr
+				} else {
+					err = stmt.GetContext(ctx, dest, qargs...)
+				}
+			} else if a, ok := db.(sqlx.QueryerContext); ok {
+				err = sqlx.GetContext(ctx, a, dest, qstr, qargs...)
+			} else {
+				err = sqlx.Get(db, dest, qstr, qargs...)
+			}
+			return err
+		})
+	}
+	rows := int64(0)
+	if err == nil {
+		rows = 1
+	}
+	logQuery(ctx, qstr, qargs, t, rows, err)
+	return err
+}
+
+// SelectT runs a query and returns the results as a slice of T.
+func SelectT[T any](ctx context.Context, db sqlx.Ext, q sq.SelectBuilder) ([]T, error) {
+	var ret []T
+	err := Select(ctx, db, q, &ret)
+	return ret, err
+}
+
+// GetT runs a query and returns a single result as T.
+func GetT[T any](ctx context.Context, db sqlx.Ext, q sq.SelectBuilder) (T, error) {
+	var ret T
+	err := Get(ctx, db, q, &ret)
+	return ret, err
+}
+
+// RawSQL is a statement without bind parameters, e.g. DDL with quoted identifiers or literals.
+// Exec runs it without replacing ? placeholders, so identifiers, literals, and operators containing ? are preserved.
+type RawSQL string
+
+func (s RawSQL) ToSql() (string, []interface{}, error) {
+	return string(s), nil, nil
+}
+
+// Exec runs a statement and returns the result.
+func Exec(ctx context.Context, db sqlx.Ext, q sq.Sqlizer) (sql.Result, error) {
+	t := time.Now()
+	var res sql.Result
+	qstr, qargs, err := q.ToSql()
+	if err == nil {
+		err = checkBindParams(qargs)
+	}
+	if err == nil {
+		err = checkShutdown(db)
+	}
+	if err == nil {
+		err = checkSchema(ctx, db)
+	}
+	if _, raw := q.(RawSQL); err == nil && !raw {
+		qstr, err = sq.Dollar.ReplacePlaceholders(qstr)
+	}
+	if err == nil {
+		err = checkDDL(ctx, qstr)
+	}
+	if err == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = withQueryTimeout(ctx, ClassifyStatement(qstr))
+		defer cancel()
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		qi := &QueryInfo{Query: qstr, Args: qargs}
+		err = runMiddleware(ctx, qi, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.ExecerContext); ok {
+				qi.Result, err = a.ExecContext(ctx, qstr, qargs...)
 			} else {
-				err = stmt.GetContext(ctx, dest, qargs...)
+				qi.Result, err = db.Exec(qstr, qargs...)
 			}
-		} else if a, ok := db.(sqlx.QueryerContext); ok {
-			err = sqlx.GetContext(ctx, a, dest, qstr, qargs...)
-		} else {
-			err = sqlx.Get(db, dest, qstr, qargs...)
-		}
+			return err
+		})
+		res = qi.Result
+	}
+	rows := int64(0)
+	if res != nil {
+		rows, _ = res.RowsAffected()
+	}
+	logQuery(ctx, qstr, qargs, t, rows, err)
+	return res, err
+}
+
+// logQuery logs queries according to the QueryLogOptions, with fields from WithLogFields,
+// records them in the context journal, if any, and adds them to query statistics, if enabled.
+func logQuery(ctx context.Context, qstr string, qargs []interface{}, t time.Time, rows int64, err error) {
+	d := time.Since(t)
+	if j := journalFromContext(ctx); j != nil {
+		j.record(qstr, d, err)
 	}
+	if s := getQueryStats(); s != nil {
+		s.Record(qstr, d, rows, err)
+	}
+	opts := getQueryLogOptions()
+	var e *zerolog.Event
+	var msg string
 	if ctx.Err() == context.Canceled {
-		log.Trace().Err(err).Str("query", qstr).Interface("args", qargs).Msg("query canceled")
+		e, msg = logger(ctx).Trace().Err(err), "query canceled"
 	} else if err != nil {
-		log.Error().Err(err).Str("query", qstr).Interface("args", qargs).Msg("query failed")
+		e, msg = logger(ctx).Error().Err(err), "query failed"
+	} else if opts.slow(d) {
+		e, msg = logger(ctx).Info(), "slow query"
+	} else if opts.sampled() {
+		e, msg = logger(ctx).Trace(), "query"
+	} else {
+		return
 	}
-	return err
+	e.Fields(logFieldsFromContext(ctx)).
+		Str("query", qstr).
+		Interface("args", opts.logArgs(qargs)).
+		Dur("duration", d).
+		Int64("rows", rows).
+		Msg(msg)
+}
+
+func sliceLen(dest interface{}) int64 {
+	v := reflect.ValueOf(dest)
+	if v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Slice {
+		return int64(v.Elem().Len())
+	}
+	return 0
 }
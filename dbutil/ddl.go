@@ -0,0 +1,73 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ErrDDLNotAllowed is returned when a DDL statement is run without AllowDDL while RequireAllowDDL is set.
+var ErrDDLNotAllowed = errors.New("DDL statement not allowed; use AllowDDL to permit schema changes")
+
+// DDLPolicy controls auditing of DDL statements (CREATE, ALTER, DROP, etc.) run through Exec.
+type DDLPolicy struct {
+	// Audit logs every DDL statement at warn level with the caller location.
+	Audit bool
+	// RequireAllowDDL rejects DDL statements unless the context was created with AllowDDL, e.g. in production.
+	RequireAllowDDL bool
+}
+
+var ddlPolicy DDLPolicy
+var ddlPolicyLock sync.RWMutex
+
+// SetDDLPolicy sets the DDL policy used by Exec.
+func SetDDLPolicy(p DDLPolicy) {
+	ddlPolicyLock.Lock()
+	defer ddlPolicyLock.Unlock()
+	ddlPolicy = p
+}
+
+type allowDDLContextKey struct{}
+
+// AllowDDL returns a context that permits DDL statements when RequireAllowDDL is set, e.g. for migrations.
+func AllowDDL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allowDDLContextKey{}, true)
+}
+
+// checkDDL audits and enforces the DDL policy for a statement.
+func checkDDL(ctx context.Context, qstr string) error {
+	if ClassifyStatement(qstr) != DDLStatement {
+		return nil
+	}
+	ddlPolicyLock.RLock()
+	p := ddlPolicy
+	ddlPolicyLock.RUnlock()
+	allowed, _ := ctx.Value(allowDDLContextKey{}).(bool)
+	if p.Audit {
+		logger(ctx).Warn().Str("query", qstr).Str("caller", callerLocation()).Bool("allowed", allowed || !p.RequireAllowDDL).Msg("DDL statement")
+	}
+	if p.RequireAllowDDL && !allowed {
+		return ErrDDLNotAllowed
+	}
+	return nil
+}
+
+// callerLocation returns the file and line of the first caller outside this package.
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	pkg := "github.com/interline-io/transitland-dbutil/dbutil."
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkg) || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DeferredIndexes are the secondary indexes and foreign keys of a table, captured so they can be
+// dropped before a bulk load and rebuilt afterwards. Primary keys, unique indexes, and unique and
+// exclusion constraints are kept, since other tables may reference them and they protect the loaded data.
+type DeferredIndexes struct {
+	Schema      string               `json:"schema"`
+	Table       string               `json:"table"`
+	Indexes     []IndexSnapshot      `json:"indexes"`
+	ForeignKeys []ConstraintSnapshot `json:"foreign_keys"`
+}
+
+// CaptureIndexes returns the secondary indexes and foreign keys of a table, e.g. "public.gtfs_stop_times".
+func CaptureIndexes(ctx context.Context, db sqlx.Ext, table string) (*DeferredIndexes, error) {
+	schema, name := "public", table
+	if i := strings.Index(table, "."); i >= 0 {
+		schema, name = table[:i], table[i+1:]
+	}
+	snapshot, err := SnapshotSchema(ctx, db, schema)
+	if err != nil {
+		return nil, err
+	}
+	t := snapshot.Table(schema, name)
+	if t == nil {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+	return &DeferredIndexes{Schema: schema, Table: name, Indexes: deferrableIndexes(t), ForeignKeys: t.ForeignKeys()}, nil
+}
+
+// deferrableIndexes returns the indexes of a table that are neither unique nor created by constraints.
+func deferrableIndexes(t *TableSnapshot) []IndexSnapshot {
+	var ret []IndexSnapshot
+	for _, idx := range ownIndexes(t) {
+		if !idx.Unique {
+			ret = append(ret, idx)
+		}
+	}
+	return ret
+}
+
+func (d *DeferredIndexes) qualifiedTable() string {
+	return QuoteIdentifier(d.Schema) + "." + QuoteIdentifier(d.Table)
+}
+
+func (d *DeferredIndexes) dropStatements() []string {
+	var ret []string
+	for _, fk := range d.ForeignKeys {
+		ret = append(ret, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.qualifiedTable(), QuoteIdentifier(fk.Name)))
+	}
+	for _, idx := range d.Indexes {
+		ret = append(ret, fmt.Sprintf("DROP INDEX %s.%s", QuoteIdentifier(d.Schema), QuoteIdentifier(idx.Name)))
+	}
+	return ret
+}
+
+// createStatements returns the statements to rebuild the indexes and foreign keys.
+// Concurrent rebuilds use CREATE INDEX CONCURRENTLY, and add foreign keys as NOT VALID
+// before validating them, so writes are not blocked while existing rows are checked.
+func (d *DeferredIndexes) createStatements(concurrently bool) []string {
+	var ret []string
+	for _, idx := range d.Indexes {
+		def := idx.Definition
+		if concurrently {
+			def = strings.Replace(def, " INDEX ", " INDEX CONCURRENTLY ", 1)
+		}
+		ret = append(ret, def)
+	}
+	for _, fk := range d.ForeignKeys {
+		if concurrently {
+			ret = append(ret,
+				fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s NOT VALID", d.qualifiedTable(), QuoteIdentifier(fk.Name), fk.Definition),
+				fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", d.qualifiedTable(), QuoteIdentifier(fk.Name)),
+			)
+		} else {
+			ret = append(ret, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", d.qualifiedTable(), QuoteIdentifier(fk.Name), fk.Definition))
+		}
+	}
+	return ret
+}
+
+// Drop drops the captured indexes and foreign keys.
+// Statements are run as is, so definitions using the jsonb ? operator are not rewritten.
+func (d *DeferredIndexes) Drop(ctx context.Context, db sqlx.Ext) error {
+	for _, stmt := range d.dropStatements() {
+		if _, err := Exec(ctx, db, RawSQL(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild recreates the captured indexes and foreign keys that do not exist, continuing past
+// failures so as much as possible is restored; the first error is returned and all are logged.
+// Concurrent rebuilds can not run inside a transaction.
+func (d *DeferredIndexes) Rebuild(ctx context.Context, db sqlx.Ext, concurrently bool) error {
+	current, err := CaptureIndexes(ctx, db, d.Schema+"."+d.Table)
+	if err != nil {
+		return err
+	}
+	missing := &DeferredIndexes{Schema: d.Schema, Table: d.Table}
+	for _, idx := range d.Indexes {
+		if !hasIndex(current.Indexes, idx.Name) {
+			missing.Indexes = append(missing.Indexes, idx)
+		}
+	}
+	for _, fk := range d.ForeignKeys {
+		if !hasConstraint(current.ForeignKeys, fk.Name) {
+			missing.ForeignKeys = append(missing.ForeignKeys, fk)
+		}
+	}
+	var firstErr error
+	for _, stmt := range missing.createStatements(concurrently) {
+		if _, err := Exec(ctx, db, RawSQL(stmt)); err != nil {
+			logger(ctx).Error().Err(err).Str("query", stmt).Msg("could not rebuild index or constraint")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// WithDeferredIndexes drops the secondary indexes and foreign keys of table, runs load, and
+// rebuilds them, even if load fails. The captured definitions are logged before dropping,
+// so they can be restored by hand if the process exits during the load.
+// DDL statements are run through Exec, so the context may need AllowDDL.
+func WithDeferredIndexes(ctx context.Context, db sqlx.Ext, table string, concurrently bool, load func(context.Context) error) error {
+	d, err := CaptureIndexes(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	logger(ctx).Info().Str("table", table).Strs("definitions", d.createStatements(false)).Msg("dropping indexes for bulk load")
+	loadErr := d.Drop(ctx, db)
+	if loadErr == nil {
+		loadErr = load(ctx)
+	}
+	// Rebuild even if the context was canceled during the load
+	rebuildErr := d.Rebuild(context.WithoutCancel(ctx), db, concurrently)
+	if loadErr != nil {
+		return loadErr
+	}
+	return rebuildErr
+}
+
+func hasIndex(indexes []IndexSnapshot, name string) bool {
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasConstraint(constraints []ConstraintSnapshot, name string) bool {
+	for _, c := range constraints {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
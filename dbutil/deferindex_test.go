@@ -0,0 +1,60 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferredIndexesStatements(t *testing.T) {
+	d := &DeferredIndexes{
+		Schema:      "public",
+		Table:       "gtfs_stop_times",
+		Indexes:     []IndexSnapshot{{Name: "gtfs_stop_times_trip_id", Definition: "CREATE INDEX gtfs_stop_times_trip_id ON public.gtfs_stop_times USING btree (trip_id)"}},
+		ForeignKeys: []ConstraintSnapshot{{Name: "gtfs_stop_times_trip_id_fkey", Type: "f", Definition: "FOREIGN KEY (trip_id) REFERENCES gtfs_trips(id)"}},
+	}
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."gtfs_stop_times" DROP CONSTRAINT "gtfs_stop_times_trip_id_fkey"`,
+		`DROP INDEX "public"."gtfs_stop_times_trip_id"`,
+	}, d.dropStatements())
+	assert.Equal(t, []string{
+		"CREATE INDEX gtfs_stop_times_trip_id ON public.gtfs_stop_times USING btree (trip_id)",
+		`ALTER TABLE "public"."gtfs_stop_times" ADD CONSTRAINT "gtfs_stop_times_trip_id_fkey" FOREIGN KEY (trip_id) REFERENCES gtfs_trips(id)`,
+	}, d.createStatements(false))
+	assert.Equal(t, []string{
+		"CREATE INDEX CONCURRENTLY gtfs_stop_times_trip_id ON public.gtfs_stop_times USING btree (trip_id)",
+		`ALTER TABLE "public"."gtfs_stop_times" ADD CONSTRAINT "gtfs_stop_times_trip_id_fkey" FOREIGN KEY (trip_id) REFERENCES gtfs_trips(id) NOT VALID`,
+		`ALTER TABLE "public"."gtfs_stop_times" VALIDATE CONSTRAINT "gtfs_stop_times_trip_id_fkey"`,
+	}, d.createStatements(true))
+}
+
+func TestDeferrableIndexes(t *testing.T) {
+	tbl := &TableSnapshot{
+		Indexes: []IndexSnapshot{
+			{Name: "gtfs_stops_pkey", Primary: true, Unique: true},
+			{Name: "gtfs_stops_stop_id_key", Unique: true},
+			{Name: "gtfs_stops_code_idx", Unique: true},
+			{Name: "gtfs_stops_name_idx"},
+			{Name: "gtfs_stops_tags_idx", Definition: "CREATE INDEX gtfs_stops_tags_idx ON public.gtfs_stops USING btree (id) WHERE (tags ? 'accessible'::text)"},
+		},
+		Constraints: []ConstraintSnapshot{
+			{Name: "gtfs_stops_pkey", Type: "p"},
+			{Name: "gtfs_stops_stop_id_key", Type: "u"},
+		},
+	}
+	idxs := deferrableIndexes(tbl)
+	var names []string
+	for _, idx := range idxs {
+		names = append(names, idx.Name)
+	}
+	assert.Equal(t, []string{"gtfs_stops_name_idx", "gtfs_stops_tags_idx"}, names)
+
+	// Definitions are run without placeholder rewriting
+	ctx, dr := WithDryRun(context.Background())
+	_, err := Exec(ctx, nil, RawSQL(idxs[1].Definition))
+	assert.NoError(t, err)
+	if stmts := dr.Statements(); assert.Len(t, stmts, 1) {
+		assert.Equal(t, idxs[1].Definition, stmts[0].Query)
+	}
+}
@@ -0,0 +1,44 @@
+package dbutil
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// DeleteByIDs deletes rows from table in chunks of at most chunkSize ids,
+// optionally sleeping between chunks to limit lock duration on large purges.
+// Progress is reported in ids to any WithProgress hook. Returns the total number of rows deleted.
+func DeleteByIDs(ctx context.Context, db sqlx.Ext, table string, ids []int, chunkSize int, sleep time.Duration) (int64, error) {
+	if chunkSize <= 0 {
+		chunkSize = len(ids)
+	}
+	total := int64(0)
+	for i := 0; i < len(ids); i += chunkSize {
+		if i > 0 && sleep > 0 {
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(sleep):
+			}
+		}
+		end := i + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		q := sq.Delete(QuoteIdentifier(table)).Where("id = ANY(?)", ids[i:end])
+		res, err := Exec(ctx, db, q)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		reportProgress(ctx, end, len(ids))
+	}
+	return total, nil
+}
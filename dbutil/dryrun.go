@@ -0,0 +1,81 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+)
+
+type dryRunContextKey struct{}
+
+// DryRun records the write statements that would have run in a dry run context.
+type DryRun struct {
+	statements []QueryInfo
+	tables     map[string]int
+	lock       sync.Mutex
+}
+
+// WithDryRun returns a context in which Exec, ExecNamed, InsertReturning, and other
+// write statements, including WITH queries containing writes and COPY imports,
+// are recorded in the returned DryRun instead of being run.
+// Reads run normally, so they do not see the effect of skipped writes.
+// InsertReturning leaves its destination unchanged.
+func WithDryRun(ctx context.Context) (context.Context, *DryRun) {
+	d := &DryRun{tables: map[string]int{}}
+	return context.WithValue(ctx, dryRunContextKey{}, d), d
+}
+
+func dryRunFromContext(ctx context.Context) *DryRun {
+	d, _ := ctx.Value(dryRunContextKey{}).(*DryRun)
+	return d
+}
+
+// Statements returns the skipped statements, in order.
+func (d *DryRun) Statements() []QueryInfo {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]QueryInfo{}, d.statements...)
+}
+
+// Tables returns the number of skipped statements for each table.
+func (d *DryRun) Tables() map[string]int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ret := map[string]int{}
+	for k, v := range d.tables {
+		ret[k] = v
+	}
+	return ret
+}
+
+func (d *DryRun) record(qi *QueryInfo) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.statements = append(d.statements, QueryInfo{Query: strings.Join(strings.Fields(qi.Query), " "), Args: qi.Args})
+	for _, table := range queryTables(qi.Query) {
+		d.tables[table]++
+	}
+}
+
+// dryRunCopy records and skips a COPY into table if ctx is a dry run context.
+// COPY helpers use pgx directly, so they do not pass through runMiddleware.
+func dryRunCopy(ctx context.Context, table string, cols []string) bool {
+	d := dryRunFromContext(ctx)
+	if d == nil {
+		return false
+	}
+	d.record(&QueryInfo{Query: copyFromStatement(table, cols)})
+	return true
+}
+
+// dryRunSkip records and skips a write statement if ctx is a dry run context.
+func dryRunSkip(ctx context.Context, qi *QueryInfo) bool {
+	d := dryRunFromContext(ctx)
+	if d == nil || ClassifyStatement(qi.Query) == ReadStatement {
+		return false
+	}
+	d.record(qi)
+	qi.Result = driver.RowsAffected(0)
+	return true
+}
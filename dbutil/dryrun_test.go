@@ -0,0 +1,74 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDryRun(t *testing.T) {
+	ctx, d := WithDryRun(context.Background())
+	executed := 0
+	exec := func(ctx context.Context, qi *QueryInfo) error {
+		executed++
+		return nil
+	}
+	qi := &QueryInfo{Query: "DELETE FROM  gtfs_stops WHERE feed_version_id = $1", Args: []interface{}{1}}
+	assert.NoError(t, runMiddleware(ctx, qi, exec))
+	if assert.NotNil(t, qi.Result) {
+		n, _ := qi.Result.RowsAffected()
+		assert.Equal(t, int64(0), n)
+	}
+	assert.NoError(t, runMiddleware(ctx, &QueryInfo{Query: "SELECT * FROM gtfs_stops"}, exec))
+	assert.Equal(t, 1, executed)
+	if assert.Equal(t, 1, len(d.Statements())) {
+		assert.Equal(t, "DELETE FROM gtfs_stops WHERE feed_version_id = $1", d.Statements()[0].Query)
+	}
+	assert.Equal(t, map[string]int{"gtfs_stops": 1}, d.Tables())
+}
+
+func TestDryRunBackfill(t *testing.T) {
+	// Answer the bounds and batch queries; the batch update should be recorded and skipped
+	upper := int64(10)
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			switch dest := qi.Dest.(type) {
+			case *struct {
+				MinID int64 `db:"min_id"`
+				MaxID int64 `db:"max_id"`
+			}:
+				dest.MinID, dest.MaxID = 1, 10
+				return nil
+			case **int64:
+				// One batch, from id 1 through 10
+				if qi.Args[0] != upper {
+					*dest = &upper
+				}
+				return nil
+			case nil:
+				return next(ctx, qi)
+			}
+			t.Fatalf("unexpected query: %s", qi.Query)
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx, d := WithDryRun(context.Background())
+	n, err := Backfill(ctx, nil, "gtfs_stops", "stop_name = upper(stop_name)", nil, 100, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	if stmts := d.Statements(); assert.Len(t, stmts, 1) {
+		assert.Equal(t, `UPDATE "gtfs_stops" SET stop_name = upper(stop_name) WHERE (id > $1 AND id <= $2)`, stmts[0].Query)
+		assert.Equal(t, []interface{}{int64(0), int64(10)}, stmts[0].Args)
+	}
+}
+
+func TestDryRunCopyImport(t *testing.T) {
+	ctx, d := WithDryRun(context.Background())
+	_, err := CopyImport(ctx, nil, 1, []CopySource{
+		{Table: "public.gtfs_stops", Columns: []string{"id", "stop_name"}},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []QueryInfo{{Query: `COPY "public"."gtfs_stops" ("id", "stop_name") FROM STDIN`}}, d.Statements())
+}
@@ -0,0 +1,164 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// DumpHeader starts the section of a dump holding rows of one table.
+type DumpHeader struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+}
+
+// dumpLine is one line of a dump: a section header or a row, encoded as a JSON object.
+type dumpLine struct {
+	Header *DumpHeader     `json:"header,omitempty"`
+	Row    json.RawMessage `json:"row,omitempty"`
+}
+
+// RestoreDumpBatchSize is the number of rows inserted per statement by RestoreDump.
+var RestoreDumpBatchSize = 1000
+
+// DumpQuery writes the rows of q to w as a section for table, in a self-describing
+// JSON lines format that RestoreDump can load into another database. Several queries,
+// e.g. one feed version across related tables, can be dumped to the same writer,
+// parents before children so foreign keys are satisfied on restore.
+// Rows are encoded with row_to_json, so the columns of q should match those of table.
+// The result set is read into memory, so very large tables should be dumped in several queries.
+// Returns the number of rows written.
+func DumpQuery(ctx context.Context, db sqlx.Ext, table string, q sq.SelectBuilder, w io.Writer) (int, error) {
+	return dumpQuery(ctx, db, table, q, w, nil)
+}
+
+// dumpQuery writes the rows of q to w, passing each row through transform if it is not nil.
+func dumpQuery(ctx context.Context, db sqlx.Ext, table string, q sq.SelectBuilder, w io.Writer, transform func(json.RawMessage) (json.RawMessage, error)) (int, error) {
+	var rows []string
+	if err := Select(ctx, db, sq.Select("row_to_json(t)::text").FromSelect(q.PlaceholderFormat(sq.Question), "t"), &rows); err != nil {
+		return 0, err
+	}
+	header := &DumpHeader{Table: table}
+	if len(rows) > 0 {
+		cols, err := jsonKeys([]byte(rows[0]))
+		if err != nil {
+			return 0, err
+		}
+		header.Columns = cols
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(dumpLine{Header: header}); err != nil {
+		return 0, err
+	}
+	for i, row := range rows {
+		line := dumpLine{Row: json.RawMessage(row)}
+		if transform != nil {
+			var err error
+			if line.Row, err = transform(line.Row); err != nil {
+				return i, err
+			}
+		}
+		if err := enc.Encode(line); err != nil {
+			return i, err
+		}
+	}
+	return len(rows), nil
+}
+
+// RestoreDump loads a dump written by DumpQuery, inserting rows in batches of RestoreDumpBatchSize.
+// Values are converted to column types by json_populate_recordset, so tables must exist
+// with compatible columns. Pass a transaction to restore all sections or none.
+// Returns the number of rows inserted.
+func RestoreDump(ctx context.Context, db sqlx.Ext, r io.Reader) (int64, error) {
+	dec := json.NewDecoder(r)
+	var header *DumpHeader
+	var batch []json.RawMessage
+	total := int64(0)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := restoreRows(ctx, db, header, batch)
+		total += n
+		batch = nil
+		return err
+	}
+	for {
+		var line dumpLine
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return total, err
+		}
+		if line.Header != nil {
+			if err := flush(); err != nil {
+				return total, err
+			}
+			header = line.Header
+			continue
+		}
+		if header == nil {
+			return total, errors.New("dump row before table header")
+		}
+		batch = append(batch, line.Row)
+		if len(batch) >= RestoreDumpBatchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, flush()
+}
+
+func restoreRows(ctx context.Context, db sqlx.Ext, header *DumpHeader, rows []json.RawMessage) (int64, error) {
+	var quoted []string
+	for _, col := range header.Columns {
+		quoted = append(quoted, QuoteIdentifier(col))
+	}
+	cols := strings.Join(quoted, ", ")
+	table := QuoteIdentifier(header.Table)
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.Write(row)
+	}
+	buf.WriteString("]")
+	q := sq.Expr(
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, ?::json)", table, cols, cols, table),
+		buf.String(),
+	)
+	return execRowsAffected(ctx, db, q)
+}
+
+// jsonKeys returns the keys of a JSON object in order.
+func jsonKeys(b []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("expected JSON object")
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
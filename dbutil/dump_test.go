@@ -0,0 +1,36 @@
+package dbutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreDump(t *testing.T) {
+	dump := `{"header":{"table":"gtfs_stops","columns":["id","stop_name"]}}
+{"row":{"id":1,"stop_name":"Main St"}}
+{"row":{"id":2,"stop_name":"1st Ave"}}
+{"header":{"table":"gtfs_stop_times","columns":["stop_id"]}}
+{"row":{"stop_id":1}}
+`
+	ctx, d := WithDryRun(context.Background())
+	_, err := RestoreDump(ctx, nil, strings.NewReader(dump))
+	assert.NoError(t, err)
+	stmts := d.Statements()
+	if assert.Equal(t, 2, len(stmts)) {
+		assert.Equal(t, `INSERT INTO "gtfs_stops" ("id", "stop_name") SELECT "id", "stop_name" FROM json_populate_recordset(NULL::"gtfs_stops", $1::json)`, stmts[0].Query)
+		assert.Equal(t, []interface{}{`[{"id":1,"stop_name":"Main St"},{"id":2,"stop_name":"1st Ave"}]`}, stmts[0].Args)
+		assert.Equal(t, []interface{}{`[{"stop_id":1}]`}, stmts[1].Args)
+	}
+
+	_, err = RestoreDump(ctx, nil, strings.NewReader(`{"row":{"id":1}}`))
+	assert.Error(t, err)
+}
+
+func Test_jsonKeys(t *testing.T) {
+	keys, err := jsonKeys([]byte(`{"id":1,"stop_name":"Main St","geom":{"type":"Point"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "stop_name", "geom"}, keys)
+}
@@ -0,0 +1,66 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// DuplicateGroup is a set of rows sharing the same values. Values are in the order
+// of the columns passed to FindDuplicates, as text; IDs are in ascending order.
+type DuplicateGroup struct {
+	Values []string `json:"values"`
+	Count  int      `json:"count"`
+	IDs    []int    `json:"ids"`
+}
+
+// FindDuplicates returns groups of two or more rows of table with equal values in cols,
+// e.g. stops or operators to review and merge, largest groups first.
+// Rows with NULL in any of cols are not compared, matching unique constraint semantics.
+func FindDuplicates(ctx context.Context, db sqlx.Ext, table string, cols []string) ([]DuplicateGroup, error) {
+	q, err := findDuplicatesQuery(table, cols)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Values Array[string] `db:"values"`
+		Count  int           `db:"count"`
+		IDs    Array[int]    `db:"ids"`
+	}
+	if err := Select(ctx, db, q, &rows); err != nil {
+		return nil, err
+	}
+	var ret []DuplicateGroup
+	for _, row := range rows {
+		ret = append(ret, DuplicateGroup{Values: row.Values.Val, Count: row.Count, IDs: row.IDs.Val})
+	}
+	return ret, nil
+}
+
+func findDuplicatesQuery(table string, cols []string) (sq.SelectBuilder, error) {
+	if len(cols) == 0 {
+		return sq.SelectBuilder{}, errors.New("no columns to compare")
+	}
+	var quoted, values, notNull []string
+	for _, col := range cols {
+		c := QuoteIdentifier(col)
+		quoted = append(quoted, c)
+		values = append(values, c+"::text")
+		notNull = append(notNull, c+" IS NOT NULL")
+	}
+	q := sq.Select(
+		fmt.Sprintf("ARRAY[%s] AS values", strings.Join(values, ", ")),
+		"count(*) AS count",
+		"array_agg(id ORDER BY id) AS ids",
+	).
+		From(QuoteIdentifier(table)).
+		Where(strings.Join(notNull, " AND ")).
+		GroupBy(quoted...).
+		Having("count(*) > 1").
+		OrderBy("count(*) DESC", "min(id)")
+	return q, nil
+}
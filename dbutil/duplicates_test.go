@@ -0,0 +1,20 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindDuplicatesQuery(t *testing.T) {
+	q, err := findDuplicatesQuery("gtfs_stops", []string{"feed_version_id", "stop_name"})
+	assert.NoError(t, err)
+	sql, _, err := q.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`SELECT ARRAY["feed_version_id"::text, "stop_name"::text] AS values, count(*) AS count, array_agg(id ORDER BY id) AS ids FROM "gtfs_stops" WHERE "feed_version_id" IS NOT NULL AND "stop_name" IS NOT NULL GROUP BY "feed_version_id", "stop_name" HAVING count(*) > 1 ORDER BY count(*) DESC, min(id)`,
+		sql,
+	)
+	_, err = findDuplicatesQuery("gtfs_stops", nil)
+	assert.Error(t, err)
+}
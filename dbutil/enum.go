@@ -0,0 +1,155 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// InvalidEnumError is returned when a value is not a member of an enum type.
+type InvalidEnumError struct {
+	Type  string
+	Value string
+}
+
+func (e *InvalidEnumError) Error() string {
+	return fmt.Sprintf("invalid value '%s' for enum %s", e.Value, e.Type)
+}
+
+// EnumStrings converts a set of Go string constants to their string values.
+func EnumStrings[T ~string](values ...T) []string {
+	var ret []string
+	for _, v := range values {
+		ret = append(ret, string(v))
+	}
+	return ret
+}
+
+// ValidateEnum returns an *InvalidEnumError if value is not one of values.
+// Columns of enum types scan directly into Go string types, so no special scanning is required.
+func ValidateEnum[T ~string](typeName string, value T, values ...T) error {
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	return &InvalidEnumError{Type: typeName, Value: string(value)}
+}
+
+// GetEnumValues returns the values of an enum type in sort order.
+func GetEnumValues(ctx context.Context, db sqlx.Ext, name string) ([]string, error) {
+	var values []string
+	q := sq.Select("e.enumlabel").
+		From("pg_enum e").
+		Where("e.enumtypid = ?::regtype", QuoteIdentifier(name)).
+		OrderBy("e.enumsortorder")
+	err := Select(ctx, db, q, &values)
+	return values, err
+}
+
+// CreateEnumType creates an enum type with the given values.
+func CreateEnumType(ctx context.Context, db sqlx.Ext, name string, values []string) error {
+	var quoted []string
+	for _, v := range values {
+		quoted = append(quoted, QuoteLiteral(v))
+	}
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", QuoteIdentifier(name), strings.Join(quoted, ", "))))
+	return err
+}
+
+// SyncEnumType creates an enum type, or adds any values missing from an existing type.
+// Existing values are never removed, since Postgres does not support dropping enum values.
+func SyncEnumType(ctx context.Context, db sqlx.Ext, name string, values []string) error {
+	var exists bool
+	if err := Get(ctx, db, sq.Select().Column("to_regtype(?) IS NOT NULL", QuoteIdentifier(name)), &exists); err != nil {
+		return err
+	}
+	if !exists {
+		return CreateEnumType(ctx, db, name, values)
+	}
+	for _, v := range values {
+		if _, err := Exec(ctx, db, RawSQL(fmt.Sprintf("ALTER TYPE %s ADD VALUE IF NOT EXISTS %s", QuoteIdentifier(name), QuoteLiteral(v)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enum is implemented by Go enum types, string or integer based, to declare their value set.
+// EnumValues returns values of the implementing type.
+type Enum interface {
+	EnumName() string
+	EnumValues() []interface{}
+}
+
+// CheckEnum returns an *InvalidEnumError if e is not one of its declared values.
+func CheckEnum(e Enum) error {
+	for _, v := range e.EnumValues() {
+		if v == interface{}(e) {
+			return nil
+		}
+	}
+	return &InvalidEnumError{Type: e.EnumName(), Value: fmt.Sprintf("%v", e)}
+}
+
+// EnumValue checks e and returns its underlying string or integer value,
+// for use in the driver.Valuer implementation of an enum type, so invalid values
+// fail before reaching the database.
+func EnumValue(e Enum) (driver.Value, error) {
+	if err := CheckEnum(e); err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(e)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	}
+	return nil, fmt.Errorf("enum %s must be a string or integer type", e.EnumName())
+}
+
+// ValidateEnums checks the values of all Enum fields of an entity struct.
+func ValidateEnums(ent interface{}) error {
+	for _, val := range entValues(ent) {
+		if s, ok := val.(Sensitive); ok {
+			val = s.Val
+		}
+		if e, ok := val.(Enum); ok {
+			if err := CheckEnum(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnumCheckConstraint returns a CHECK constraint restricting column to the values of e,
+// for integer enums or string enums stored as text.
+func EnumCheckConstraint(column string, e Enum) string {
+	var literals []string
+	for _, v := range e.EnumValues() {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+			literals = append(literals, QuoteLiteral(rv.String()))
+		} else {
+			literals = append(literals, fmt.Sprintf("%d", rv.Interface()))
+		}
+	}
+	return fmt.Sprintf("CHECK (%s IN (%s))", QuoteIdentifier(column), strings.Join(literals, ", "))
+}
+
+// SyncEnum creates or updates the Postgres enum type for a string enum, using SyncEnumType.
+func SyncEnum(ctx context.Context, db sqlx.Ext, e Enum) error {
+	var values []string
+	for _, v := range e.EnumValues() {
+		values = append(values, reflect.ValueOf(v).String())
+	}
+	return SyncEnumType(ctx, db, e.EnumName(), values)
+}
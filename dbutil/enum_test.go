@@ -0,0 +1,47 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRouteType int
+
+func (e testRouteType) EnumName() string { return "route_type" }
+func (e testRouteType) EnumValues() []interface{} {
+	return []interface{}{testRouteType(0), testRouteType(1), testRouteType(3)}
+}
+
+type testWheelchair string
+
+func (e testWheelchair) EnumName() string { return "wheelchair" }
+func (e testWheelchair) EnumValues() []interface{} {
+	return []interface{}{testWheelchair("yes"), testWheelchair("no")}
+}
+
+func TestCheckEnum(t *testing.T) {
+	assert.NoError(t, CheckEnum(testRouteType(3)))
+	var enumErr *InvalidEnumError
+	assert.ErrorAs(t, CheckEnum(testRouteType(2)), &enumErr)
+	assert.Equal(t, "2", enumErr.Value)
+
+	v, err := EnumValue(testWheelchair("yes"))
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", v)
+	_, err = EnumValue(testWheelchair("maybe"))
+	assert.ErrorAs(t, err, &enumErr)
+
+	type ent struct {
+		ID         int
+		RouteType  testRouteType
+		Wheelchair testWheelchair
+	}
+	assert.NoError(t, ValidateEnums(&ent{RouteType: 1, Wheelchair: "no"}))
+	assert.Error(t, ValidateEnums(&ent{RouteType: 1, Wheelchair: "maybe"}))
+}
+
+func TestEnumCheckConstraint(t *testing.T) {
+	assert.Equal(t, `CHECK ("route_type" IN (0, 1, 3))`, EnumCheckConstraint("route_type", testRouteType(0)))
+	assert.Equal(t, `CHECK ("wheelchair" IN ('yes', 'no'))`, EnumCheckConstraint("wheelchair", testWheelchair("")))
+}
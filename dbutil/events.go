@@ -0,0 +1,124 @@
+package dbutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+// EventType is a database connection lifecycle event.
+type EventType string
+
+const (
+	EventConnected        EventType = "connected"
+	EventDisconnected     EventType = "disconnected"
+	EventPoolExhausted    EventType = "pool_exhausted"
+	EventReconnectAttempt EventType = "reconnect_attempt"
+)
+
+// Event describes a change in database health.
+type Event struct {
+	Type EventType
+	Time time.Time
+	Err  error
+}
+
+// EventBus delivers connection lifecycle events to subscribers.
+type EventBus struct {
+	subs   map[int]func(Event)
+	nextID int
+	lock   sync.RWMutex
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[int]func(Event){}}
+}
+
+// Subscribe registers fn to receive events and returns a function that removes the subscription.
+// fn is called synchronously and should not block.
+func (b *EventBus) Subscribe(fn func(Event)) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = fn
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// Publish sends an event to all subscribers.
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, fn := range b.subs {
+		fn(e)
+	}
+}
+
+// ConfigureEvents adds hooks to a pool config that publish connected and disconnected events
+// as individual pool connections are opened and closed.
+func ConfigureEvents(cfg *pgxpool.Config, bus *EventBus) {
+	afterConnect := cfg.AfterConnect
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		bus.Publish(Event{Type: EventConnected})
+		return nil
+	}
+	beforeClose := cfg.BeforeClose
+	cfg.BeforeClose = func(conn *pgx.Conn) {
+		if beforeClose != nil {
+			beforeClose(conn)
+		}
+		bus.Publish(Event{Type: EventDisconnected})
+	}
+}
+
+// Monitor pings db every interval until the context is done, publishing an event when the database
+// becomes unreachable or recovers, a reconnect attempt for each failed ping while unreachable,
+// and pool exhausted when callers had to wait for a connection since the last check.
+func Monitor(ctx context.Context, db *sqlx.DB, bus *EventBus, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		healthy := true
+		lastWaitCount := db.Stats().WaitCount
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := db.PingContext(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && healthy {
+				bus.Publish(Event{Type: EventDisconnected, Err: err})
+			} else if err != nil {
+				bus.Publish(Event{Type: EventReconnectAttempt, Err: err})
+			} else if !healthy {
+				bus.Publish(Event{Type: EventConnected})
+			}
+			healthy = err == nil
+			stats := db.Stats()
+			if stats.WaitCount > lastWaitCount {
+				bus.Publish(Event{Type: EventPoolExhausted})
+			}
+			lastWaitCount = stats.WaitCount
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Plan is a node in a query plan returned by EXPLAIN (FORMAT JSON).
+type Plan struct {
+	NodeType        string  `json:"Node Type"`
+	RelationName    string  `json:"Relation Name"`
+	IndexName       string  `json:"Index Name"`
+	StartupCost     float64 `json:"Startup Cost"`
+	TotalCost       float64 `json:"Total Cost"`
+	PlanRows        float64 `json:"Plan Rows"`
+	ActualTotalTime float64 `json:"Actual Total Time"`
+	ActualRows      float64 `json:"Actual Rows"`
+	Plans           []Plan  `json:"Plans"`
+}
+
+// UsesIndex returns true if this node or any child scans the named index.
+func (p Plan) UsesIndex(name string) bool {
+	if p.IndexName == name {
+		return true
+	}
+	for _, child := range p.Plans {
+		if child.UsesIndex(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExplainResult is the parsed output of EXPLAIN (FORMAT JSON).
+// Timing fields are only set when analyzed.
+type ExplainResult struct {
+	Plan          Plan    `json:"Plan"`
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+}
+
+// Explain returns the query plan for q. If analyze is true, the query is executed
+// to collect actual timings, so do not use analyze with statements that have side effects.
+func Explain(ctx context.Context, db sqlx.Ext, q sq.SelectBuilder, analyze bool) (*ExplainResult, error) {
+	prefix := "EXPLAIN (FORMAT JSON)"
+	if analyze {
+		prefix = "EXPLAIN (ANALYZE, FORMAT JSON)"
+	}
+	var out []byte
+	if err := Get(ctx, db, q.Prefix(prefix), &out); err != nil {
+		return nil, err
+	}
+	return parseExplain(out)
+}
+
+func parseExplain(data []byte) (*ExplainResult, error) {
+	var results []ExplainResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errors.New("empty explain output")
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,23 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseExplain(t *testing.T) {
+	data := `[{"Plan": {"Node Type": "Nested Loop", "Total Cost": 16.6, "Plans": [
+		{"Node Type": "Seq Scan", "Relation Name": "routes"},
+		{"Node Type": "Index Scan", "Relation Name": "stops", "Index Name": "stops_pkey"}
+	]}, "Planning Time": 0.2, "Execution Time": 1.5}]`
+	r, err := parseExplain([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Nested Loop", r.Plan.NodeType)
+	assert.Equal(t, 16.6, r.Plan.TotalCost)
+	assert.Equal(t, 1.5, r.ExecutionTime)
+	assert.True(t, r.Plan.UsesIndex("stops_pkey"))
+	assert.False(t, r.Plan.UsesIndex("routes_pkey"))
+}
@@ -0,0 +1,61 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+// OpenDBPoolFailover opens a pool that connects to the first reachable host among urls.
+// The hosts of later urls are added as pgx fallbacks, so each new pool connection
+// tries them in order; connections lost when the primary goes away are replaced
+// by connections to the next reachable host. Only the host, port, and TLS settings
+// of later urls are used; credentials, database, and runtime params come from the first.
+// Multi-host urls (host1,host2) are also supported.
+// Add target_session_attrs=read-write to the first url to skip hosts that are standbys.
+func OpenDBPoolFailover(ctx context.Context, urls ...string) (*pgxpool.Pool, *sqlx.DB, error) {
+	if len(urls) == 0 {
+		return nil, nil, errors.New("no database urls")
+	}
+	cfg, err := pgxpool.ParseConfig(urls[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, u := range urls[1:] {
+		fcfg, err := pgx.ParseConfig(u)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.ConnConfig.Fallbacks = append(cfg.ConnConfig.Fallbacks, &pgconn.FallbackConfig{
+			Host:      fcfg.Host,
+			Port:      fcfg.Port,
+			TLSConfig: fcfg.TLSConfig,
+		})
+		cfg.ConnConfig.Fallbacks = append(cfg.ConnConfig.Fallbacks, fcfg.Fallbacks...)
+	}
+	afterConnect := cfg.AfterConnect
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		logger(ctx).Trace().Str("host", conn.PgConn().Conn().RemoteAddr().String()).Msg("connected to database host")
+		return nil
+	}
+	return OpenDBPoolWithConfig(ctx, cfg)
+}
+
+// ServerAddr returns the address and port of the server handling queries for db,
+// e.g. to report which host a failover pool is connected to.
+func ServerAddr(ctx context.Context, db sqlx.Ext) (string, error) {
+	var addr string
+	q := sq.Select("coalesce(host(inet_server_addr()), 'local') || ':' || coalesce(inet_server_port(), 0)")
+	err := Get(ctx, db, q, &addr)
+	return addr, err
+}
@@ -0,0 +1,58 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// FanOutError reports the sources that failed in FanOutSelect, keyed by index.
+type FanOutError struct {
+	Errs map[int]error
+}
+
+func (e *FanOutError) Error() string {
+	var keys []int
+	for k := range e.Errs {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	var msgs []string
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("source %d: %s", k, e.Errs[k].Error()))
+	}
+	return fmt.Sprintf("%d sources failed: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+// FanOutSelect runs q concurrently against each database and passes the rows from each
+// successful source to merge. Calls to merge are serialized.
+// If any source fails, the returned error is a *FanOutError; results from other sources are still merged.
+func FanOutSelect[T any](ctx context.Context, dbs []sqlx.Ext, q sq.SelectBuilder, merge func(source int, rows []T)) error {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	errs := map[int]error{}
+	for i, db := range dbs {
+		wg.Add(1)
+		go func(i int, db sqlx.Ext) {
+			defer wg.Done()
+			rows, err := SelectT[T](ctx, db, q)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			merge(i, rows)
+		}(i, db)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &FanOutError{Errs: errs}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// FindEnts loads the rows of table with the given ids in a single query, in no particular order.
+// Ids with no matching row are ignored.
+func FindEnts[T any](ctx context.Context, db sqlx.Ext, table string, ids []int) ([]T, error) {
+	var ret []T
+	if len(ids) == 0 {
+		return ret, nil
+	}
+	q := sq.Select("*").From(QuoteIdentifier(table)).Where("id = ANY(?)", ids)
+	err := Select(ctx, db, q, &ret)
+	return ret, err
+}
+
+// FindEntsByID is FindEnts returning the rows keyed by their id column.
+func FindEntsByID[T any](ctx context.Context, db sqlx.Ext, table string, ids []int) (map[int]T, error) {
+	rows, err := FindEnts[T](ctx, db, table, ids)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[int]T, len(rows))
+	for _, row := range rows {
+		id, err := entID(row)
+		if err != nil {
+			return nil, err
+		}
+		ret[id] = row
+	}
+	return ret, nil
+}
+
+// entID returns the value of the id column of an entity struct.
+func entID(ent interface{}) (int, error) {
+	v := reflect.Indirect(reflect.ValueOf(ent))
+	f := entMapper.FieldByName(v, "id")
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(f.Int()), nil
+	}
+	return 0, fmt.Errorf("%s has no integer id column", v.Type())
+}
@@ -0,0 +1,19 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntID(t *testing.T) {
+	type ent struct {
+		ID   int64
+		Name string
+	}
+	id, err := entID(&ent{ID: 12})
+	assert.NoError(t, err)
+	assert.Equal(t, 12, id)
+	_, err = entID(struct{ Name string }{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+// Health is the result of a database health check.
+type Health struct {
+	OK              bool          `json:"ok"`
+	Latency         time.Duration `json:"latency"`
+	OpenConnections int           `json:"open_connections"`
+	InUse           int           `json:"in_use"`
+	Idle            int           `json:"idle"`
+	MaxConnections  int           `json:"max_connections"`
+	Error           string        `json:"error,omitempty"`
+	LastError       string        `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time    `json:"last_error_at,omitempty"`
+}
+
+// HealthChecker pings a database and reports connection pool statistics.
+// Pool is optional; if set, connection counts come from the pgx pool instead of database/sql.
+type HealthChecker struct {
+	Timeout     time.Duration
+	db          *sqlx.DB
+	pool        *pgxpool.Pool
+	lastErr     string
+	lastErrTime time.Time
+	lock        sync.Mutex
+}
+
+func NewHealthChecker(db *sqlx.DB, pool *pgxpool.Pool) *HealthChecker {
+	return &HealthChecker{Timeout: 5 * time.Second, db: db, pool: pool}
+}
+
+// Health pings the database and returns its latency and connection counts.
+func (h *HealthChecker) Health(ctx context.Context) Health {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	ret := Health{}
+	t := time.Now()
+	err := h.db.PingContext(ctx)
+	ret.Latency = time.Since(t)
+	if h.pool != nil {
+		stat := h.pool.Stat()
+		ret.OpenConnections = int(stat.TotalConns())
+		ret.InUse = int(stat.AcquiredConns())
+		ret.Idle = int(stat.IdleConns())
+		ret.MaxConnections = int(stat.MaxConns())
+	} else {
+		stat := h.db.Stats()
+		ret.OpenConnections = stat.OpenConnections
+		ret.InUse = stat.InUse
+		ret.Idle = stat.Idle
+		ret.MaxConnections = stat.MaxOpenConnections
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err != nil {
+		ret.Error = err.Error()
+		h.lastErr = ret.Error
+		h.lastErrTime = time.Now()
+	} else {
+		ret.OK = true
+	}
+	if h.lastErr != "" {
+		lastErrTime := h.lastErrTime
+		ret.LastError = h.lastErr
+		ret.LastErrorAt = &lastErrTime
+	}
+	return ret
+}
+
+// ServeHTTP writes the health check as JSON, with status 503 if the database is unavailable,
+// for use as a readiness probe.
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := h.Health(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if !health.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
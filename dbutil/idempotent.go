@@ -0,0 +1,43 @@
+package dbutil
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotencyTable is the table used by ExecIdempotent to record applied keys. Expected schema:
+//
+//	CREATE TABLE dbutil_idempotency_keys (key text PRIMARY KEY, created_at timestamptz NOT NULL DEFAULT now());
+var IdempotencyTable = "dbutil_idempotency_keys"
+
+// ExecIdempotentRetries is the number of times ExecIdempotent retries after a transient error.
+var ExecIdempotentRetries = 3
+
+// ExecIdempotent runs q at most once for key. The key is recorded in the same transaction
+// as q, so the statement is safely retried on transient errors.
+// Returns false if the key was already applied.
+func ExecIdempotent(ctx context.Context, db *sqlx.DB, q sq.Sqlizer, key string) (bool, error) {
+	applied := false
+	p := DefaultRetryPolicy
+	p.MaxAttempts = ExecIdempotentRetries + 1
+	err := p.Do(ctx, func(ctx context.Context) error {
+		applied = false
+		return Tx(ctx, db, func(tx *sqlx.Tx) error {
+			res, err := Exec(ctx, tx, sq.Insert(IdempotencyTable).Columns("key").Values(key).Suffix("ON CONFLICT DO NOTHING"))
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil || n == 0 {
+				return err
+			}
+			if _, err := Exec(ctx, tx, q); err != nil {
+				return err
+			}
+			applied = true
+			return nil
+		})
+	})
+	return applied && err == nil, err
+}
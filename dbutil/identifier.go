@@ -0,0 +1,73 @@
+package dbutil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// QuoteIdentifier quotes a possibly schema-qualified identifier, e.g. tl.feed_versions -> "tl"."feed_versions".
+func QuoteIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// QuoteLiteral quotes a string as a SQL literal, for statements that do not accept bind parameters.
+func QuoteLiteral(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// ValidateIdentifier returns an error unless name is a plain, possibly schema-qualified,
+// identifier that is safe to use without quoting.
+func ValidateIdentifier(name string) error {
+	for _, part := range strings.Split(name, ".") {
+		if len(part) > 63 {
+			return fmt.Errorf("identifier '%s' is longer than 63 characters", part)
+		}
+		if !identifierPattern.MatchString(part) {
+			return fmt.Errorf("invalid identifier '%s'", name)
+		}
+	}
+	return nil
+}
+
+// SanitizeOrderBy validates an ORDER BY expression such as "name ASC, id DESC NULLS LAST"
+// against a set of allowed columns and returns it in normalized form.
+func SanitizeOrderBy(expr string, allowed ...string) (string, error) {
+	allowedCols := map[string]bool{}
+	for _, col := range allowed {
+		allowedCols[col] = true
+	}
+	var terms []string
+	for _, term := range strings.Split(expr, ",") {
+		fields := strings.Fields(term)
+		if len(fields) == 0 {
+			return "", fmt.Errorf("empty order by term in '%s'", expr)
+		}
+		col := fields[0]
+		if !allowedCols[col] {
+			return "", fmt.Errorf("order by column '%s' is not allowed", col)
+		}
+		if err := ValidateIdentifier(col); err != nil {
+			return "", err
+		}
+		out := []string{col}
+		rest := strings.ToUpper(strings.Join(fields[1:], " "))
+		switch rest {
+		case "", "ASC", "DESC",
+			"NULLS FIRST", "NULLS LAST",
+			"ASC NULLS FIRST", "ASC NULLS LAST",
+			"DESC NULLS FIRST", "DESC NULLS LAST":
+		default:
+			return "", fmt.Errorf("invalid order by direction '%s'", rest)
+		}
+		if rest != "" {
+			out = append(out, rest)
+		}
+		terms = append(terms, strings.Join(out, " "))
+	}
+	return strings.Join(terms, ", "), nil
+}
@@ -0,0 +1,50 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"feed_versions"`, QuoteIdentifier("feed_versions"))
+	assert.Equal(t, `"tl"."feed_versions"`, QuoteIdentifier("tl.feed_versions"))
+	assert.Equal(t, `"a""b"`, QuoteIdentifier(`a"b`))
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	assert.NoError(t, ValidateIdentifier("feed_versions"))
+	assert.NoError(t, ValidateIdentifier("tenant_1.stops"))
+	assert.Error(t, ValidateIdentifier("stops; drop table stops"))
+	assert.Error(t, ValidateIdentifier("1stops"))
+	assert.Error(t, ValidateIdentifier(""))
+}
+
+func TestSanitizeOrderBy(t *testing.T) {
+	tcs := []struct {
+		expr   string
+		expect string
+		ok     bool
+	}{
+		{"name", "name", true},
+		{"name desc, id asc nulls last", "name DESC, id ASC NULLS LAST", true},
+		{"email", "", false},
+		{"name; drop table stops", "", false},
+		{"name sideways", "", false},
+		{"name,", "", false},
+	}
+	for _, tc := range tcs {
+		v, err := SanitizeOrderBy(tc.expr, "id", "name")
+		if tc.ok {
+			assert.NoError(t, err, tc.expr)
+			assert.Equal(t, tc.expect, v)
+		} else {
+			assert.Error(t, err, tc.expr)
+		}
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	assert.Equal(t, "'bus'", QuoteLiteral("bus"))
+	assert.Equal(t, "'O''Hare'", QuoteLiteral("O'Hare"))
+}
@@ -0,0 +1,120 @@
+package dbutil
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RunningQuery is a query that is currently running.
+type RunningQuery struct {
+	ID       int64         `json:"id"`
+	Query    string        `json:"query"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// QueryRegistry tracks queries run by Select, Get, and Exec while they are running.
+type QueryRegistry struct {
+	running map[int64]*runningQuery
+	nextID  int64
+	lock    sync.Mutex
+}
+
+type runningQuery struct {
+	query  string
+	start  time.Time
+	cancel context.CancelFunc
+}
+
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{running: map[int64]*runningQuery{}}
+}
+
+var queryRegistry *QueryRegistry
+var queryRegistryLock sync.RWMutex
+
+// SetQueryRegistry enables tracking of running queries in r, or disables it if r is nil.
+func SetQueryRegistry(r *QueryRegistry) {
+	queryRegistryLock.Lock()
+	defer queryRegistryLock.Unlock()
+	queryRegistry = r
+}
+
+func getQueryRegistry() *QueryRegistry {
+	queryRegistryLock.RLock()
+	defer queryRegistryLock.RUnlock()
+	return queryRegistry
+}
+
+// trackQuery registers a query as running, if a registry is set, and returns a function to call when it finishes.
+// The returned context is canceled if the query is canceled through the registry.
+func trackQuery(ctx context.Context, qstr string) (context.Context, func()) {
+	r := getQueryRegistry()
+	if r == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.nextID++
+	id := r.nextID
+	r.running[id] = &runningQuery{query: qstr, start: time.Now(), cancel: cancel}
+	return ctx, func() {
+		cancel()
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		delete(r.running, id)
+	}
+}
+
+// Running returns the running queries, longest running first.
+func (r *QueryRegistry) Running() []RunningQuery {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+	var ret []RunningQuery
+	for id, q := range r.running {
+		ret = append(ret, RunningQuery{ID: id, Query: q.query, Start: q.start, Duration: now.Sub(q.start)})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// Cancel cancels a running query by ID. The driver cancels the query on the server.
+// Returns false if the query is no longer running.
+func (r *QueryRegistry) Cancel(id int64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	q, ok := r.running[id]
+	if ok {
+		q.cancel()
+	}
+	return ok
+}
+
+// Watchdog checks running queries every interval until the context is done,
+// logging queries that have run longer than maxDuration, and canceling them if cancel is true.
+func (r *QueryRegistry) Watchdog(ctx context.Context, interval time.Duration, maxDuration time.Duration, cancel bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for _, q := range r.Running() {
+				if q.Duration < maxDuration {
+					continue
+				}
+				logger(ctx).Error().Int64("id", q.ID).Str("query", q.Query).Str("duration", q.Duration.String()).Bool("cancel", cancel).Msg("watchdog: long running query")
+				if cancel {
+					r.Cancel(q.ID)
+				}
+			}
+		}
+	}()
+}
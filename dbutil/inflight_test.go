@@ -0,0 +1,24 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRegistry(t *testing.T) {
+	r := NewQueryRegistry()
+	SetQueryRegistry(r)
+	defer SetQueryRegistry(nil)
+	ctx, done := trackQuery(context.Background(), "SELECT pg_sleep(10)")
+	running := r.Running()
+	if assert.Equal(t, 1, len(running)) {
+		assert.Equal(t, "SELECT pg_sleep(10)", running[0].Query)
+		assert.True(t, r.Cancel(running[0].ID))
+	}
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	done()
+	assert.Equal(t, 0, len(r.Running()))
+	assert.False(t, r.Cancel(running[0].ID))
+}
@@ -0,0 +1,64 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertReturning runs an insert with RETURNING * and scans the inserted row into dest,
+// so database-generated values such as ids, defaults, and generated columns are captured.
+func InsertReturning(ctx context.Context, db sqlx.Ext, q sq.InsertBuilder, dest interface{}) error {
+	t := time.Now()
+	ctx, cancel := withQueryTimeout(ctx, WriteStatement)
+	defer cancel()
+	qstr, qargs, err := q.Suffix("RETURNING *").PlaceholderFormat(sq.Dollar).ToSql()
+	if err == nil {
+		err = checkBindParams(qargs)
+	}
+	if err == nil {
+		err = checkShutdown(db)
+	}
+	if err == nil {
+		err = checkSchema(ctx, db)
+	}
+	if err == nil {
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		err = runMiddleware(ctx, &QueryInfo{Query: qstr, Args: qargs, Dest: dest}, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.QueryerContext); ok {
+				err = sqlx.GetContext(ctx, a, dest, qstr, qargs...)
+			} else {
+				err = sqlx.Get(db, dest, qstr, qargs...)
+			}
+			return err
+		})
+	}
+	rows := int64(0)
+	if err == nil {
+		rows = 1
+	}
+	logQuery(ctx, qstr, qargs, t, rows, err)
+	return err
+}
+
+// InsertIgnore runs an insert with ON CONFLICT DO NOTHING and returns the number of rows inserted.
+// Rows that conflict with existing rows on conflictCols, or on any unique constraint if none are
+// given, are skipped; the number skipped is the number of rows in q minus the number inserted.
+func InsertIgnore(ctx context.Context, db sqlx.Ext, q sq.InsertBuilder, conflictCols ...string) (int64, error) {
+	suffix := "ON CONFLICT DO NOTHING"
+	if len(conflictCols) > 0 {
+		var quoted []string
+		for _, col := range conflictCols {
+			quoted = append(quoted, QuoteIdentifier(col))
+		}
+		suffix = fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(quoted, ", "))
+	}
+	return execRowsAffected(ctx, db, q.Suffix(suffix))
+}
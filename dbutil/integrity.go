@@ -0,0 +1,170 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Relationship is a reference from columns of one table to columns of another, either
+// declared by a foreign key or by convention, e.g. gtfs_stops.parent_station to gtfs_stops.id.
+// IDColumn identifies rows of Table in reports, and defaults to id.
+type Relationship struct {
+	Name       string   `json:"name"`
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	IDColumn   string   `json:"id_column,omitempty"`
+}
+
+// ForeignKeyRelationships introspects the foreign keys of tables in the given schemas, or public if none are given.
+func ForeignKeyRelationships(ctx context.Context, db sqlx.Ext, schemas ...string) ([]Relationship, error) {
+	if len(schemas) == 0 {
+		schemas = []string{"public"}
+	}
+	attnames := func(rel, key string) string {
+		return fmt.Sprintf(
+			"array(SELECT a.attname::text FROM unnest(con.%s) WITH ORDINALITY k(attnum, ord) JOIN pg_attribute a ON a.attrelid = con.%s AND a.attnum = k.attnum ORDER BY k.ord)",
+			key,
+			rel,
+		)
+	}
+	var rows []struct {
+		Name       string        `db:"name"`
+		Table      string        `db:"table_name"`
+		Columns    Array[string] `db:"columns"`
+		RefTable   string        `db:"ref_table"`
+		RefColumns Array[string] `db:"ref_columns"`
+	}
+	q := sq.Select(
+		"con.conname AS name",
+		"n.nspname || '.' || t.relname AS table_name",
+		attnames("conrelid", "conkey")+" AS columns",
+		"rn.nspname || '.' || rt.relname AS ref_table",
+		attnames("confrelid", "confkey")+" AS ref_columns",
+	).
+		From("pg_constraint con").
+		Join("pg_class t ON t.oid = con.conrelid").
+		Join("pg_namespace n ON n.oid = t.relnamespace").
+		Join("pg_class rt ON rt.oid = con.confrelid").
+		Join("pg_namespace rn ON rn.oid = rt.relnamespace").
+		Where("con.contype = 'f'").
+		Where("n.nspname = ANY(?)", schemas).
+		OrderBy("n.nspname", "t.relname", "con.conname")
+	if err := Select(ctx, db, q, &rows); err != nil {
+		return nil, err
+	}
+	var ret []Relationship
+	for _, row := range rows {
+		ret = append(ret, Relationship{
+			Name:       row.Name,
+			Table:      row.Table,
+			Columns:    row.Columns.Val,
+			RefTable:   row.RefTable,
+			RefColumns: row.RefColumns.Val,
+		})
+	}
+	return ret, nil
+}
+
+// IntegrityReport describes the rows of a Relationship that reference missing rows.
+// Orphans counts referencing rows, and Dangling counts distinct missing referenced values;
+// up to the requested number of samples of each are included.
+type IntegrityReport struct {
+	Relationship   Relationship `json:"relationship"`
+	Orphans        int64        `json:"orphans"`
+	Dangling       int64        `json:"dangling"`
+	SampleIDs      []string     `json:"sample_ids,omitempty"`
+	SampleDangling []string     `json:"sample_dangling,omitempty"`
+}
+
+// OK returns true if every reference points to an existing row.
+func (r IntegrityReport) OK() bool {
+	return r.Orphans == 0
+}
+
+func (r IntegrityReport) String() string {
+	rel := r.Relationship
+	s := fmt.Sprintf("%s (%s) -> %s (%s): %d orphaned rows, %d dangling references",
+		rel.Table,
+		strings.Join(rel.Columns, ", "),
+		rel.RefTable,
+		strings.Join(rel.RefColumns, ", "),
+		r.Orphans,
+		r.Dangling,
+	)
+	if len(r.SampleIDs) > 0 {
+		s += fmt.Sprintf("\n  sample ids: %s", strings.Join(r.SampleIDs, ", "))
+	}
+	if len(r.SampleDangling) > 0 {
+		s += fmt.Sprintf("\n  sample references: %s", strings.Join(r.SampleDangling, ", "))
+	}
+	return s
+}
+
+// CheckIntegrity finds orphaned rows for each relationship, e.g. after an import that ran
+// with foreign keys deferred or disabled, or for references not declared as foreign keys.
+// Rows with a NULL in any referencing column are not checked, matching foreign key semantics.
+// Each relationship is checked with one query that scans the referencing table.
+func CheckIntegrity(ctx context.Context, db sqlx.Ext, rels []Relationship, samples int) ([]IntegrityReport, error) {
+	var ret []IntegrityReport
+	for _, rel := range rels {
+		q, err := integrityQuery(rel, samples)
+		if err != nil {
+			return ret, err
+		}
+		var row struct {
+			Orphans        int64         `db:"orphans"`
+			Dangling       int64         `db:"dangling"`
+			SampleIDs      Array[string] `db:"sample_ids"`
+			SampleDangling Array[string] `db:"sample_dangling"`
+		}
+		if err := Get(ctx, db, q, &row); err != nil {
+			return ret, err
+		}
+		ret = append(ret, IntegrityReport{
+			Relationship:   rel,
+			Orphans:        row.Orphans,
+			Dangling:       row.Dangling,
+			SampleIDs:      row.SampleIDs.Val,
+			SampleDangling: row.SampleDangling.Val,
+		})
+	}
+	return ret, nil
+}
+
+func integrityQuery(rel Relationship, samples int) (sq.SelectBuilder, error) {
+	if len(rel.Columns) == 0 || len(rel.Columns) != len(rel.RefColumns) {
+		return sq.SelectBuilder{}, fmt.Errorf("relationship %s -> %s: columns and ref columns must match", rel.Table, rel.RefTable)
+	}
+	idCol := rel.IDColumn
+	if idCol == "" {
+		idCol = "id"
+	}
+	var cols, notNull, match []string
+	for i, col := range rel.Columns {
+		c := "c." + QuoteIdentifier(col)
+		cols = append(cols, c)
+		notNull = append(notNull, c+" IS NOT NULL")
+		match = append(match, fmt.Sprintf("p.%s = %s", QuoteIdentifier(rel.RefColumns[i]), c))
+	}
+	ref := cols[0] + "::text"
+	if len(cols) > 1 {
+		ref = fmt.Sprintf("ROW(%s)::text", strings.Join(cols, ", "))
+	}
+	id := "c." + QuoteIdentifier(idCol)
+	q := sq.Select(
+		"count(*) AS orphans",
+		fmt.Sprintf("count(DISTINCT %s) AS dangling", ref),
+		fmt.Sprintf("(array_agg(%s::text ORDER BY %s))[1:%d] AS sample_ids", id, id, samples),
+		fmt.Sprintf("(array_agg(DISTINCT %s))[1:%d] AS sample_dangling", ref, samples),
+	).
+		From(QuoteIdentifier(rel.Table) + " AS c").
+		Where(strings.Join(notNull, " AND ")).
+		Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS p WHERE %s)", QuoteIdentifier(rel.RefTable), strings.Join(match, " AND ")))
+	return q, nil
+}
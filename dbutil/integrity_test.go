@@ -0,0 +1,45 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrityQuery(t *testing.T) {
+	t.Run("single column", func(t *testing.T) {
+		q, err := integrityQuery(Relationship{
+			Table:      "gtfs_stops",
+			Columns:    []string{"parent_station"},
+			RefTable:   "gtfs_stops",
+			RefColumns: []string{"id"},
+		}, 5)
+		assert.NoError(t, err)
+		sql, _, err := q.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t,
+			`SELECT count(*) AS orphans, count(DISTINCT c."parent_station"::text) AS dangling, (array_agg(c."id"::text ORDER BY c."id"))[1:5] AS sample_ids, (array_agg(DISTINCT c."parent_station"::text))[1:5] AS sample_dangling FROM "gtfs_stops" AS c WHERE c."parent_station" IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "gtfs_stops" AS p WHERE p."id" = c."parent_station")`,
+			sql,
+		)
+	})
+	t.Run("multiple columns", func(t *testing.T) {
+		q, err := integrityQuery(Relationship{
+			Table:      "public.gtfs_trips",
+			Columns:    []string{"feed_version_id", "route_id"},
+			RefTable:   "public.gtfs_routes",
+			RefColumns: []string{"feed_version_id", "id"},
+			IDColumn:   "trip_id",
+		}, 1)
+		assert.NoError(t, err)
+		sql, _, err := q.ToSql()
+		assert.NoError(t, err)
+		assert.Contains(t, sql, `count(DISTINCT ROW(c."feed_version_id", c."route_id")::text) AS dangling`)
+		assert.Contains(t, sql, `array_agg(c."trip_id"::text ORDER BY c."trip_id")`)
+		assert.Contains(t, sql, `FROM "public"."gtfs_trips" AS c`)
+		assert.Contains(t, sql, `WHERE p."feed_version_id" = c."feed_version_id" AND p."id" = c."route_id"`)
+	})
+	t.Run("mismatched columns", func(t *testing.T) {
+		_, err := integrityQuery(Relationship{Table: "a", Columns: []string{"x", "y"}, RefTable: "b", RefColumns: []string{"id"}}, 1)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,80 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type journalContextKey struct{}
+
+// JournalEntry is a statement recorded by a Journal.
+type JournalEntry struct {
+	Query    string
+	Duration time.Duration
+	Err      error
+}
+
+// Journal records the statements run through Select, Get, and Exec with a context
+// created by WithJournal. It is typically used to debug failed transactions.
+type Journal struct {
+	entries []JournalEntry
+	lock    sync.Mutex
+}
+
+// WithJournal returns a context that records statements into the returned Journal.
+// When Tx is called with this context and the transaction fails, the returned error is a *JournalError.
+func WithJournal(ctx context.Context) (context.Context, *Journal) {
+	j := &Journal{}
+	return context.WithValue(ctx, journalContextKey{}, j), j
+}
+
+// Entries returns the recorded statements, in order.
+func (j *Journal) Entries() []JournalEntry {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return append([]JournalEntry{}, j.entries...)
+}
+
+func (j *Journal) record(qstr string, d time.Duration, err error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.entries = append(j.entries, JournalEntry{Query: strings.Join(strings.Fields(qstr), " "), Duration: d, Err: err})
+}
+
+func journalFromContext(ctx context.Context) *Journal {
+	j, _ := ctx.Value(journalContextKey{}).(*Journal)
+	return j
+}
+
+// JournalError wraps a transaction error with the statements the transaction ran.
+type JournalError struct {
+	Err     error
+	Entries []JournalEntry
+}
+
+func (e *JournalError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Err.Error())
+	sb.WriteString("; transaction statements:")
+	for i, entry := range e.Entries {
+		sb.WriteString(fmt.Sprintf("\n  %d: %s (%s)", i+1, entry.Query, entry.Duration))
+		if entry.Err != nil {
+			sb.WriteString(fmt.Sprintf(" error: %s", entry.Err.Error()))
+		}
+	}
+	return sb.String()
+}
+
+func (e *JournalError) Unwrap() error {
+	return e.Err
+}
+
+func wrapJournalError(ctx context.Context, err error) error {
+	if j := journalFromContext(ctx); j != nil && err != nil {
+		return &JournalError{Err: err, Entries: j.Entries()}
+	}
+	return err
+}
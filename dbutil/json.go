@@ -0,0 +1,78 @@
+package dbutil
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// JSONBuildObject returns a jsonb_build_object expression using each column name as its key.
+func JSONBuildObject(cols ...string) string {
+	var args []string
+	for _, col := range cols {
+		key := col
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			key = col[i+1:]
+		}
+		args = append(args, fmt.Sprintf("'%s', %s", key, col))
+	}
+	return fmt.Sprintf("jsonb_build_object(%s)", strings.Join(args, ", "))
+}
+
+// JSONAgg returns a jsonb_agg expression aliased as alias.
+// Groups with no rows produce an empty array instead of NULL.
+func JSONAgg(expr string, alias string) string {
+	return fmt.Sprintf("coalesce(jsonb_agg(%s), '[]'::jsonb) AS %s", expr, alias)
+}
+
+// JSON scans a json or jsonb column into a value of type T.
+type JSON[T any] struct {
+	Val   T
+	Valid bool
+}
+
+// Scan implements sql.Scanner.
+func (r *JSON[T]) Scan(src interface{}) error {
+	var zero T
+	r.Val, r.Valid = zero, false
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", src)
+	}
+	if err := json.Unmarshal(b, &r.Val); err != nil {
+		return err
+	}
+	r.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (r JSON[T]) Value() (driver.Value, error) {
+	if !r.Valid {
+		return nil, nil
+	}
+	return json.Marshal(r.Val)
+}
+
+// JSONAggSubquery returns a correlated subquery column that aggregates rows of child into
+// a JSON array of objects with the given columns, aliased as alias, e.g. to load small nested
+// collections with their parents in one query. child should have no columns, only FROM and
+// a WHERE condition referring to the parent; orderBy, if not empty, orders the array.
+// Scan the column into a JSON[[]T] field.
+func JSONAggSubquery(child sq.SelectBuilder, alias string, orderBy string, cols ...string) sq.Sqlizer {
+	agg := JSONBuildObject(cols...)
+	if orderBy != "" {
+		agg = agg + " ORDER BY " + orderBy
+	}
+	return sq.Alias(child.Column(fmt.Sprintf("coalesce(jsonb_agg(%s), '[]'::jsonb)", agg)), alias)
+}
@@ -0,0 +1,50 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONBuildObject(t *testing.T) {
+	assert.Equal(t, "jsonb_build_object('id', r.id, 'route_id', r.route_id)", JSONBuildObject("r.id", "r.route_id"))
+	assert.Equal(t, "coalesce(jsonb_agg(r.id), '[]'::jsonb) AS ids", JSONAgg("r.id", "ids"))
+}
+
+func TestJSON_Scan(t *testing.T) {
+	type child struct {
+		ID      int    `json:"id"`
+		RouteID string `json:"route_id"`
+	}
+	tcs := []struct {
+		src   interface{}
+		valid bool
+		count int
+	}{
+		{nil, false, 0},
+		{"[]", true, 0},
+		{[]byte(`[{"id":1,"route_id":"a"},{"id":2,"route_id":"b"}]`), true, 2},
+	}
+	for _, tc := range tcs {
+		var v JSON[[]child]
+		if err := v.Scan(tc.src); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.valid, v.Valid)
+		assert.Equal(t, tc.count, len(v.Val))
+	}
+}
+
+func TestJSONAggSubquery(t *testing.T) {
+	child := sq.Select().From("gtfs_stops c").Where("c.parent_station = p.id AND c.location_type = ?", 0)
+	q := sq.Select("p.id").
+		Column(JSONAggSubquery(child, "children", "c.stop_name", "c.id", "c.stop_name")).
+		From("gtfs_stops p").
+		Where("p.location_type = ?", 1).
+		PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT p.id, (SELECT coalesce(jsonb_agg(jsonb_build_object('id', c.id, 'stop_name', c.stop_name) ORDER BY c.stop_name), '[]'::jsonb) FROM gtfs_stops c WHERE c.parent_station = p.id AND c.location_type = $1) AS children FROM gtfs_stops p WHERE p.location_type = $2", qstr)
+	assert.Equal(t, []interface{}{0, 1}, qargs)
+}
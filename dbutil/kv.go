@@ -0,0 +1,105 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// KVStore stores small values, such as cursors, checkpoints, and feature flags, as JSON in a table.
+// Keys with a TTL expire after it has passed; expired keys are ignored, and removed by Sweep.
+// Methods take the db to use, so updates can share a transaction with related changes. Expected schema:
+//
+//	CREATE TABLE dbutil_kv (
+//		key text PRIMARY KEY,
+//		value jsonb NOT NULL,
+//		expires_at timestamptz,
+//		updated_at timestamptz NOT NULL DEFAULT now()
+//	);
+type KVStore struct {
+	Table string
+}
+
+func NewKVStore(table string) *KVStore {
+	return &KVStore{Table: table}
+}
+
+// notExpired is the condition for keys that have not expired.
+const notExpired = "(expires_at IS NULL OR expires_at > now())"
+
+// Get decodes the value of key into dest. Returns false if the key does not exist or has expired.
+func (kv *KVStore) Get(ctx context.Context, db sqlx.Ext, key string, dest interface{}) (bool, error) {
+	var values []string
+	q := sq.Select("value::text").From(QuoteIdentifier(kv.Table)).Where("key = ?", key).Where(notExpired)
+	if err := Select(ctx, db, q, &values); err != nil || len(values) == 0 {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(values[0]), dest)
+}
+
+// Set sets the value of key. If ttl is greater than zero the key expires after ttl.
+func (kv *KVStore) Set(ctx context.Context, db sqlx.Ext, key string, value interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	q := sq.Insert(QuoteIdentifier(kv.Table)).
+		Columns("key", "value", "expires_at").
+		Values(key, string(b), ttlExpiresAt(ttl)).
+		Suffix("ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at, updated_at = now()")
+	_, err = Exec(ctx, db, q)
+	return err
+}
+
+// Delete removes key.
+func (kv *KVStore) Delete(ctx context.Context, db sqlx.Ext, key string) error {
+	_, err := Exec(ctx, db, sq.Delete(QuoteIdentifier(kv.Table)).Where("key = ?", key))
+	return err
+}
+
+// CompareAndSwap sets the value of key to newValue only if its current value equals oldValue, compared as JSON.
+// If oldValue is nil, the key is set only if it does not exist or has expired.
+// Returns false if the value was not set.
+func (kv *KVStore) CompareAndSwap(ctx context.Context, db sqlx.Ext, key string, oldValue interface{}, newValue interface{}, ttl time.Duration) (bool, error) {
+	newb, err := json.Marshal(newValue)
+	if err != nil {
+		return false, err
+	}
+	var q sq.Sqlizer
+	if oldValue == nil {
+		q = sq.Insert(QuoteIdentifier(kv.Table)+" AS kv").
+			Columns("key", "value", "expires_at").
+			Values(key, string(newb), ttlExpiresAt(ttl)).
+			Suffix("ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at, updated_at = now() WHERE kv.expires_at <= now()")
+	} else {
+		oldb, err := json.Marshal(oldValue)
+		if err != nil {
+			return false, err
+		}
+		q = sq.Update(QuoteIdentifier(kv.Table)).
+			Set("value", string(newb)).
+			Set("expires_at", ttlExpiresAt(ttl)).
+			Set("updated_at", sq.Expr("now()")).
+			Where("key = ?", key).
+			Where("value = ?::jsonb", string(oldb)).
+			Where(notExpired)
+	}
+	n, err := execRowsAffected(ctx, db, q)
+	return n > 0, err
+}
+
+// Sweep removes expired keys and returns the number removed.
+func (kv *KVStore) Sweep(ctx context.Context, db sqlx.Ext) (int64, error) {
+	return execRowsAffected(ctx, db, sq.Delete(QuoteIdentifier(kv.Table)).Where("expires_at <= now()"))
+}
+
+// ttlExpiresAt returns the expiry time for ttl, computed with the server clock, or NULL for no expiry.
+func ttlExpiresAt(ttl time.Duration) interface{} {
+	if ttl <= 0 {
+		return nil
+	}
+	return sq.Expr("now() + make_interval(secs => ?)", ttl.Seconds())
+}
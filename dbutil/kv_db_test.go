@@ -0,0 +1,87 @@
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVStoreDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	// Statements run outside a transaction, so now() advances for TTL expiry
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS dbutil_kv_test CASCADE",
+		"CREATE SCHEMA dbutil_kv_test",
+		`CREATE TABLE dbutil_kv_test."KV" (key text PRIMARY KEY, value jsonb NOT NULL, expires_at timestamptz, updated_at timestamptz NOT NULL DEFAULT now())`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.ExecContext(ctx, "DROP SCHEMA dbutil_kv_test CASCADE")
+	kv := dbutil.NewKVStore("dbutil_kv_test.KV")
+	get := func(key string) (int, bool) {
+		var v int
+		ok, err := kv.Get(ctx, db, key, &v)
+		assert.NoError(t, err)
+		return v, ok
+	}
+
+	t.Run("ttl", func(t *testing.T) {
+		assert.NoError(t, kv.Set(ctx, db, "short", 1, 50*time.Millisecond))
+		assert.NoError(t, kv.Set(ctx, db, "long", 2, time.Hour))
+		assert.NoError(t, kv.Set(ctx, db, "forever", 3, 0))
+		v, ok := get("short")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		time.Sleep(100 * time.Millisecond)
+		_, ok = get("short")
+		assert.False(t, ok)
+		_, ok = get("long")
+		assert.True(t, ok)
+		n, err := kv.Sweep(ctx, db)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		_, ok = get("forever")
+		assert.True(t, ok)
+	})
+
+	t.Run("compare and swap", func(t *testing.T) {
+		ok, err := kv.CompareAndSwap(ctx, db, "cas", nil, 1, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		// Only set if missing
+		ok, err = kv.CompareAndSwap(ctx, db, "cas", nil, 2, 0)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		// Stale old value
+		ok, err = kv.CompareAndSwap(ctx, db, "cas", 2, 3, 0)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = kv.CompareAndSwap(ctx, db, "cas", 1, 3, 50*time.Millisecond)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		v, _ := get("cas")
+		assert.Equal(t, 3, v)
+		// Expired keys can be set as if missing, but not swapped
+		time.Sleep(100 * time.Millisecond)
+		ok, err = kv.CompareAndSwap(ctx, db, "cas", 3, 4, 0)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = kv.CompareAndSwap(ctx, db, "cas", nil, 5, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		v, _ = get("cas")
+		assert.Equal(t, 5, v)
+	})
+}
@@ -0,0 +1,27 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVStore(t *testing.T) {
+	ctx, d := WithDryRun(context.Background())
+	kv := NewKVStore("dbutil_kv")
+	assert.NoError(t, kv.Set(ctx, nil, "cursor", map[string]int{"id": 10}, time.Minute))
+	_, err := kv.CompareAndSwap(ctx, nil, "cursor", nil, 1, 0)
+	assert.NoError(t, err)
+	_, err = kv.CompareAndSwap(ctx, nil, "cursor", 1, 2, 0)
+	assert.NoError(t, err)
+	stmts := d.Statements()
+	if assert.Equal(t, 3, len(stmts)) {
+		assert.Equal(t, `INSERT INTO "dbutil_kv" (key,value,expires_at) VALUES ($1,$2,now() + make_interval(secs => $3)) ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at, updated_at = now()`, stmts[0].Query)
+		assert.Equal(t, []interface{}{"cursor", `{"id":10}`, float64(60)}, stmts[0].Args)
+		assert.Equal(t, `INSERT INTO "dbutil_kv" AS kv (key,value,expires_at) VALUES ($1,$2,$3) ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at, updated_at = now() WHERE kv.expires_at <= now()`, stmts[1].Query)
+		assert.Equal(t, `UPDATE "dbutil_kv" SET value = $1, expires_at = $2, updated_at = now() WHERE key = $3 AND value = $4::jsonb AND (expires_at IS NULL OR expires_at > now())`, stmts[2].Query)
+		assert.Equal(t, []interface{}{"2", nil, "cursor", "1"}, stmts[2].Args)
+	}
+}
@@ -0,0 +1,130 @@
+package dbutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultLeaderInterval is the Interval used when a LeaderElector has none.
+const DefaultLeaderInterval = 5 * time.Second
+
+// LeaderElector elects a single leader among processes sharing a database, using a
+// session-level advisory lock held on a dedicated pool connection. The connection is
+// checked every Interval; if it is lost, so is the lock, and leadership ends until
+// the lock is acquired again on a new connection.
+type LeaderElector struct {
+	Key      int64
+	Interval time.Duration
+	pool     *pgxpool.Pool
+	leader   bool
+	lost     chan struct{}
+	lock     sync.Mutex
+}
+
+// NewLeaderElector returns a LeaderElector for the advisory lock key of name.
+func NewLeaderElector(pool *pgxpool.Pool, name string) *LeaderElector {
+	lost := make(chan struct{})
+	close(lost)
+	return &LeaderElector{
+		Key:      AdvisoryLockKey(name),
+		Interval: DefaultLeaderInterval,
+		pool:     pool,
+		lost:     lost,
+	}
+}
+
+// IsLeader returns true while this process holds the leader lock.
+func (e *LeaderElector) IsLeader() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.leader
+}
+
+// Lost returns a channel that is closed when the current leadership ends,
+// or an already closed channel if this process is not the leader.
+// Work that requires leadership should stop when it is closed.
+func (e *LeaderElector) Lost() <-chan struct{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.lost
+}
+
+// Run tries to acquire leadership every Interval, and holds it until the context is done
+// or the connection is lost. The lock is released when Run returns.
+func (e *LeaderElector) Run(ctx context.Context) error {
+	for {
+		if err := e.hold(ctx); err != nil && ctx.Err() == nil {
+			logger(ctx).Error().Err(err).Int64("key", e.Key).Msg("leader election: connection lost")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.interval()):
+		}
+	}
+}
+
+// Start runs Run in the background.
+func (e *LeaderElector) Start(ctx context.Context) {
+	go e.Run(ctx)
+}
+
+// hold acquires the lock if available and holds it until the context is done or the connection fails.
+func (e *LeaderElector) hold(ctx context.Context) error {
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	locked := false
+	defer func() {
+		if locked {
+			e.setLeader(false)
+			// Connections are returned to the pool, so release the session lock first
+			if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", e.Key); err != nil {
+				conn.Conn().Close(context.Background())
+			}
+		}
+		conn.Release()
+	}()
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", e.Key).Scan(&locked); err != nil || !locked {
+		return err
+	}
+	e.setLeader(true)
+	logger(ctx).Info().Int64("key", e.Key).Msg("leader election: acquired leadership")
+	ticker := time.NewTicker(e.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := conn.Ping(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *LeaderElector) interval() time.Duration {
+	if e.Interval <= 0 {
+		return DefaultLeaderInterval
+	}
+	return e.Interval
+}
+
+func (e *LeaderElector) setLeader(leader bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if leader == e.leader {
+		return
+	}
+	e.leader = leader
+	if leader {
+		e.lost = make(chan struct{})
+	} else {
+		close(e.lost)
+	}
+}
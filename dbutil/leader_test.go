@@ -0,0 +1,37 @@
+package dbutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderElectorLost(t *testing.T) {
+	e := NewLeaderElector(nil, "scheduler")
+	assert.Equal(t, AdvisoryLockKey("scheduler"), e.Key)
+	assert.False(t, e.IsLeader())
+	assertClosed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+	assert.True(t, assertClosed(e.Lost()))
+	e.setLeader(true)
+	assert.True(t, e.IsLeader())
+	lost := e.Lost()
+	assert.False(t, assertClosed(lost))
+	e.setLeader(false)
+	assert.False(t, e.IsLeader())
+	assert.True(t, assertClosed(lost))
+}
+
+func TestLeaderElectorInterval(t *testing.T) {
+	e := &LeaderElector{}
+	assert.Equal(t, DefaultLeaderInterval, e.interval())
+	e.Interval = time.Second
+	assert.Equal(t, time.Second, e.interval())
+}
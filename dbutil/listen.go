@@ -0,0 +1,117 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+// Notification is a message received on a LISTEN channel.
+type Notification struct {
+	Channel string
+	Payload string
+	PID     uint32
+}
+
+// Decode unmarshals a JSON payload into dest.
+func (n Notification) Decode(dest interface{}) error {
+	return json.Unmarshal([]byte(n.Payload), dest)
+}
+
+// Notify sends payload, encoded as JSON, on channel.
+func Notify(ctx context.Context, db sqlx.ExecerContext, channel string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, string(b))
+	return err
+}
+
+// Listener holds a dedicated pool connection subscribed to one or more channels,
+// reconnecting with exponential backoff if the connection is lost.
+type Listener struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	pool       *pgxpool.Pool
+	channels   []string
+}
+
+func NewListener(pool *pgxpool.Pool, channels ...string) *Listener {
+	return &Listener{
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+		pool:       pool,
+		channels:   channels,
+	}
+}
+
+// Listen delivers notifications to cb until the context is done.
+func (l *Listener) Listen(ctx context.Context, cb func(Notification)) error {
+	backoff := l.MinBackoff
+	for {
+		connected, err := l.listen(ctx, cb)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = l.MinBackoff
+		}
+		logger(ctx).Error().Err(err).Str("backoff", backoff.String()).Msg("listener: connection lost, reconnecting")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+		if backoff > l.MaxBackoff {
+			backoff = l.MaxBackoff
+		}
+	}
+}
+
+// Notifications runs Listen in the background and delivers notifications on the returned channel.
+// The channel is closed when the context is done.
+func (l *Listener) Notifications(ctx context.Context) <-chan Notification {
+	ch := make(chan Notification)
+	go func() {
+		defer close(ch)
+		l.Listen(ctx, func(n Notification) {
+			select {
+			case ch <- n:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return ch
+}
+
+func (l *Listener) listen(ctx context.Context, cb func(Notification)) (bool, error) {
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		// Connections are returned to the pool, so clear subscriptions first
+		if _, err := conn.Exec(context.Background(), "UNLISTEN *"); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}()
+	for _, channel := range l.channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return false, err
+		}
+	}
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		cb(Notification{Channel: n.Channel, Payload: n.Payload, PID: n.PID})
+	}
+}
@@ -0,0 +1,91 @@
+package dbutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Loader coalesces concurrent loads by key within a short window into a single batched fetch.
+type Loader[K comparable, V any] struct {
+	Wait     time.Duration
+	MaxBatch int
+	fetch    func(context.Context, []K) (map[K]V, error)
+	batch    *loaderBatch[K, V]
+	lock     sync.Mutex
+}
+
+type loaderBatch[K comparable, V any] struct {
+	keys    []K
+	seen    map[K]bool
+	done    chan struct{}
+	results map[K]V
+	err     error
+}
+
+func NewLoader[K comparable, V any](wait time.Duration, maxBatch int, fetch func(context.Context, []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{Wait: wait, MaxBatch: maxBatch, fetch: fetch}
+}
+
+// NewIDLoader returns a Loader that fetches rows of T by id using q with an added "id = ANY(...)" condition.
+func NewIDLoader[T any](db sqlx.Ext, q sq.SelectBuilder, wait time.Duration, maxBatch int, id func(T) int) *Loader[int, T] {
+	return NewLoader(wait, maxBatch, func(ctx context.Context, ids []int) (map[int]T, error) {
+		var rows []T
+		if err := Select(ctx, db, q.Where("id = ANY(?)", ids), &rows); err != nil {
+			return nil, err
+		}
+		ret := make(map[int]T, len(rows))
+		for _, row := range rows {
+			ret[id(row)] = row
+		}
+		return ret, nil
+	})
+}
+
+// Load returns the value for key, waiting for the batch containing it to be fetched.
+// The second return value is false if the fetch returned no value for key.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.lock.Lock()
+	b := l.batch
+	if b == nil {
+		b = &loaderBatch[K, V]{seen: map[K]bool{}, done: make(chan struct{})}
+		l.batch = b
+		// The batch is shared, so it should not be canceled with the first caller
+		go l.run(context.WithoutCancel(ctx), b)
+	}
+	if !b.seen[key] {
+		b.seen[key] = true
+		b.keys = append(b.keys, key)
+	}
+	if l.MaxBatch > 0 && len(b.keys) >= l.MaxBatch {
+		l.batch = nil
+	}
+	l.lock.Unlock()
+
+	var zero V
+	select {
+	case <-ctx.Done():
+		return zero, false, ctx.Err()
+	case <-b.done:
+	}
+	if b.err != nil {
+		return zero, false, b.err
+	}
+	v, ok := b.results[key]
+	return v, ok, nil
+}
+
+func (l *Loader[K, V]) run(ctx context.Context, b *loaderBatch[K, V]) {
+	time.Sleep(l.Wait)
+	l.lock.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	keys := b.keys
+	l.lock.Unlock()
+	b.results, b.err = l.fetch(ctx, keys)
+	close(b.done)
+}
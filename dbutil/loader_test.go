@@ -0,0 +1,40 @@
+package dbutil
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader(t *testing.T) {
+	calls := int32(0)
+	l := NewLoader(10*time.Millisecond, 0, func(ctx context.Context, keys []int) (map[int]string, error) {
+		atomic.AddInt32(&calls, 1)
+		ret := map[int]string{}
+		for _, k := range keys {
+			if k%2 == 0 {
+				ret[k] = "even"
+			}
+		}
+		return ret, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, ok, err := l.Load(context.Background(), i%10)
+			assert.NoError(t, err)
+			assert.Equal(t, i%2 == 0, ok)
+			if ok {
+				assert.Equal(t, "even", v)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,76 @@
+package dbutil
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// AdvisoryLockKey returns a stable advisory lock key for a string, e.g. a feed onestop id.
+func AdvisoryLockKey(v string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	return int64(h.Sum64())
+}
+
+// WithAdvisoryLock runs cb in a transaction holding the advisory lock for key,
+// waiting until the lock is available. The lock is released when the transaction ends.
+func WithAdvisoryLock(ctx context.Context, db *sqlx.DB, key int64, cb func(*sqlx.Tx) error) error {
+	return Tx(ctx, db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", key); err != nil {
+			return err
+		}
+		return cb(tx)
+	})
+}
+
+// TryWithAdvisoryLock runs cb in a transaction holding the advisory lock for key.
+// If the lock is held elsewhere, cb is not run and false is returned.
+func TryWithAdvisoryLock(ctx context.Context, db *sqlx.DB, key int64, cb func(*sqlx.Tx) error) (bool, error) {
+	locked := false
+	err := Tx(ctx, db, func(tx *sqlx.Tx) error {
+		if err := tx.QueryRowxContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", key).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		return cb(tx)
+	})
+	return locked, err
+}
+
+// RowLock is a row-level locking clause for SELECT.
+type RowLock string
+
+const (
+	ForUpdate      RowLock = "FOR UPDATE"
+	ForNoKeyUpdate RowLock = "FOR NO KEY UPDATE"
+	ForShare       RowLock = "FOR SHARE"
+	ForKeyShare    RowLock = "FOR KEY SHARE"
+)
+
+// LockWait controls how a row locking clause handles rows locked by other transactions.
+type LockWait string
+
+const (
+	LockWaitDefault LockWait = ""
+	SkipLocked      LockWait = "SKIP LOCKED"
+	NoWait          LockWait = "NOWAIT"
+)
+
+// WithRowLock appends a row locking clause to q, optionally limited to the given tables.
+// Row locks are only held until the end of the transaction, so q should be run inside Tx.
+func WithRowLock(q sq.SelectBuilder, lock RowLock, wait LockWait, tables ...string) sq.SelectBuilder {
+	clause := string(lock)
+	if len(tables) > 0 {
+		clause = clause + " OF " + strings.Join(tables, ", ")
+	}
+	if wait != LockWaitDefault {
+		clause = clause + " " + string(wait)
+	}
+	return q.Suffix(clause)
+}
@@ -0,0 +1,29 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRowLock(t *testing.T) {
+	q := sq.Select("*").From("jobs").Where("status = ?", "queued").Limit(10)
+	tcs := []struct {
+		lock   RowLock
+		wait   LockWait
+		tables []string
+		expect string
+	}{
+		{ForUpdate, LockWaitDefault, nil, "SELECT * FROM jobs WHERE status = ? LIMIT 10 FOR UPDATE"},
+		{ForUpdate, SkipLocked, nil, "SELECT * FROM jobs WHERE status = ? LIMIT 10 FOR UPDATE SKIP LOCKED"},
+		{ForNoKeyUpdate, NoWait, []string{"jobs"}, "SELECT * FROM jobs WHERE status = ? LIMIT 10 FOR NO KEY UPDATE OF jobs NOWAIT"},
+	}
+	for _, tc := range tcs {
+		qstr, _, err := WithRowLock(q, tc.lock, tc.wait, tc.tables...).ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.expect, qstr)
+	}
+}
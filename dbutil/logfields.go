@@ -0,0 +1,25 @@
+package dbutil
+
+import (
+	"context"
+)
+
+type logFieldsContextKey struct{}
+
+// WithLogFields returns a context whose fields, e.g. request_id or feed_onestop_id,
+// are added to query log lines. Fields are merged with those of any parent context.
+func WithLogFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	merged := map[string]interface{}{}
+	for k, v := range logFieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, logFieldsContextKey{}, merged)
+}
+
+func logFieldsFromContext(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(logFieldsContextKey{}).(map[string]interface{})
+	return fields
+}
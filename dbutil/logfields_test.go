@@ -0,0 +1,16 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogFields(t *testing.T) {
+	ctx := WithLogFields(context.Background(), map[string]interface{}{"request_id": "abc", "user": "a"})
+	child := WithLogFields(ctx, map[string]interface{}{"user": "b", "feed_onestop_id": "f-test"})
+	assert.Equal(t, map[string]interface{}{"request_id": "abc", "user": "a"}, logFieldsFromContext(ctx))
+	assert.Equal(t, map[string]interface{}{"request_id": "abc", "user": "b", "feed_onestop_id": "f-test"}, logFieldsFromContext(child))
+	assert.Nil(t, logFieldsFromContext(context.Background()))
+}
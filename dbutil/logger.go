@@ -0,0 +1,43 @@
+package dbutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/interline-io/log"
+	"github.com/rs/zerolog"
+)
+
+var (
+	pkgLogger     *zerolog.Logger
+	pkgLoggerLock sync.RWMutex
+)
+
+// SetLogger sets the logger used by this package, e.g. to route database logs to a separate sink
+// or set their level. By default, the interline-io/log global logger is used.
+func SetLogger(l zerolog.Logger) {
+	pkgLoggerLock.Lock()
+	defer pkgLoggerLock.Unlock()
+	pkgLogger = &l
+}
+
+type loggerContextKey struct{}
+
+// WithLogger returns a context whose queries and transactions log to l instead of the package logger,
+// e.g. to silence a noisy job or to log one component at a different level.
+func WithLogger(ctx context.Context, l zerolog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, &l)
+}
+
+// logger returns the logger from ctx, the logger set by SetLogger, or the global logger.
+func logger(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(loggerContextKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	pkgLoggerLock.RLock()
+	defer pkgLoggerLock.RUnlock()
+	if pkgLogger != nil {
+		return pkgLogger
+	}
+	return &log.Logger
+}
@@ -0,0 +1,20 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/interline-io/log"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	assert.Equal(t, &log.Logger, logger(context.Background()))
+
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), zerolog.New(&buf))
+	logger(ctx).Error().Msg("test")
+	assert.Contains(t, buf.String(), `"message":"test"`)
+}
@@ -0,0 +1,39 @@
+package dbutil
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// entMapper maps struct fields to columns using the same rules as OpenDB.
+var entMapper = reflectx.NewMapperFunc("db", toSnakeCase)
+
+// entColumns returns the mapped column fields of a struct type, flattening embedded structs
+// but not descending into other struct-valued fields.
+func entColumns(t reflect.Type) []*reflectx.FieldInfo {
+	var ret []*reflectx.FieldInfo
+	for _, fi := range entMapper.TypeMap(reflectx.Deref(t)).Index {
+		if fi.Embedded || fi.Name == "" || fi.Name == "-" || strings.Contains(fi.Path, ".") {
+			continue
+		}
+		ret = append(ret, fi)
+	}
+	return ret
+}
+
+// entValues returns the column values of a struct, or pointer to struct, keyed by column name.
+// Values of fields with the sensitive tag option are wrapped in Sensitive.
+func entValues(ent interface{}) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(ent))
+	ret := map[string]interface{}{}
+	for _, fi := range entColumns(v.Type()) {
+		val := reflectx.FieldByIndexesReadOnly(v, fi.Index).Interface()
+		if _, ok := fi.Options["sensitive"]; ok {
+			val = Sensitive{Val: val}
+		}
+		ret[fi.Name] = val
+	}
+	return ret
+}
@@ -0,0 +1,185 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// MergeMinVersion is the first server_version_num that supports MERGE.
+const MergeMinVersion = 150000
+
+// MergeBuilder reconciles rows from a source relation into a target table:
+// source rows matching a target row on the key columns update it, and others are inserted.
+type MergeBuilder struct {
+	target      string
+	source      sq.Sqlizer
+	sourceAlias string
+	keys        []string
+	columns     []string
+}
+
+// Merge starts a MergeBuilder for the target table.
+func Merge(target string) MergeBuilder {
+	return MergeBuilder{target: target}
+}
+
+// Using sets the source relation and the alias it is referred to by,
+// e.g. a Values or Unnest expression, a table name, or a subquery in parentheses.
+// Untyped Values parameters are resolved as text, so Values sources should give
+// column types, e.g. "id int", for keys and columns that are not text.
+func (m MergeBuilder) Using(source sq.Sqlizer, alias string) MergeBuilder {
+	m.source = source
+	m.sourceAlias = alias
+	return m
+}
+
+// On sets the key columns used to match source rows to target rows.
+func (m MergeBuilder) On(keys ...string) MergeBuilder {
+	m.keys = keys
+	return m
+}
+
+// Columns sets the columns copied from the source. Key columns are inserted but never updated.
+func (m MergeBuilder) Columns(cols ...string) MergeBuilder {
+	m.columns = cols
+	return m
+}
+
+func (m MergeBuilder) check() error {
+	if m.target == "" || m.source == nil || m.sourceAlias == "" {
+		return errors.New("merge requires a target and a source")
+	}
+	if len(m.keys) == 0 || len(m.columns) == 0 {
+		return errors.New("merge requires key columns and columns")
+	}
+	return nil
+}
+
+func (m MergeBuilder) updateColumns() []string {
+	var ret []string
+	for _, col := range m.columns {
+		isKey := false
+		for _, key := range m.keys {
+			if col == key {
+				isKey = true
+			}
+		}
+		if !isKey {
+			ret = append(ret, col)
+		}
+	}
+	return ret
+}
+
+// sourceColumn returns the quoted reference to col of the source.
+func (m MergeBuilder) sourceColumn(col string) string {
+	return QuoteIdentifier(m.sourceAlias) + "." + QuoteIdentifier(col)
+}
+
+func quoteIdentifiers(names []string) string {
+	var quoted []string
+	for _, name := range names {
+		quoted = append(quoted, QuoteIdentifier(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (m MergeBuilder) sourceSql() (string, []interface{}, error) {
+	source := m.source
+	if sb, ok := source.(sq.SelectBuilder); ok {
+		source = sq.Alias(sb.PlaceholderFormat(sq.Question), QuoteIdentifier(m.sourceAlias))
+	}
+	return source.ToSql()
+}
+
+// ToSql implements sq.Sqlizer, returning a MERGE statement, which requires Postgres 15 or later.
+// The source should use ? placeholders; the statement uses $n placeholders.
+func (m MergeBuilder) ToSql() (string, []interface{}, error) {
+	if err := m.check(); err != nil {
+		return "", nil, err
+	}
+	sstr, sargs, err := m.sourceSql()
+	if err != nil {
+		return "", nil, err
+	}
+	var on, sets, vals []string
+	for _, key := range m.keys {
+		on = append(on, fmt.Sprintf("t.%s = %s", QuoteIdentifier(key), m.sourceColumn(key)))
+	}
+	for _, col := range m.updateColumns() {
+		sets = append(sets, fmt.Sprintf("%s = %s", QuoteIdentifier(col), m.sourceColumn(col)))
+	}
+	for _, col := range m.columns {
+		vals = append(vals, m.sourceColumn(col))
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("MERGE INTO %s AS t USING %s ON %s", QuoteIdentifier(m.target), sstr, strings.Join(on, " AND ")))
+	if len(sets) > 0 {
+		sb.WriteString(" WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", "))
+	}
+	sb.WriteString(fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", quoteIdentifiers(m.columns), strings.Join(vals, ", ")))
+	qstr, err := sq.Dollar.ReplacePlaceholders(sb.String())
+	return qstr, sargs, err
+}
+
+// UpsertSql returns the equivalent INSERT ... ON CONFLICT DO UPDATE statement for older servers.
+// Unlike MERGE, this requires a unique constraint on the key columns.
+func (m MergeBuilder) UpsertSql() (string, []interface{}, error) {
+	if err := m.check(); err != nil {
+		return "", nil, err
+	}
+	sstr, sargs, err := m.sourceSql()
+	if err != nil {
+		return "", nil, err
+	}
+	var vals, sets []string
+	for _, col := range m.columns {
+		vals = append(vals, m.sourceColumn(col))
+	}
+	for _, col := range m.updateColumns() {
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", QuoteIdentifier(col), QuoteIdentifier(col)))
+	}
+	action := "DO NOTHING"
+	if len(sets) > 0 {
+		action = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	qstr, err := sq.Dollar.ReplacePlaceholders(fmt.Sprintf(
+		"INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) %s",
+		QuoteIdentifier(m.target),
+		quoteIdentifiers(m.columns),
+		strings.Join(vals, ", "),
+		sstr,
+		quoteIdentifiers(m.keys),
+		action,
+	))
+	return qstr, sargs, err
+}
+
+// ServerVersion returns the server_version_num of the server handling queries for db, e.g. 150004.
+func ServerVersion(ctx context.Context, db sqlx.Ext) (int, error) {
+	var version int
+	err := Get(ctx, db, sq.Select("current_setting('server_version_num')::int"), &version)
+	return version, err
+}
+
+// ExecMerge runs m as a MERGE statement on Postgres 15 or later, or as an upsert on older servers,
+// and returns the number of rows inserted or updated.
+func ExecMerge(ctx context.Context, db sqlx.Ext, m MergeBuilder) (int64, error) {
+	version, err := ServerVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if version >= MergeMinVersion {
+		return execRowsAffected(ctx, db, m)
+	}
+	qstr, qargs, err := m.UpsertSql()
+	if err != nil {
+		return 0, err
+	}
+	return execRowsAffected(ctx, db, sq.Expr(qstr, qargs...))
+}
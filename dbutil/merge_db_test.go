@@ -0,0 +1,53 @@
+package dbutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecMergeDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	testutil.TestTx(t, db, func(tx *sqlx.Tx) {
+		if _, err := tx.ExecContext(ctx, `CREATE TEMPORARY TABLE "MergeStops" (id int PRIMARY KEY, stop_name text) ON COMMIT DROP`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO "MergeStops" VALUES (1, 'a')`); err != nil {
+			t.Fatal(err)
+		}
+		names := func() (ret []string) {
+			if err := tx.SelectContext(ctx, &ret, `SELECT stop_name FROM "MergeStops" ORDER BY id`); err != nil {
+				t.Fatal(err)
+			}
+			return ret
+		}
+
+		// Typed Values compare and insert against the integer key
+		values, err := dbutil.Values("s", []string{"id int", "stop_name text"}, [][]interface{}{{1, "A"}, {2, "B"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := dbutil.ExecMerge(ctx, tx, dbutil.Merge("MergeStops").Using(values, "s").On("id").Columns("id", "stop_name"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+		assert.Equal(t, []string{"A", "B"}, names())
+
+		unnest, err := dbutil.Unnest("s", []string{"id int", "stop_name text"}, []int{2, 3}, []string{"BB", "C"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err = dbutil.ExecMerge(ctx, tx, dbutil.Merge("MergeStops").Using(unnest, "s").On("id").Columns("id", "stop_name"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+		assert.Equal(t, []string{"A", "BB", "C"}, names())
+	})
+}
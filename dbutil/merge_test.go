@@ -0,0 +1,38 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeBuilder(t *testing.T) {
+	source, err := Values("s", []string{"stop_id", "stop_name text"}, [][]interface{}{{"a", "A"}, {"b", "B"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Merge("gtfs_stops").Using(source, "s").On("stop_id").Columns("stop_id", "stop_name")
+	t.Run("merge", func(t *testing.T) {
+		qstr, qargs, err := m.ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `MERGE INTO "gtfs_stops" AS t USING (VALUES ($1,$2::text), ($3,$4::text)) AS "s"("stop_id", "stop_name") ON t."stop_id" = "s"."stop_id" WHEN MATCHED THEN UPDATE SET "stop_name" = "s"."stop_name" WHEN NOT MATCHED THEN INSERT ("stop_id", "stop_name") VALUES ("s"."stop_id", "s"."stop_name")`, qstr)
+		assert.Equal(t, []interface{}{"a", "A", "b", "B"}, qargs)
+	})
+	t.Run("upsert", func(t *testing.T) {
+		qstr, qargs, err := m.UpsertSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO "gtfs_stops" ("stop_id", "stop_name") SELECT "s"."stop_id", "s"."stop_name" FROM (VALUES ($1,$2::text), ($3,$4::text)) AS "s"("stop_id", "stop_name") ON CONFLICT ("stop_id") DO UPDATE SET "stop_name" = excluded."stop_name"`, qstr)
+		assert.Equal(t, []interface{}{"a", "A", "b", "B"}, qargs)
+	})
+	t.Run("select source", func(t *testing.T) {
+		q := sq.Select("stop_id").From("import_stops").Where("feed_id = ?", 1).PlaceholderFormat(sq.Dollar)
+		qstr, _, err := Merge("gtfs_stops").Using(q, "s").On("stop_id").Columns("stop_id").ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, `MERGE INTO "gtfs_stops" AS t USING (SELECT stop_id FROM import_stops WHERE feed_id = $1) AS "s" ON t."stop_id" = "s"."stop_id" WHEN NOT MATCHED THEN INSERT ("stop_id") VALUES ("s"."stop_id")`, qstr)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := Merge("gtfs_stops").Using(source, "s").ToSql()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+)
+
+// QueryInfo describes a query passed through middleware.
+// Middleware may rewrite Query and Args before calling the next handler.
+// Query uses Postgres $n placeholders, so added arguments must be numbered after Args.
+type QueryInfo struct {
+	Query string
+	Args  []interface{}
+	// Dest is the destination for Select and Get, or nil for Exec.
+	Dest interface{}
+	// Result is set by Exec after the statement runs.
+	Result sql.Result
+}
+
+// QueryHandler runs a query.
+type QueryHandler func(ctx context.Context, qi *QueryInfo) error
+
+// Middleware wraps the execution of queries by Select, Get, Exec, and the named and insert variants.
+// Middleware runs after query building and validation, and inside the query timeout, journal, and logging.
+type Middleware func(next QueryHandler) QueryHandler
+
+var middleware []Middleware
+var middlewareLock sync.RWMutex
+
+// SetMiddleware sets the middleware chain, outermost first, or clears it if called with none.
+func SetMiddleware(mw ...Middleware) {
+	middlewareLock.Lock()
+	defer middlewareLock.Unlock()
+	middleware = mw
+}
+
+// runMiddleware runs exec through the middleware chain.
+// Write statements are skipped in a dry run context.
+func runMiddleware(ctx context.Context, qi *QueryInfo, exec QueryHandler) error {
+	middlewareLock.RLock()
+	mw := middleware
+	middlewareLock.RUnlock()
+	h := func(ctx context.Context, qi *QueryInfo) error {
+		if dryRunSkip(ctx, qi) {
+			return nil
+		}
+		return exec(ctx, qi)
+	}
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h(ctx, qi)
+}
+
+// RetryMiddleware retries queries that fail with transient errors according to p.
+// Writes are retried as well, so use it only where statements are idempotent.
+func RetryMiddleware(p RetryPolicy) Middleware {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			return p.Do(ctx, func(ctx context.Context) error {
+				// sqlx appends to slices, so discard any rows from a failed attempt
+				if v := reflect.ValueOf(qi.Dest); v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Slice {
+					v.Elem().SetLen(0)
+				}
+				return next(ctx, qi)
+			})
+		}
+	}
+}
+
+// Interceptor inspects a query before it runs and returns the query and args to run instead,
+// e.g. to add an index hint or a tenant filter. Returning an error blocks the query.
+// The query uses $n placeholders, so a filter on an added argument is written as
+// fmt.Sprintf(" AND tenant_id = $%d", len(args)+1).
+type Interceptor func(ctx context.Context, query string, args []interface{}) (string, []interface{}, error)
+
+// InterceptMiddleware returns middleware that passes each query through fn before it runs.
+func InterceptMiddleware(fn Interceptor) Middleware {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			query, args, err := fn(ctx, qi.Query, qi.Args)
+			if err != nil {
+				return err
+			}
+			qi.Query, qi.Args = query, args
+			return next(ctx, qi)
+		}
+	}
+}
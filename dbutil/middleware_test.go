@@ -0,0 +1,57 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunMiddleware(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next QueryHandler) QueryHandler {
+			return func(ctx context.Context, qi *QueryInfo) error {
+				calls = append(calls, name)
+				qi.Query = qi.Query + " /* " + name + " */"
+				return next(ctx, qi)
+			}
+		}
+	}
+	SetMiddleware(mw("a"), mw("b"))
+	defer SetMiddleware()
+	var executed string
+	err := runMiddleware(context.Background(), &QueryInfo{Query: "SELECT 1"}, func(ctx context.Context, qi *QueryInfo) error {
+		executed = qi.Query
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, calls)
+	assert.Equal(t, "SELECT 1 /* a */ /* b */", executed)
+}
+
+func TestInterceptMiddleware(t *testing.T) {
+	blocked := errors.New("blocked")
+	ic := func(ctx context.Context, query string, args []interface{}) (string, []interface{}, error) {
+		if strings.HasPrefix(query, "DROP") {
+			return "", nil, blocked
+		}
+		return query + fmt.Sprintf(" AND tenant_id = $%d", len(args)+1), append(args, 1), nil
+	}
+	SetMiddleware(InterceptMiddleware(ic))
+	defer SetMiddleware()
+	var executed *QueryInfo
+	exec := func(ctx context.Context, qi *QueryInfo) error {
+		executed = qi
+		return nil
+	}
+	assert.NoError(t, runMiddleware(context.Background(), &QueryInfo{Query: "SELECT * FROM t WHERE id = $1", Args: []interface{}{2}}, exec))
+	assert.Equal(t, "SELECT * FROM t WHERE id = $1 AND tenant_id = $2", executed.Query)
+	assert.Equal(t, []interface{}{2, 1}, executed.Args)
+	executed = nil
+	assert.ErrorIs(t, runMiddleware(context.Background(), &QueryInfo{Query: "DROP TABLE t"}, exec), blocked)
+	assert.Nil(t, executed)
+}
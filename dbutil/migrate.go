@@ -0,0 +1,98 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// MigrationsTable records the migrations applied by Migrate.
+var MigrationsTable = "dbutil_migrations"
+
+// Migrate applies the .sql files in dir that have not yet been applied, in lexical order.
+// Each file runs in its own transaction and is recorded by file name in MigrationsTable.
+// Returns the names of the applied files.
+func Migrate(ctx context.Context, db *sqlx.DB, dir string) ([]string, error) {
+	ctx = AllowDDL(ctx)
+	if _, err := Exec(ctx, db, RawSQL(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version text PRIMARY KEY, applied_at timestamptz NOT NULL DEFAULT now())",
+		QuoteIdentifier(MigrationsTable),
+	))); err != nil {
+		return nil, err
+	}
+	var applied []string
+	if err := Select(ctx, db, sq.Select("version").From(QuoteIdentifier(MigrationsTable)), &applied); err != nil {
+		return nil, err
+	}
+	appliedSet := map[string]bool{}
+	for _, v := range applied {
+		appliedSet[v] = true
+	}
+	fns, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fns)
+	var ret []string
+	for _, fn := range fns {
+		version := strings.TrimSuffix(filepath.Base(fn), ".sql")
+		if appliedSet[version] {
+			continue
+		}
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return ret, err
+		}
+		logger(ctx).Info().Str("version", version).Msg("applying migration")
+		if err := Tx(ctx, db, func(tx *sqlx.Tx) error {
+			// Run as a single simple-protocol statement so files may contain multiple statements
+			if _, err := tx.ExecContext(ctx, string(data)); err != nil {
+				return fmt.Errorf("migration %s: %w", version, err)
+			}
+			_, err := Exec(ctx, tx, sq.Insert(QuoteIdentifier(MigrationsTable)).Columns("version").Values(version))
+			return err
+		}); err != nil {
+			return ret, err
+		}
+		ret = append(ret, version)
+	}
+	return ret, nil
+}
+
+// TableExists returns true if the possibly schema-qualified table exists.
+func TableExists(ctx context.Context, db sqlx.Ext, table string) (bool, error) {
+	var exists bool
+	err := Get(ctx, db, sq.Select().Column("to_regclass(?) IS NOT NULL", QuoteIdentifier(table)), &exists)
+	return exists, err
+}
+
+// TableStat is size and activity information for a table.
+type TableStat struct {
+	Schema     string `db:"schema" json:"schema"`
+	Table      string `db:"table" json:"table"`
+	LiveRows   int64  `db:"live_rows" json:"live_rows"`
+	DeadRows   int64  `db:"dead_rows" json:"dead_rows"`
+	TotalBytes int64  `db:"total_bytes" json:"total_bytes"`
+}
+
+// GetTableStats returns row counts and sizes for user tables, largest first.
+func GetTableStats(ctx context.Context, db sqlx.Ext) ([]TableStat, error) {
+	var ret []TableStat
+	q := sq.Select(
+		"schemaname AS schema",
+		"relname AS table",
+		"n_live_tup AS live_rows",
+		"n_dead_tup AS dead_rows",
+		"pg_total_relation_size(relid) AS total_bytes",
+	).
+		From("pg_stat_user_tables").
+		OrderBy("total_bytes DESC")
+	err := Select(ctx, db, q, &ret)
+	return ret, err
+}
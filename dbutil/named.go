@@ -0,0 +1,113 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SelectNamed runs a query with :named parameters bound from arg (a struct or map) and reads results into dest.
+func SelectNamed(ctx context.Context, db sqlx.Ext, query string, arg interface{}, dest interface{}) error {
+	t := time.Now()
+	ctx, cancel := withQueryTimeout(ctx, ReadStatement)
+	defer cancel()
+	qstr, qargs, err := bindNamed(ctx, db, query, arg)
+	if err == nil {
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		err = runMiddleware(ctx, &QueryInfo{Query: qstr, Args: qargs, Dest: dest}, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.QueryerContext); ok {
+				err = sqlx.SelectContext(ctx, a, dest, qstr, qargs...)
+			} else {
+				err = sqlx.Select(db, dest, qstr, qargs...)
+			}
+			return err
+		})
+	}
+	logQuery(ctx, qstr, qargs, t, sliceLen(dest), err)
+	return err
+}
+
+// GetNamed runs a query with :named parameters bound from arg (a struct or map) and reads a single result into dest.
+func GetNamed(ctx context.Context, db sqlx.Ext, query string, arg interface{}, dest interface{}) error {
+	t := time.Now()
+	ctx, cancel := withQueryTimeout(ctx, ReadStatement)
+	defer cancel()
+	qstr, qargs, err := bindNamed(ctx, db, query, arg)
+	if err == nil {
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		err = runMiddleware(ctx, &QueryInfo{Query: qstr, Args: qargs, Dest: dest}, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.QueryerContext); ok {
+				err = sqlx.GetContext(ctx, a, dest, qstr, qargs...)
+			} else {
+				err = sqlx.Get(db, dest, qstr, qargs...)
+			}
+			return err
+		})
+	}
+	rows := int64(0)
+	if err == nil {
+		rows = 1
+	}
+	logQuery(ctx, qstr, qargs, t, rows, err)
+	return err
+}
+
+// ExecNamed runs a statement with :named parameters bound from arg (a struct or map) and returns the result.
+func ExecNamed(ctx context.Context, db sqlx.Ext, query string, arg interface{}) (sql.Result, error) {
+	t := time.Now()
+	var res sql.Result
+	qstr, qargs, err := bindNamed(ctx, db, query, arg)
+	if err == nil {
+		err = checkDDL(ctx, qstr)
+	}
+	if err == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = withQueryTimeout(ctx, ClassifyStatement(qstr))
+		defer cancel()
+		ctx, done := trackQuery(ctx, qstr)
+		defer done()
+		qi := &QueryInfo{Query: qstr, Args: qargs}
+		err = runMiddleware(ctx, qi, func(ctx context.Context, qi *QueryInfo) error {
+			qstr, qargs = qi.Query, qi.Args
+			var err error
+			if a, ok := db.(sqlx.ExecerContext); ok {
+				qi.Result, err = a.ExecContext(ctx, qstr, qargs...)
+			} else {
+				qi.Result, err = db.Exec(qstr, qargs...)
+			}
+			return err
+		})
+		res = qi.Result
+	}
+	rows := int64(0)
+	if res != nil {
+		rows, _ = res.RowsAffected()
+	}
+	logQuery(ctx, qstr, qargs, t, rows, err)
+	return res, err
+}
+
+func bindNamed(ctx context.Context, db sqlx.Ext, query string, arg interface{}) (string, []interface{}, error) {
+	qstr, qargs, err := db.BindNamed(query, arg)
+	if err == nil {
+		err = checkBindParams(qargs)
+	}
+	if err == nil {
+		err = checkShutdown(db)
+	}
+	if err == nil {
+		err = checkSchema(ctx, db)
+	}
+	if err != nil {
+		return query, nil, err
+	}
+	return qstr, qargs, nil
+}
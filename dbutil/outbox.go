@@ -0,0 +1,120 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxMessage is a message stored in an outbox table.
+type OutboxMessage struct {
+	ID        int64
+	Topic     string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Decode unmarshals the JSON payload into dest.
+func (m OutboxMessage) Decode(dest interface{}) error {
+	return json.Unmarshal(m.Payload, dest)
+}
+
+// Outbox implements the transactional outbox pattern: messages are written in the same
+// transaction as the entity changes they describe, and later relayed to a publisher
+// with at-least-once semantics. Expected schema:
+//
+//	CREATE TABLE dbutil_outbox (
+//		id bigserial PRIMARY KEY,
+//		topic text NOT NULL,
+//		payload jsonb NOT NULL,
+//		created_at timestamptz NOT NULL DEFAULT now()
+//	);
+type Outbox struct {
+	Table        string
+	BatchSize    int
+	PollInterval time.Duration
+	// Channel, if set, is notified on Enqueue; pass a Listener on the same channel to Relay to wake it.
+	Channel string
+}
+
+func NewOutbox(table string) *Outbox {
+	return &Outbox{
+		Table:        table,
+		BatchSize:    100,
+		PollInterval: 5 * time.Second,
+	}
+}
+
+// Enqueue writes a message to the outbox. db should be the transaction that writes the related changes.
+func (o *Outbox) Enqueue(ctx context.Context, db sqlx.Ext, topic string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := Exec(ctx, db, sq.Insert(o.Table).Columns("topic", "payload").Values(topic, string(b))); err != nil {
+		return err
+	}
+	if o.Channel != "" {
+		if _, err := Exec(ctx, db, sq.Expr("SELECT pg_notify(?, ?)", o.Channel, topic)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Relay publishes pending messages in order until the context is done.
+// A message is removed only after publish succeeds; if publish fails, the batch is retried later.
+// If listener is not nil, Relay also wakes on its notifications instead of waiting for the next poll.
+func (o *Outbox) Relay(ctx context.Context, db *sqlx.DB, listener *Listener, publish func(context.Context, OutboxMessage) error) error {
+	var wake <-chan Notification
+	if listener != nil {
+		wake = listener.Notifications(ctx)
+	}
+	for {
+		n, err := o.RelayBatch(ctx, db, publish)
+		if err != nil {
+			logger(ctx).Error().Err(err).Str("table", o.Table).Msg("outbox: relay failed")
+		}
+		if err == nil && n == o.BatchSize {
+			// More messages are likely pending
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-time.After(o.PollInterval):
+		}
+	}
+}
+
+// RelayBatch publishes and removes up to BatchSize pending messages, returning the number relayed.
+func (o *Outbox) RelayBatch(ctx context.Context, db *sqlx.DB, publish func(context.Context, OutboxMessage) error) (int, error) {
+	count := 0
+	err := Tx(ctx, db, func(tx *sqlx.Tx) error {
+		var msgs []OutboxMessage
+		q := sq.Select("id", "topic", "payload", "created_at").From(o.Table).OrderBy("id").Limit(uint64(o.BatchSize))
+		if err := Select(ctx, tx, WithRowLock(q, ForUpdate, SkipLocked), &msgs); err != nil {
+			return err
+		}
+		var ids []int64
+		for _, msg := range msgs {
+			if err := publish(ctx, msg); err != nil {
+				return err
+			}
+			ids = append(ids, msg.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if _, err := Exec(ctx, tx, sq.Delete(o.Table).Where("id = ANY(?)", ids)); err != nil {
+			return err
+		}
+		count = len(ids)
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,25 @@
+package dbutil
+
+import (
+	"fmt"
+)
+
+// MaxBindParams is the maximum number of bind parameters in a single Postgres statement.
+const MaxBindParams = 65535
+
+// TooManyParamsError is returned when a statement has more bind parameters than Postgres allows.
+// Use fewer rows per statement, or pass large id lists as a single array parameter with Any.
+type TooManyParamsError struct {
+	Count int
+}
+
+func (e *TooManyParamsError) Error() string {
+	return fmt.Sprintf("statement has %d bind parameters, more than the maximum of %d", e.Count, MaxBindParams)
+}
+
+func checkBindParams(qargs []interface{}) error {
+	if len(qargs) > MaxBindParams {
+		return &TooManyParamsError{Count: len(qargs)}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect_TooManyParams(t *testing.T) {
+	ids := make([]interface{}, MaxBindParams+1)
+	for i := range ids {
+		ids[i] = i
+	}
+	var ret []int
+	err := Select(context.Background(), nil, sq.Select("id").From("stops").Where(sq.Eq{"id": ids}), &ret)
+	var paramErr *TooManyParamsError
+	assert.True(t, errors.As(err, &paramErr))
+	assert.Equal(t, MaxBindParams+1, paramErr.Count)
+}
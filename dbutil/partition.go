@@ -0,0 +1,83 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PartitionBound is a partition bound specification, e.g. FOR VALUES IN (1).
+type PartitionBound string
+
+// ListBound returns a list partition bound for the given keys.
+func ListBound(keys ...int) PartitionBound {
+	var vals []string
+	for _, k := range keys {
+		vals = append(vals, strconv.Itoa(k))
+	}
+	return PartitionBound(fmt.Sprintf("FOR VALUES IN (%s)", strings.Join(vals, ", ")))
+}
+
+// RangeBound returns a range partition bound from (inclusive) to (exclusive).
+func RangeBound(from int, to int) PartitionBound {
+	return PartitionBound(fmt.Sprintf("FOR VALUES FROM (%d) TO (%d)", from, to))
+}
+
+// PartitionName returns the conventional name for a partition, e.g. gtfs_stop_times_123.
+func PartitionName(parent string, key int) string {
+	return fmt.Sprintf("%s_%d", parent, key)
+}
+
+// CreatePartition creates name as a partition of parent with the given bound.
+func CreatePartition(ctx context.Context, db sqlx.Ext, parent string, name string, bound PartitionBound) error {
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf(
+		"CREATE TABLE %s PARTITION OF %s %s",
+		QuoteIdentifier(name),
+		QuoteIdentifier(parent),
+		bound,
+	)))
+	return err
+}
+
+// CreateDetachedPartition creates name as a standalone table with the same columns, defaults,
+// and constraints as parent. Load it with COPY, then use AttachPartition.
+// Adding a CHECK constraint matching the bound before attaching lets Postgres skip validating the rows.
+func CreateDetachedPartition(ctx context.Context, db sqlx.Ext, parent string, name string) error {
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf(
+		"CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)",
+		QuoteIdentifier(name),
+		QuoteIdentifier(parent),
+	)))
+	return err
+}
+
+// AttachPartition attaches an existing table to parent with the given bound.
+func AttachPartition(ctx context.Context, db sqlx.Ext, parent string, name string, bound PartitionBound) error {
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf(
+		"ALTER TABLE %s ATTACH PARTITION %s %s",
+		QuoteIdentifier(parent),
+		QuoteIdentifier(name),
+		bound,
+	)))
+	return err
+}
+
+// DetachPartition detaches a partition from parent, leaving it as a standalone table.
+// Detaching concurrently avoids blocking queries on parent but cannot run inside a transaction.
+func DetachPartition(ctx context.Context, db sqlx.Ext, parent string, name string, concurrently bool) error {
+	qstr := fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", QuoteIdentifier(parent), QuoteIdentifier(name))
+	if concurrently {
+		qstr = qstr + " CONCURRENTLY"
+	}
+	_, err := Exec(ctx, db, RawSQL(qstr))
+	return err
+}
+
+// DropPartition drops a partition, if it exists.
+func DropPartition(ctx context.Context, db sqlx.Ext, name string) error {
+	_, err := Exec(ctx, db, RawSQL(fmt.Sprintf("DROP TABLE IF EXISTS %s", QuoteIdentifier(name))))
+	return err
+}
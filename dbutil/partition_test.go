@@ -0,0 +1,14 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionBound(t *testing.T) {
+	assert.Equal(t, PartitionBound("FOR VALUES IN (1)"), ListBound(1))
+	assert.Equal(t, PartitionBound("FOR VALUES IN (1, 2, 3)"), ListBound(1, 2, 3))
+	assert.Equal(t, PartitionBound("FOR VALUES FROM (100) TO (200)"), RangeBound(100, 200))
+	assert.Equal(t, "gtfs_stop_times_123", PartitionName("gtfs_stop_times", 123))
+}
@@ -0,0 +1,23 @@
+package dbutil
+
+import (
+	"context"
+)
+
+type progressContextKey struct{}
+
+// ProgressFunc is called by bulk helpers after each chunk with the number of items done
+// and the total number of items.
+type ProgressFunc func(done, total int)
+
+// WithProgress returns a context whose bulk operations, such as DeleteByIDs and SelectChunked,
+// report progress to fn, so long imports can update job status instead of appearing hung.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, fn)
+}
+
+func reportProgress(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(progressContextKey{}).(ProgressFunc); ok && fn != nil {
+		fn(done, total)
+	}
+}
@@ -0,0 +1,19 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProgress(t *testing.T) {
+	var calls [][2]int
+	ctx := WithProgress(context.Background(), func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	})
+	reportProgress(ctx, 5, 10)
+	reportProgress(ctx, 10, 10)
+	reportProgress(context.Background(), 1, 1)
+	assert.Equal(t, [][2]int{{5, 10}, {10, 10}}, calls)
+}
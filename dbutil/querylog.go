@@ -0,0 +1,69 @@
+package dbutil
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// QueryLogOptions controls which queries are logged and how their arguments appear.
+// Failed queries are always logged. A zero value logs only failed and canceled queries.
+type QueryLogOptions struct {
+	// SampleRate is the fraction of successful queries logged at trace level, from 0 to 1.
+	SampleRate float64
+	// SlowQuery is the duration at or above which successful queries are always logged at info level.
+	SlowQuery time.Duration
+	// MaxArgLength truncates logged string and byte arguments to this many bytes.
+	MaxArgLength int
+}
+
+var queryLogOptions QueryLogOptions
+var queryLogOptionsLock sync.RWMutex
+
+// SetQueryLogOptions sets the query logging options used by Select, Get, and Exec.
+func SetQueryLogOptions(opts QueryLogOptions) {
+	queryLogOptionsLock.Lock()
+	defer queryLogOptionsLock.Unlock()
+	queryLogOptions = opts
+}
+
+func getQueryLogOptions() QueryLogOptions {
+	queryLogOptionsLock.RLock()
+	defer queryLogOptionsLock.RUnlock()
+	return queryLogOptions
+}
+
+func (opts QueryLogOptions) sampled() bool {
+	return opts.SampleRate > 0 && (opts.SampleRate >= 1 || rand.Float64() < opts.SampleRate)
+}
+
+func (opts QueryLogOptions) slow(d time.Duration) bool {
+	return opts.SlowQuery > 0 && d >= opts.SlowQuery
+}
+
+// logArgs returns query arguments as they should appear in logs,
+// with Sensitive arguments redacted and long arguments truncated.
+func (opts QueryLogOptions) logArgs(qargs []interface{}) []interface{} {
+	ret := make([]interface{}, len(qargs))
+	for i, arg := range qargs {
+		switch v := arg.(type) {
+		case Sensitive:
+			ret[i] = v.String()
+		case string:
+			ret[i] = truncateArg(v, opts.MaxArgLength)
+		case []byte:
+			ret[i] = truncateArg(string(v), opts.MaxArgLength)
+		default:
+			ret[i] = arg
+		}
+	}
+	return ret
+}
+
+func truncateArg(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d bytes)", s[:n], len(s))
+}
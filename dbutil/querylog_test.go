@@ -0,0 +1,18 @@
+package dbutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLogOptions(t *testing.T) {
+	opts := QueryLogOptions{MaxArgLength: 4, SlowQuery: time.Second}
+	assert.Equal(t, []interface{}{"abc", "abcd...(6 bytes)", "abcd...(5 bytes)", 123}, opts.logArgs([]interface{}{"abc", "abcdef", []byte("abcde"), 123}))
+	assert.True(t, opts.slow(2*time.Second))
+	assert.False(t, opts.slow(time.Millisecond))
+	assert.False(t, opts.sampled())
+	assert.True(t, QueryLogOptions{SampleRate: 1}.sampled())
+	assert.False(t, QueryLogOptions{}.slow(time.Hour))
+}
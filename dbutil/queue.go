@@ -0,0 +1,267 @@
+package dbutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Job statuses in a JobQueue table.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDead    = "dead"
+)
+
+// ErrJobLost is returned by Ack, Retry, and DeadLetter if the job's lock expired and another
+// worker claimed it, so the job now belongs to that worker and was not changed.
+var ErrJobLost = errors.New("job was claimed by another worker")
+
+// Job is a job stored in a JobQueue table.
+type Job struct {
+	ID          int64
+	Queue       string
+	Payload     []byte
+	Status      string
+	Attempts    int
+	RunAt       time.Time
+	LockedUntil *time.Time
+	LastError   *string
+	CreatedAt   time.Time
+}
+
+// Decode unmarshals the JSON payload into dest.
+func (j Job) Decode(dest interface{}) error {
+	return json.Unmarshal(j.Payload, dest)
+}
+
+// JobQueue is a database-backed job queue. Workers claim jobs with FOR UPDATE SKIP LOCKED,
+// so any number of workers can dequeue concurrently without blocking each other.
+// A claimed job that is not acked or retried before its lock expires, e.g. because
+// the worker exited, becomes available again. Expected schema:
+//
+//	CREATE TABLE dbutil_jobs (
+//		id bigserial PRIMARY KEY,
+//		queue text NOT NULL,
+//		payload jsonb NOT NULL,
+//		status text NOT NULL DEFAULT 'pending',
+//		attempts int NOT NULL DEFAULT 0,
+//		run_at timestamptz NOT NULL DEFAULT now(),
+//		locked_until timestamptz,
+//		last_error text,
+//		created_at timestamptz NOT NULL DEFAULT now()
+//	);
+//	CREATE INDEX ON dbutil_jobs (queue, run_at) WHERE status <> 'dead';
+type JobQueue struct {
+	Table string
+	// LockTimeout is how long a dequeued job is claimed before other workers may take it.
+	LockTimeout time.Duration
+	// RetryPolicy sets the attempts before a failed job is dead-lettered, and the backoff between attempts.
+	RetryPolicy  RetryPolicy
+	PollInterval time.Duration
+}
+
+func NewJobQueue(table string) *JobQueue {
+	return &JobQueue{
+		Table:       table,
+		LockTimeout: 5 * time.Minute,
+		RetryPolicy: RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     time.Hour,
+		},
+		PollInterval: 5 * time.Second,
+	}
+}
+
+// Enqueue adds a job to run as soon as possible. db may be a transaction, so jobs are only
+// visible to workers if the related changes commit.
+func (jq *JobQueue) Enqueue(ctx context.Context, db sqlx.Ext, queue string, payload interface{}) error {
+	return jq.EnqueueAt(ctx, db, queue, payload, time.Time{})
+}
+
+// EnqueueAt adds a job to run at or after runAt, or as soon as possible if runAt is zero.
+func (jq *JobQueue) EnqueueAt(ctx context.Context, db sqlx.Ext, queue string, payload interface{}, runAt time.Time) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	q := sq.Insert(QuoteIdentifier(jq.Table)).Columns("queue", "payload").Values(queue, string(b))
+	if !runAt.IsZero() {
+		q = sq.Insert(QuoteIdentifier(jq.Table)).Columns("queue", "payload", "run_at").Values(queue, string(b), runAt)
+	}
+	_, err = Exec(ctx, db, q)
+	return err
+}
+
+// Dequeue claims up to n jobs that are due, in run_at order, incrementing their attempts.
+// Each claimed job must be passed to Ack, Retry, or DeadLetter. Jobs whose lock expired
+// after their last allowed attempt, e.g. because they crash the worker, are dead-lettered
+// instead of being claimed again.
+func (jq *JobQueue) Dequeue(ctx context.Context, db *sqlx.DB, queue string, n int) ([]Job, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("dequeue count must be greater than 0, got %d", n)
+	}
+	var jobs []Job
+	err := Tx(ctx, db, func(tx *sqlx.Tx) error {
+		jobs = nil
+		if jq.RetryPolicy.MaxAttempts > 0 {
+			if _, err := Exec(ctx, tx, jq.deadLetterExpired(queue)); err != nil {
+				return err
+			}
+		}
+		q := sq.Select("id", "queue", "payload", "status", "attempts", "run_at", "locked_until", "last_error", "created_at").
+			From(QuoteIdentifier(jq.Table)).
+			Where("queue = ?", queue).
+			Where("run_at <= now()").
+			Where("(status = ? OR (status = ? AND locked_until < now()))", JobPending, JobRunning).
+			OrderBy("run_at", "id").
+			Limit(uint64(n))
+		if err := Select(ctx, tx, WithRowLock(q, ForUpdate, SkipLocked), &jobs); err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+		var ids []int64
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		lockedUntil := time.Now().Add(jq.LockTimeout)
+		update := sq.Update(QuoteIdentifier(jq.Table)).
+			Set("status", JobRunning).
+			Set("attempts", sq.Expr("attempts + 1")).
+			Set("locked_until", lockedUntil).
+			Where("id = ANY(?)", ids)
+		if _, err := Exec(ctx, tx, update); err != nil {
+			return err
+		}
+		for i := range jobs {
+			jobs[i].Status = JobRunning
+			jobs[i].Attempts++
+			jobs[i].LockedUntil = &lockedUntil
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// Ack removes a completed job.
+func (jq *JobQueue) Ack(ctx context.Context, db sqlx.Ext, job Job) error {
+	return jq.updateClaimed(ctx, db, sq.Delete(QuoteIdentifier(jq.Table)).Where(jq.claimed(job)))
+}
+
+// Retry records a failed attempt and schedules the job again after a backoff,
+// or dead-letters it if it has used all attempts.
+func (jq *JobQueue) Retry(ctx context.Context, db sqlx.Ext, job Job, jobErr error) error {
+	if jq.RetryPolicy.MaxAttempts > 0 && job.Attempts >= jq.RetryPolicy.MaxAttempts {
+		return jq.DeadLetter(ctx, db, job, jobErr)
+	}
+	q := sq.Update(QuoteIdentifier(jq.Table)).
+		Set("status", JobPending).
+		Set("run_at", time.Now().Add(jq.backoff(job.Attempts))).
+		Set("locked_until", nil).
+		Set("last_error", jobErrString(jobErr))
+	return jq.updateClaimed(ctx, db, q.Where(jq.claimed(job)))
+}
+
+// DeadLetter marks a job as dead, so it is kept for inspection but never run again.
+// Dead jobs can be requeued by setting their status back to pending.
+func (jq *JobQueue) DeadLetter(ctx context.Context, db sqlx.Ext, job Job, jobErr error) error {
+	q := sq.Update(QuoteIdentifier(jq.Table)).
+		Set("status", JobDead).
+		Set("locked_until", nil).
+		Set("last_error", jobErrString(jobErr))
+	return jq.updateClaimed(ctx, db, q.Where(jq.claimed(job)))
+}
+
+// backoff returns the delay before retrying a job that has made the given number of attempts,
+// doubling from InitialBackoff and capped at MaxBackoff, if set.
+func (jq *JobQueue) backoff(attempts int) time.Duration {
+	p := jq.RetryPolicy
+	backoff := p.InitialBackoff
+	for i := 1; i < attempts && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff = backoff * 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// deadLetterExpired marks jobs dead if their lock expired after they used all attempts.
+func (jq *JobQueue) deadLetterExpired(queue string) sq.UpdateBuilder {
+	maxAttempts := jq.RetryPolicy.MaxAttempts
+	expired := sq.Select("id").
+		From(QuoteIdentifier(jq.Table)).
+		Where("queue = ?", queue).
+		Where("status = ?", JobRunning).
+		Where("locked_until < now()").
+		Where("attempts >= ?", maxAttempts)
+	return sq.Update(QuoteIdentifier(jq.Table)).
+		Set("status", JobDead).
+		Set("locked_until", nil).
+		Set("last_error", sq.Expr("coalesce(last_error, ?)", fmt.Sprintf("lock expired after %d attempts", maxAttempts))).
+		Where(sq.Expr("id IN (?)", WithRowLock(expired, ForUpdate, SkipLocked)))
+}
+
+// claimed matches the job as claimed by Dequeue. Each claim increments
+// attempts, so a job reclaimed by another worker after its lock expired no longer matches.
+func (jq *JobQueue) claimed(job Job) sq.Sqlizer {
+	return sq.Expr("id = ? AND attempts = ? AND status = ?", job.ID, job.Attempts, JobRunning)
+}
+
+func (jq *JobQueue) updateClaimed(ctx context.Context, db sqlx.Ext, q sq.Sqlizer) error {
+	n, err := execRowsAffected(ctx, db, q)
+	if err == nil && n == 0 {
+		err = ErrJobLost
+	}
+	return err
+}
+
+// Work dequeues and handles jobs from queue until the context is done.
+// Jobs are acked if handle succeeds, and retried otherwise.
+func (jq *JobQueue) Work(ctx context.Context, db *sqlx.DB, queue string, n int, handle func(context.Context, Job) error) error {
+	if n <= 0 {
+		n = 1
+	}
+	for {
+		jobs, err := jq.Dequeue(ctx, db, queue, n)
+		if err != nil {
+			logger(ctx).Error().Err(err).Str("table", jq.Table).Str("queue", queue).Msg("job queue: dequeue failed")
+		}
+		for _, job := range jobs {
+			if jobErr := handle(ctx, job); jobErr != nil {
+				logger(ctx).Error().Err(jobErr).Int64("job_id", job.ID).Int("attempts", job.Attempts).Str("queue", queue).Msg("job queue: job failed")
+				err = jq.Retry(ctx, db, job, jobErr)
+			} else {
+				err = jq.Ack(ctx, db, job)
+			}
+			if err != nil {
+				logger(ctx).Error().Err(err).Int64("job_id", job.ID).Str("queue", queue).Msg("job queue: could not update job")
+			}
+		}
+		if err == nil && len(jobs) == n {
+			// More jobs are likely due
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jq.PollInterval):
+		}
+	}
+}
+
+func jobErrString(err error) *string {
+	if err == nil {
+		return nil
+	}
+	s := err.Error()
+	return &s
+}
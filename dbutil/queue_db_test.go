@@ -0,0 +1,133 @@
+package dbutil_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/interline-io/transitland-dbutil/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobQueueDB(t *testing.T) {
+	if a, ok := testutil.CheckTestDB(); !ok {
+		t.Skip(a)
+		return
+	}
+	ctx := context.Background()
+	db := testutil.MustOpenTestDB(t)
+	stmts := []string{
+		"DROP SCHEMA IF EXISTS dbutil_queue_test CASCADE",
+		"CREATE SCHEMA dbutil_queue_test",
+		`CREATE TABLE dbutil_queue_test."Jobs" (
+			id bigserial PRIMARY KEY,
+			queue text NOT NULL,
+			payload jsonb NOT NULL,
+			status text NOT NULL DEFAULT 'pending',
+			attempts int NOT NULL DEFAULT 0,
+			run_at timestamptz NOT NULL DEFAULT now(),
+			locked_until timestamptz,
+			last_error text,
+			created_at timestamptz NOT NULL DEFAULT now()
+		)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer db.ExecContext(ctx, "DROP SCHEMA dbutil_queue_test CASCADE")
+	jq := dbutil.NewJobQueue("dbutil_queue_test.Jobs")
+	enqueue := func(queue string, count int) {
+		for i := 0; i < count; i++ {
+			if err := jq.Enqueue(ctx, db, queue, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("skip locked", func(t *testing.T) {
+		enqueue("skip", 2)
+		// Hold a row lock on the first job, as a concurrent Dequeue would
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		var lockedID int64
+		if err := tx.GetContext(ctx, &lockedID, `SELECT id FROM dbutil_queue_test."Jobs" WHERE queue = 'skip' ORDER BY id LIMIT 1 FOR UPDATE`); err != nil {
+			t.Fatal(err)
+		}
+		jobs, err := jq.Dequeue(ctx, db, "skip", 2)
+		assert.NoError(t, err)
+		if assert.Equal(t, 1, len(jobs)) {
+			assert.NotEqual(t, lockedID, jobs[0].ID)
+			assert.Equal(t, 1, jobs[0].Attempts)
+		}
+	})
+
+	t.Run("concurrent workers", func(t *testing.T) {
+		enqueue("concurrent", 20)
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		claimed := map[int64]int{}
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					jobs, err := jq.Dequeue(ctx, db, "concurrent", 3)
+					if err != nil || len(jobs) == 0 {
+						assert.NoError(t, err)
+						return
+					}
+					lock.Lock()
+					for _, job := range jobs {
+						claimed[job.ID]++
+					}
+					lock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 20, len(claimed))
+		for id, n := range claimed {
+			assert.Equal(t, 1, n, "job %d claimed more than once", id)
+		}
+	})
+
+	t.Run("lock expiry", func(t *testing.T) {
+		jq := dbutil.NewJobQueue("dbutil_queue_test.Jobs")
+		jq.LockTimeout = 50 * time.Millisecond
+		jq.RetryPolicy.MaxAttempts = 2
+		enqueue("expiry", 1)
+		first, err := jq.Dequeue(ctx, db, "expiry", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(first))
+		none, err := jq.Dequeue(ctx, db, "expiry", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(none), "claimed job should not be dequeued again before its lock expires")
+
+		// Reclaimed after the lock expires; the first claim is lost
+		time.Sleep(100 * time.Millisecond)
+		second, err := jq.Dequeue(ctx, db, "expiry", 1)
+		assert.NoError(t, err)
+		if assert.Equal(t, 1, len(second)) {
+			assert.Equal(t, 2, second[0].Attempts)
+		}
+		assert.ErrorIs(t, jq.Ack(ctx, db, first[0]), dbutil.ErrJobLost)
+
+		// Dead-lettered after the lock of the last attempt expires
+		time.Sleep(100 * time.Millisecond)
+		none, err = jq.Dequeue(ctx, db, "expiry", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(none))
+		var status string
+		if err := db.GetContext(ctx, &status, `SELECT status FROM dbutil_queue_test."Jobs" WHERE id = $1`, second[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, dbutil.JobDead, status)
+	})
+}
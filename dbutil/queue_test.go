@@ -0,0 +1,69 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobQueue_backoff(t *testing.T) {
+	jq := NewJobQueue("dbutil_jobs")
+	jq.RetryPolicy = RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, jq.backoff(1))
+	assert.Equal(t, 2*time.Second, jq.backoff(2))
+	assert.Equal(t, 4*time.Second, jq.backoff(3))
+	assert.Equal(t, 5*time.Second, jq.backoff(4))
+	assert.Equal(t, 5*time.Second, jq.backoff(10))
+	jq.RetryPolicy.MaxBackoff = 0
+	assert.Equal(t, 8*time.Second, jq.backoff(4), "backoff should not be capped without MaxBackoff")
+}
+
+func TestJobQueue_update(t *testing.T) {
+	var stmts []QueryInfo
+	rows := int64(1)
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			stmts = append(stmts, *qi)
+			qi.Result = driver.RowsAffected(rows)
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := context.Background()
+	jq := NewJobQueue("dbutil_jobs")
+	job := Job{ID: 7, Status: JobRunning, Attempts: 2}
+
+	assert.NoError(t, jq.Ack(ctx, nil, job))
+	assert.NoError(t, jq.Retry(ctx, nil, job, errors.New("failed")))
+	job.Attempts = jq.RetryPolicy.MaxAttempts
+	assert.NoError(t, jq.Retry(ctx, nil, job, errors.New("failed")))
+	if assert.Len(t, stmts, 3) {
+		assert.Equal(t, `DELETE FROM "dbutil_jobs" WHERE id = $1 AND attempts = $2 AND status = $3`, stmts[0].Query)
+		assert.Equal(t, []interface{}{int64(7), 2, JobRunning}, stmts[0].Args)
+		assert.Equal(t, `UPDATE "dbutil_jobs" SET status = $1, run_at = $2, locked_until = $3, last_error = $4 WHERE id = $5 AND attempts = $6 AND status = $7`, stmts[1].Query)
+		assert.Equal(t, JobPending, stmts[1].Args[0])
+		assert.Equal(t, []interface{}{int64(7), 2, JobRunning}, stmts[1].Args[4:])
+		assert.Equal(t, `UPDATE "dbutil_jobs" SET status = $1, locked_until = $2, last_error = $3 WHERE id = $4 AND attempts = $5 AND status = $6`, stmts[2].Query)
+		assert.Equal(t, JobDead, stmts[2].Args[0])
+	}
+
+	// The job was reclaimed by another worker
+	rows = 0
+	assert.ErrorIs(t, jq.Ack(ctx, nil, job), ErrJobLost)
+}
+
+func TestJobQueue_deadLetterExpired(t *testing.T) {
+	jq := NewJobQueue("dbutil_jobs")
+	qstr, args, err := jq.deadLetterExpired("default").PlaceholderFormat(sq.Dollar).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE "dbutil_jobs" SET status = $1, locked_until = $2, last_error = coalesce(last_error, $3) WHERE id IN (SELECT id FROM "dbutil_jobs" WHERE queue = $4 AND status = $5 AND locked_until < now() AND attempts >= $6 FOR UPDATE SKIP LOCKED)`, qstr)
+	assert.Equal(t, []interface{}{JobDead, nil, "lock expired after 5 attempts", "default", JobRunning, 5}, args)
+
+	_, err = jq.Dequeue(context.Background(), nil, "default", 0)
+	assert.Error(t, err)
+}
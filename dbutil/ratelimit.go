@@ -0,0 +1,138 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned without running the query when a tenant is over its limits.
+var ErrRateLimited = errors.New("database rate limit exceeded")
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context that identifies the tenant or requester a query is run for,
+// for use by RateLimiter.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok
+}
+
+// RateLimiter limits the queries per second and concurrent queries for each tenant,
+// so one heavy consumer can not starve a shared pool. Apply it to all queries with
+// RateLimitMiddleware, or to individual calls with Do.
+// Queries with no tenant in the context share a single limit under the empty key.
+// A zero QPS or MaxConcurrent disables that limit. Idle tenants are evicted periodically,
+// so keying by requester does not grow memory without bound.
+type RateLimiter struct {
+	QPS           float64
+	Burst         int
+	MaxConcurrent int
+	tenants       map[string]*tenantLimit
+	evicted       time.Time
+	lock          sync.Mutex
+}
+
+// rateLimiterEvictInterval is how often RateLimiter scans for idle tenants.
+const rateLimiterEvictInterval = time.Minute
+
+type tenantLimit struct {
+	tokens   float64
+	updated  time.Time
+	inFlight int
+}
+
+func NewRateLimiter(qps float64, burst int, maxConcurrent int) *RateLimiter {
+	return &RateLimiter{QPS: qps, Burst: burst, MaxConcurrent: maxConcurrent, tenants: map[string]*tenantLimit{}}
+}
+
+// Do calls fn if the tenant from ctx is within its limits, and returns ErrRateLimited otherwise.
+func (r *RateLimiter) Do(ctx context.Context, fn func(context.Context) error) error {
+	tenant, _ := TenantFromContext(ctx)
+	if !r.acquire(tenant, time.Now()) {
+		return ErrRateLimited
+	}
+	defer r.release(tenant)
+	return fn(ctx)
+}
+
+func (r *RateLimiter) acquire(tenant string, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	burst := r.burst()
+	if now.Sub(r.evicted) >= rateLimiterEvictInterval {
+		r.evictIdle(now)
+	}
+	if r.tenants == nil {
+		r.tenants = map[string]*tenantLimit{}
+	}
+	t, ok := r.tenants[tenant]
+	if !ok {
+		t = &tenantLimit{tokens: burst, updated: now}
+		r.tenants[tenant] = t
+	}
+	if r.MaxConcurrent > 0 && t.inFlight >= r.MaxConcurrent {
+		return false
+	}
+	if r.QPS > 0 {
+		t.tokens += now.Sub(t.updated).Seconds() * r.QPS
+		if t.tokens > burst {
+			t.tokens = burst
+		}
+		t.updated = now
+		if t.tokens < 1 {
+			return false
+		}
+		t.tokens--
+	}
+	t.inFlight++
+	return true
+}
+
+func (r *RateLimiter) burst() float64 {
+	if r.Burst < 1 {
+		return 1
+	}
+	return float64(r.Burst)
+}
+
+// evictIdle removes tenants with no queries in flight whose tokens have refilled,
+// since they are in the same state as a tenant seen for the first time.
+func (r *RateLimiter) evictIdle(now time.Time) {
+	r.evicted = now
+	burst := r.burst()
+	for tenant, t := range r.tenants {
+		if t.inFlight > 0 {
+			continue
+		}
+		if r.QPS <= 0 || t.tokens+now.Sub(t.updated).Seconds()*r.QPS >= burst {
+			delete(r.tenants, tenant)
+		}
+	}
+}
+
+func (r *RateLimiter) release(tenant string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if t, ok := r.tenants[tenant]; ok {
+		t.inFlight--
+	}
+}
+
+// RateLimitMiddleware returns middleware that runs each query subject to the limits of r,
+// returning ErrRateLimited for queries from a tenant over its limits. Install it with SetMiddleware.
+func RateLimitMiddleware(r *RateLimiter) Middleware {
+	return func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			return r.Do(ctx, func(ctx context.Context) error {
+				return next(ctx, qi)
+			})
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_QPS(t *testing.T) {
+	r := NewRateLimiter(10, 2, 0)
+	now := time.Now()
+	assert.True(t, r.acquire("a", now))
+	assert.True(t, r.acquire("a", now))
+	assert.False(t, r.acquire("a", now))
+	// Other tenants have their own limits
+	assert.True(t, r.acquire("b", now))
+	// One token is added every 100ms at 10 qps
+	assert.True(t, r.acquire("a", now.Add(100*time.Millisecond)))
+	assert.False(t, r.acquire("a", now.Add(100*time.Millisecond)))
+}
+
+func TestRateLimiter_MaxConcurrent(t *testing.T) {
+	r := NewRateLimiter(0, 0, 1)
+	ctx := WithTenant(context.Background(), "a")
+	err := r.Do(ctx, func(ctx context.Context) error {
+		assert.ErrorIs(t, r.Do(ctx, func(context.Context) error { return nil }), ErrRateLimited)
+		assert.NoError(t, r.Do(WithTenant(ctx, "b"), func(context.Context) error { return nil }))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, r.Do(ctx, func(context.Context) error { return nil }))
+}
+
+func TestRateLimiter_evictIdle(t *testing.T) {
+	r := NewRateLimiter(1, 1, 0)
+	now := time.Now()
+	assert.True(t, r.acquire("a", now))
+	assert.True(t, r.acquire("b", now))
+	r.release("a")
+	assert.Len(t, r.tenants, 2)
+	// b is still in flight, and a has refilled
+	later := now.Add(rateLimiterEvictInterval)
+	assert.True(t, r.acquire("c", later))
+	assert.Len(t, r.tenants, 2)
+	assert.NotContains(t, r.tenants, "a")
+	assert.Contains(t, r.tenants, "b")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// The zero value is usable
+	r := &RateLimiter{MaxConcurrent: 1}
+	block := make(chan struct{})
+	started := make(chan struct{})
+	SetMiddleware(RateLimitMiddleware(r), func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			if qi.Query == "SELECT 1" {
+				close(started)
+				<-block
+			}
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := WithTenant(context.Background(), "a")
+	done := make(chan error)
+	go func() {
+		var v int
+		done <- Get(ctx, nil, sq.Select("1"), &v)
+	}()
+	<-started
+	var v int
+	assert.ErrorIs(t, Get(ctx, nil, sq.Select("2"), &v), ErrRateLimited)
+	assert.NoError(t, Get(WithTenant(ctx, "b"), nil, sq.Select("2"), &v))
+	close(block)
+	assert.NoError(t, <-done)
+	assert.NoError(t, Get(ctx, nil, sq.Select("2"), &v))
+}
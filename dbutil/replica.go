@@ -0,0 +1,194 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+type forcePrimaryContextKey struct{}
+
+// ForcePrimary returns a context in which ReplicaRouter reads go to the primary,
+// for read-your-writes paths.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryContextKey{}, true)
+}
+
+func isForcePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(forcePrimaryContextKey{}).(bool)
+	return v
+}
+
+// ReplicaLag is the most recent replication lag measurement for a replica.
+type ReplicaLag struct {
+	Index      int           `json:"index"`
+	Lag        time.Duration `json:"lag"`
+	InRotation bool          `json:"in_rotation"`
+	CheckedAt  time.Time     `json:"checked_at"`
+	Err        error         `json:"-"`
+}
+
+// ReplicaRouter sends reads to read replicas and writes and transactions to the primary.
+// Reads are distributed round-robin, or to the least-lagged replica if LeastLag is set.
+// When lag is monitored, replicas that fail the check or exceed MaxLag are taken out of rotation.
+type ReplicaRouter struct {
+	MaxLag   time.Duration
+	LeastLag bool
+	primary  *sqlx.DB
+	replicas []*sqlx.DB
+	lags     []ReplicaLag
+	next     uint64
+	lock     sync.RWMutex
+}
+
+func NewReplicaRouter(primary *sqlx.DB, replicas ...*sqlx.DB) *ReplicaRouter {
+	r := &ReplicaRouter{primary: primary}
+	for _, replica := range replicas {
+		r.addReplica(replica)
+	}
+	return r
+}
+
+func (r *ReplicaRouter) addReplica(replica *sqlx.DB) {
+	r.lags = append(r.lags, ReplicaLag{Index: len(r.replicas), InRotation: true})
+	r.replicas = append(r.replicas, replica)
+}
+
+// OpenReplicaRouter opens the primary and replica databases with OpenDB.
+func OpenReplicaRouter(primaryURL string, replicaURLs ...string) (*ReplicaRouter, error) {
+	primary, err := OpenDB(primaryURL)
+	if err != nil {
+		return nil, err
+	}
+	r := NewReplicaRouter(primary)
+	for _, u := range replicaURLs {
+		replica, err := OpenDB(u)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.addReplica(replica)
+	}
+	return r, nil
+}
+
+// Primary returns the primary database.
+func (r *ReplicaRouter) Primary() *sqlx.DB {
+	return r.primary
+}
+
+// Reader returns the database to use for a read: a replica in rotation,
+// or the primary if there are none or ctx was created by ForcePrimary.
+func (r *ReplicaRouter) Reader(ctx context.Context) *sqlx.DB {
+	if isForcePrimary(ctx) {
+		return r.primary
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	var candidates []int
+	for i, lag := range r.lags {
+		if lag.InRotation {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return r.primary
+	}
+	if r.LeastLag {
+		best := candidates[0]
+		for _, i := range candidates {
+			if r.lags[i].Lag < r.lags[best].Lag {
+				best = i
+			}
+		}
+		return r.replicas[best]
+	}
+	n := atomic.AddUint64(&r.next, 1)
+	return r.replicas[candidates[n%uint64(len(candidates))]]
+}
+
+// Lags returns the most recent lag measurement for each replica.
+func (r *ReplicaRouter) Lags() []ReplicaLag {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return append([]ReplicaLag{}, r.lags...)
+}
+
+// CheckLag measures replication lag on each replica and updates which replicas are in rotation.
+func (r *ReplicaRouter) CheckLag(ctx context.Context) {
+	lags := make([]ReplicaLag, len(r.replicas))
+	for i, replica := range r.replicas {
+		lag := ReplicaLag{Index: i, CheckedAt: time.Now()}
+		var seconds float64
+		// Lag is zero if the replica has replayed everything it has received
+		q := sq.Select(`CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)
+			END`)
+		if err := Get(ctx, replica, q, &seconds); err != nil {
+			lag.Err = err
+		} else {
+			lag.Lag = time.Duration(seconds * float64(time.Second))
+			lag.InRotation = r.MaxLag <= 0 || lag.Lag <= r.MaxLag
+		}
+		if !lag.InRotation {
+			logger(ctx).Info().Err(lag.Err).Int("replica", i).Str("lag", lag.Lag.String()).Msg("replica out of rotation")
+		}
+		lags[i] = lag
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lags = lags
+}
+
+// StartLagMonitor checks replica lag every interval until the context is done.
+func (r *ReplicaRouter) StartLagMonitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.CheckLag(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Select runs a query on a replica.
+func (r *ReplicaRouter) Select(ctx context.Context, q sq.SelectBuilder, dest interface{}) error {
+	return Select(ctx, r.Reader(ctx), q, dest)
+}
+
+// Get runs a query on a replica.
+func (r *ReplicaRouter) Get(ctx context.Context, q sq.SelectBuilder, dest interface{}) error {
+	return Get(ctx, r.Reader(ctx), q, dest)
+}
+
+// Exec runs a statement on the primary.
+func (r *ReplicaRouter) Exec(ctx context.Context, q sq.Sqlizer) (sql.Result, error) {
+	return Exec(ctx, r.primary, q)
+}
+
+// Tx runs a transaction on the primary.
+func (r *ReplicaRouter) Tx(ctx context.Context, cb func(*sqlx.Tx) error) error {
+	return Tx(ctx, r.primary, cb)
+}
+
+// Close closes the primary and all replicas.
+func (r *ReplicaRouter) Close() error {
+	err := r.primary.Close()
+	for _, replica := range r.replicas {
+		if rerr := replica.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// RetryPolicy configures retries of idempotent operations on transient errors.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is long enough to ride out a typical managed database failover.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// Do calls fn until it succeeds, returns an error that is not transient, or attempts are exhausted.
+// Retries stop early if the next backoff would pass the context deadline.
+func (p RetryPolicy) Do(ctx context.Context, fn func(context.Context) error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		logger(ctx).Info().Err(err).Int("attempt", attempt).Str("backoff", backoff.String()).Msg("retrying after transient error")
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// RetrySelect is Select with retries on transient errors.
+func RetrySelect(ctx context.Context, p RetryPolicy, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	return p.Do(ctx, func(ctx context.Context) error {
+		// sqlx appends to slices, so discard any rows from a failed attempt
+		if v := reflect.ValueOf(dest); v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Slice {
+			v.Elem().SetLen(0)
+		}
+		return Select(ctx, db, q, dest)
+	})
+}
+
+// RetryGet is Get with retries on transient errors.
+func RetryGet(ctx context.Context, p RetryPolicy, db sqlx.Ext, q sq.SelectBuilder, dest interface{}) error {
+	return p.Do(ctx, func(ctx context.Context) error {
+		return Get(ctx, db, q, dest)
+	})
+}
+
+// transientCodes are SQLSTATE codes and classes that are expected to succeed on retry.
+var transientCodes = []string{
+	"08",    // connection exception
+	"53300", // too_many_connections
+	"57P01", // admin_shutdown
+	"57P02", // crash_shutdown
+	"57P03", // cannot_connect_now
+	"25006", // read_only_sql_transaction, e.g. writing to a demoted primary after failover
+}
+
+// IsTransient returns true if err is a connection failure, connection limit, or failover error.
+func IsTransient(err error) bool {
+	if isConnError(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		for _, code := range transientCodes {
+			if strings.HasPrefix(pgErr.Code, code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isConnError returns true if err indicates a lost or failed connection.
+// Context errors are not connection errors, even though context.DeadlineExceeded
+// implements net.Error, so statement timeouts and cancellations are not retried.
+func isConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,46 @@
+package dbutil
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Savepoint creates a savepoint in tx, so later statements can be undone with RollbackTo
+// without aborting the whole transaction. Savepoint statements run through Exec,
+// so they are logged, journaled, and passed through middleware like other statements.
+func Savepoint(ctx context.Context, tx *sqlx.Tx, name string) error {
+	_, err := Exec(ctx, tx, RawSQL("SAVEPOINT "+QuoteIdentifier(name)))
+	return err
+}
+
+// RollbackTo rolls tx back to a savepoint, which remains available for reuse.
+func RollbackTo(ctx context.Context, tx *sqlx.Tx, name string) error {
+	_, err := Exec(ctx, tx, RawSQL("ROLLBACK TO SAVEPOINT "+QuoteIdentifier(name)))
+	return err
+}
+
+// ReleaseSavepoint releases a savepoint, keeping the changes made since it was created.
+func ReleaseSavepoint(ctx context.Context, tx *sqlx.Tx, name string) error {
+	_, err := Exec(ctx, tx, RawSQL("RELEASE SAVEPOINT "+QuoteIdentifier(name)))
+	return err
+}
+
+// WithSavepoint runs cb inside a savepoint. If cb returns an error, tx is rolled back to
+// the savepoint and the error is returned, leaving tx usable, e.g. to skip a bad row in a batch.
+func WithSavepoint(ctx context.Context, tx *sqlx.Tx, name string, cb func() error) error {
+	if err := Savepoint(ctx, tx, name); err != nil {
+		return err
+	}
+	if err := cb(); err != nil {
+		if rbErr := RollbackTo(ctx, tx, name); rbErr != nil {
+			logger(ctx).Error().Err(rbErr).Str("savepoint", name).Msg("could not rollback to savepoint")
+			return err
+		}
+		if relErr := ReleaseSavepoint(ctx, tx, name); relErr != nil {
+			logger(ctx).Error().Err(relErr).Str("savepoint", name).Msg("could not release savepoint")
+		}
+		return err
+	}
+	return ReleaseSavepoint(ctx, tx, name)
+}
@@ -0,0 +1,31 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSavepoint(t *testing.T) {
+	var stmts []string
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			stmts = append(stmts, qi.Query)
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := context.Background()
+	tx := &sqlx.Tx{}
+
+	assert.NoError(t, WithSavepoint(ctx, tx, "row_1", func() error { return nil }))
+	assert.Equal(t, []string{`SAVEPOINT "row_1"`, `RELEASE SAVEPOINT "row_1"`}, stmts)
+
+	stmts = nil
+	failed := errors.New("failed")
+	assert.ErrorIs(t, WithSavepoint(ctx, tx, "row_2", func() error { return failed }), failed)
+	assert.Equal(t, []string{`SAVEPOINT "row_2"`, `ROLLBACK TO SAVEPOINT "row_2"`, `RELEASE SAVEPOINT "row_2"`}, stmts)
+}
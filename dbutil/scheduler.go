@@ -0,0 +1,146 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultSchedulerPollInterval is the PollInterval used when a Scheduler has none.
+const DefaultSchedulerPollInterval = time.Minute
+
+// ScheduledTask is a callback run by a Scheduler at most once per Interval across all instances.
+type ScheduledTask struct {
+	Name     string
+	Interval time.Duration
+	Run      func(context.Context, *sqlx.DB) error
+}
+
+// Scheduler runs registered tasks periodically, storing the last run time of each task in a table.
+// Each run holds a session-level advisory lock for the task on a dedicated connection, so a task
+// runs on only one instance at a time, and claims the run by setting the last run time in a
+// single statement, so instances do not run it again before Interval has passed. Tasks run outside
+// any transaction, so long runs do not hold back vacuum. Failed runs are recorded and retried
+// after Interval. Expected schema:
+//
+//	CREATE TABLE dbutil_schedules (
+//		name text PRIMARY KEY,
+//		last_run_at timestamptz,
+//		last_error text
+//	);
+type Scheduler struct {
+	Table        string
+	PollInterval time.Duration
+	tasks        []ScheduledTask
+	runTask      func(context.Context, *sqlx.DB, ScheduledTask) (bool, error)
+	lock         sync.Mutex
+}
+
+func NewScheduler(table string) *Scheduler {
+	return &Scheduler{
+		Table:        table,
+		PollInterval: DefaultSchedulerPollInterval,
+	}
+}
+
+// Register adds a task.
+func (s *Scheduler) Register(name string, interval time.Duration, run func(context.Context, *sqlx.DB) error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tasks = append(s.tasks, ScheduledTask{Name: name, Interval: interval, Run: run})
+}
+
+// Start runs due tasks every PollInterval until the context is done.
+func (s *Scheduler) Start(ctx context.Context, db *sqlx.DB) {
+	go func() {
+		interval := s.PollInterval
+		if interval <= 0 {
+			interval = DefaultSchedulerPollInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.RunDue(ctx, db)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue runs each task that is due and not running elsewhere, and returns the number run.
+// Task errors are logged and recorded, not returned. Errors running a task, such as a lost
+// connection, do not stop the remaining tasks from running, and are returned joined.
+func (s *Scheduler) RunDue(ctx context.Context, db *sqlx.DB) (int, error) {
+	s.lock.Lock()
+	tasks := append([]ScheduledTask(nil), s.tasks...)
+	runTask := s.runTask
+	s.lock.Unlock()
+	if runTask == nil {
+		runTask = s.run
+	}
+	count := 0
+	var errs []error
+	for _, task := range tasks {
+		ran, err := runTask(ctx, db, task)
+		if err != nil {
+			logger(ctx).Error().Err(err).Str("task", task.Name).Msg("scheduler: could not run task")
+			errs = append(errs, err)
+		}
+		if ran {
+			count++
+		}
+	}
+	return count, errors.Join(errs...)
+}
+
+// run runs task if it is due, and returns true if it ran.
+func (s *Scheduler) run(ctx context.Context, db *sqlx.DB, task ScheduledTask) (bool, error) {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	key := AdvisoryLockKey(s.Table + ":" + task.Name)
+	locked := false
+	if err := conn.QueryRowxContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil || !locked {
+		return false, err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			logger(ctx).Error().Err(err).Str("task", task.Name).Msg("scheduler: could not release lock")
+		}
+	}()
+	if _, err := Exec(ctx, db, sq.Insert(s.Table).Columns("name").Values(task.Name).Suffix("ON CONFLICT DO NOTHING")); err != nil {
+		return false, err
+	}
+	if claimed, err := execRowsAffected(ctx, db, s.claimQuery(task)); err != nil || claimed == 0 {
+		return false, err
+	}
+	t := time.Now()
+	taskErr := task.Run(ctx, db)
+	if taskErr != nil {
+		logger(ctx).Error().Err(taskErr).Str("task", task.Name).Msg("scheduler: task failed")
+	} else {
+		logger(ctx).Info().Str("task", task.Name).Dur("elapsed", time.Since(t)).Msg("scheduler: task completed")
+	}
+	update := sq.Update(s.Table).
+		Set("last_error", jobErrString(taskErr)).
+		Where("name = ?", task.Name)
+	_, err = Exec(context.WithoutCancel(ctx), db, update)
+	return true, err
+}
+
+// claimQuery sets the last run time of task if it is due, updating no rows otherwise.
+func (s *Scheduler) claimQuery(task ScheduledTask) sq.UpdateBuilder {
+	return sq.Update(s.Table).
+		Set("last_run_at", sq.Expr("now()")).
+		Where("name = ?", task.Name).
+		Where("(last_run_at IS NULL OR last_run_at <= now() - make_interval(secs => ?))", task.Interval.Seconds())
+}
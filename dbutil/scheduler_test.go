@@ -0,0 +1,39 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler_claimQuery(t *testing.T) {
+	s := NewScheduler("dbutil_schedules")
+	sql, args, err := s.claimQuery(ScheduledTask{Name: "refresh", Interval: time.Hour}).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE dbutil_schedules SET last_run_at = now() WHERE name = ? AND (last_run_at IS NULL OR last_run_at <= now() - make_interval(secs => ?))", sql)
+	assert.Equal(t, []interface{}{"refresh", 3600.0}, args)
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	s := NewScheduler("dbutil_schedules")
+	for _, name := range []string{"a", "b", "c"} {
+		s.Register(name, time.Hour, nil)
+	}
+	errLost := errors.New("connection lost")
+	var names []string
+	s.runTask = func(ctx context.Context, db *sqlx.DB, task ScheduledTask) (bool, error) {
+		names = append(names, task.Name)
+		if task.Name == "a" {
+			return false, errLost
+		}
+		return true, nil
+	}
+	n, err := s.RunDue(context.Background(), nil)
+	assert.ErrorIs(t, err, errLost)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
@@ -0,0 +1,74 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrSchemaRequiresTx is returned when a query with a WithSchema context is run outside a transaction
+// started by Tx with the same schema.
+var ErrSchemaRequiresTx = errors.New("queries with a schema context must run inside Tx")
+
+// schemaTxs records the schema applied to each open transaction started by Tx.
+var schemaTxs sync.Map
+
+type schemaContextKey struct{}
+
+// WithSchema returns a context that scopes transactions started by Tx to a tenant schema,
+// by issuing SET LOCAL search_path. The public schema is kept on the search path, after
+// the tenant schema, so extension types and functions such as PostGIS still resolve.
+// Select, Get, and Exec return ErrSchemaRequiresTx if used with this context outside a transaction
+// started by Tx with the same schema, since the query would otherwise run against the default schema.
+func WithSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaContextKey{}, schema)
+}
+
+// SchemaFromContext returns the schema set by WithSchema, if any.
+func SchemaFromContext(ctx context.Context) (string, bool) {
+	schema, ok := ctx.Value(schemaContextKey{}).(string)
+	return schema, ok && schema != ""
+}
+
+// checkSchema returns an error if ctx has a schema but db is not a transaction
+// whose search_path was set to that schema by Tx.
+func checkSchema(ctx context.Context, db sqlx.Ext) error {
+	schema, ok := SchemaFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tx, ok := db.(*sqlx.Tx)
+	if !ok {
+		return ErrSchemaRequiresTx
+	}
+	if applied, ok := schemaTxs.Load(tx); !ok || applied.(string) != schema {
+		return ErrSchemaRequiresTx
+	}
+	return nil
+}
+
+// setLocalSearchPath sets search_path for the remainder of tx, if ctx has a schema,
+// and records the schema for checkSchema until clearSearchPath is called.
+func setLocalSearchPath(ctx context.Context, tx *sqlx.Tx) error {
+	schema, ok := SchemaFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, searchPathStatement(schema)); err != nil {
+		return err
+	}
+	schemaTxs.Store(tx, schema)
+	return nil
+}
+
+func searchPathStatement(schema string) string {
+	return fmt.Sprintf("SET LOCAL search_path TO %s, public", QuoteIdentifier(schema))
+}
+
+// clearSearchPath forgets the schema recorded for tx when it ends.
+func clearSearchPath(tx *sqlx.Tx) {
+	schemaTxs.Delete(tx)
+}
@@ -0,0 +1,23 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSchema(t *testing.T) {
+	ctx := WithSchema(context.Background(), "tenant_1")
+	assert.NoError(t, checkSchema(context.Background(), nil))
+	assert.ErrorIs(t, checkSchema(ctx, &sqlx.DB{}), ErrSchemaRequiresTx)
+	tx := &sqlx.Tx{}
+	assert.ErrorIs(t, checkSchema(ctx, tx), ErrSchemaRequiresTx, "transaction without search_path")
+	schemaTxs.Store(tx, "tenant_2")
+	assert.ErrorIs(t, checkSchema(ctx, tx), ErrSchemaRequiresTx, "transaction with another schema")
+	schemaTxs.Store(tx, "tenant_1")
+	assert.NoError(t, checkSchema(ctx, tx))
+	clearSearchPath(tx)
+	assert.ErrorIs(t, checkSchema(ctx, tx), ErrSchemaRequiresTx, "ended transaction")
+}
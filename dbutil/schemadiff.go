@@ -0,0 +1,319 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaDiff describes the differences from one schema snapshot, a, to another, b.
+// To compare entity structs with a database, use ValidateEnts.
+type SchemaDiff struct {
+	AddedTables   []*TableSnapshot `json:"added_tables,omitempty"`
+	RemovedTables []*TableSnapshot `json:"removed_tables,omitempty"`
+	ChangedTables []TableDiff      `json:"changed_tables,omitempty"`
+}
+
+// TableDiff describes the differences in a table present in both snapshots.
+// Changed indexes and constraints are reported as removed and added.
+type TableDiff struct {
+	Schema             string               `json:"schema"`
+	Name               string               `json:"name"`
+	AddedColumns       []ColumnSnapshot     `json:"added_columns,omitempty"`
+	RemovedColumns     []ColumnSnapshot     `json:"removed_columns,omitempty"`
+	ChangedColumns     []ColumnChange       `json:"changed_columns,omitempty"`
+	AddedIndexes       []IndexSnapshot      `json:"added_indexes,omitempty"`
+	RemovedIndexes     []IndexSnapshot      `json:"removed_indexes,omitempty"`
+	AddedConstraints   []ConstraintSnapshot `json:"added_constraints,omitempty"`
+	RemovedConstraints []ConstraintSnapshot `json:"removed_constraints,omitempty"`
+}
+
+// ColumnChange is a column whose type, nullability, or default differs.
+type ColumnChange struct {
+	From ColumnSnapshot `json:"from"`
+	To   ColumnSnapshot `json:"to"`
+}
+
+// Empty returns true if the snapshots match.
+func (d SchemaDiff) Empty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+func (d SchemaDiff) String() string {
+	var lines []string
+	for _, t := range d.AddedTables {
+		lines = append(lines, fmt.Sprintf("added table: %s.%s", t.Schema, t.Name))
+	}
+	for _, t := range d.RemovedTables {
+		lines = append(lines, fmt.Sprintf("removed table: %s.%s", t.Schema, t.Name))
+	}
+	for _, t := range d.ChangedTables {
+		lines = append(lines, fmt.Sprintf("changed table: %s.%s", t.Schema, t.Name))
+		for _, c := range t.AddedColumns {
+			lines = append(lines, fmt.Sprintf("  added column: %s %s", c.Name, c.Type))
+		}
+		for _, c := range t.RemovedColumns {
+			lines = append(lines, fmt.Sprintf("  removed column: %s", c.Name))
+		}
+		for _, c := range t.ChangedColumns {
+			lines = append(lines, fmt.Sprintf("  changed column: %s %s -> %s", c.From.Name, columnDesc(c.From), columnDesc(c.To)))
+		}
+		for _, idx := range t.AddedIndexes {
+			lines = append(lines, fmt.Sprintf("  added index: %s", idx.Name))
+		}
+		for _, idx := range t.RemovedIndexes {
+			lines = append(lines, fmt.Sprintf("  removed index: %s", idx.Name))
+		}
+		for _, c := range t.AddedConstraints {
+			lines = append(lines, fmt.Sprintf("  added constraint: %s", c.Name))
+		}
+		for _, c := range t.RemovedConstraints {
+			lines = append(lines, fmt.Sprintf("  removed constraint: %s", c.Name))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffSchema snapshots the given schemas, or public, in databases a and b and compares them,
+// e.g. to check that staging and production match before a deploy.
+func DiffSchema(ctx context.Context, a sqlx.Ext, b sqlx.Ext, schemas ...string) (SchemaDiff, error) {
+	sa, err := SnapshotSchema(ctx, a, schemas...)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	sb, err := SnapshotSchema(ctx, b, schemas...)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	return DiffSnapshots(sa, sb), nil
+}
+
+// DiffSnapshots compares two schema snapshots. Column order and comments are ignored.
+func DiffSnapshots(a *SchemaSnapshot, b *SchemaSnapshot) SchemaDiff {
+	var d SchemaDiff
+	for _, tb := range b.Tables {
+		if a.Table(tb.Schema, tb.Name) == nil {
+			d.AddedTables = append(d.AddedTables, tb)
+		}
+	}
+	for _, ta := range a.Tables {
+		tb := b.Table(ta.Schema, ta.Name)
+		if tb == nil {
+			d.RemovedTables = append(d.RemovedTables, ta)
+			continue
+		}
+		if td, ok := diffTables(ta, tb); ok {
+			d.ChangedTables = append(d.ChangedTables, td)
+		}
+	}
+	return d
+}
+
+func diffTables(a *TableSnapshot, b *TableSnapshot) (TableDiff, bool) {
+	td := TableDiff{Schema: a.Schema, Name: a.Name}
+	acols := map[string]ColumnSnapshot{}
+	for _, c := range a.Columns {
+		acols[c.Name] = c
+	}
+	bcols := map[string]bool{}
+	for _, c := range b.Columns {
+		bcols[c.Name] = true
+		ac, ok := acols[c.Name]
+		if !ok {
+			td.AddedColumns = append(td.AddedColumns, c)
+		} else if columnDesc(ac) != columnDesc(c) {
+			td.ChangedColumns = append(td.ChangedColumns, ColumnChange{From: ac, To: c})
+		}
+	}
+	for _, c := range a.Columns {
+		if !bcols[c.Name] {
+			td.RemovedColumns = append(td.RemovedColumns, c)
+		}
+	}
+	aidx, bidx := ownIndexes(a), ownIndexes(b)
+	for _, idx := range bidx {
+		if !hasIndexDef(aidx, idx) {
+			td.AddedIndexes = append(td.AddedIndexes, idx)
+		}
+	}
+	for _, idx := range aidx {
+		if !hasIndexDef(bidx, idx) {
+			td.RemovedIndexes = append(td.RemovedIndexes, idx)
+		}
+	}
+	for _, c := range b.Constraints {
+		if !hasConstraintDef(a.Constraints, c) {
+			td.AddedConstraints = append(td.AddedConstraints, c)
+		}
+	}
+	for _, c := range a.Constraints {
+		if !hasConstraintDef(b.Constraints, c) {
+			td.RemovedConstraints = append(td.RemovedConstraints, c)
+		}
+	}
+	changed := len(td.AddedColumns) > 0 || len(td.RemovedColumns) > 0 || len(td.ChangedColumns) > 0 ||
+		len(td.AddedIndexes) > 0 || len(td.RemovedIndexes) > 0 ||
+		len(td.AddedConstraints) > 0 || len(td.RemovedConstraints) > 0
+	return td, changed
+}
+
+// Statements returns statements that change schema a to match schema b. Review them before
+// running: renames appear as a drop and an add, so data in renamed tables and columns would be lost.
+// Sequences used by nextval defaults, e.g. of serial columns, are created and owned by their column.
+func (d SchemaDiff) Statements() []string {
+	var dropConstraints, dropIndexes, tables, sequences, columns, owners, addConstraints, addIndexes []string
+	qualified := func(schema, name string) string {
+		return QuoteIdentifier(schema) + "." + QuoteIdentifier(name)
+	}
+	addSequence := func(table string, c ColumnSnapshot) {
+		if seq, ok := nextvalSequence(c.Default); ok {
+			sequences = append(sequences, fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s", seq))
+			owners = append(owners, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s", seq, table, QuoteIdentifier(c.Name)))
+		}
+	}
+	addColumn := func(table string, c ColumnSnapshot) string {
+		addSequence(table, c)
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, QuoteIdentifier(c.Name), c.Type)
+		if c.Default != nil {
+			stmt = stmt + " DEFAULT " + *c.Default
+		}
+		if !c.Nullable {
+			stmt = stmt + " NOT NULL"
+		}
+		return stmt
+	}
+	for _, t := range d.RemovedTables {
+		tables = append(tables, fmt.Sprintf("DROP TABLE %s", qualified(t.Schema, t.Name)))
+	}
+	for _, t := range d.AddedTables {
+		table := qualified(t.Schema, t.Name)
+		tables = append(tables, fmt.Sprintf("CREATE TABLE %s ()", table))
+		for _, c := range t.Columns {
+			columns = append(columns, addColumn(table, c))
+		}
+		for _, c := range t.Constraints {
+			addConstraints = append(addConstraints, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", table, QuoteIdentifier(c.Name), c.Definition))
+		}
+		for _, idx := range ownIndexes(t) {
+			addIndexes = append(addIndexes, idx.Definition)
+		}
+	}
+	for _, t := range d.ChangedTables {
+		table := qualified(t.Schema, t.Name)
+		for _, c := range t.RemovedConstraints {
+			dropConstraints = append(dropConstraints, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, QuoteIdentifier(c.Name)))
+		}
+		for _, idx := range t.RemovedIndexes {
+			dropIndexes = append(dropIndexes, fmt.Sprintf("DROP INDEX %s", qualified(t.Schema, idx.Name)))
+		}
+		for _, c := range t.RemovedColumns {
+			columns = append(columns, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, QuoteIdentifier(c.Name)))
+		}
+		for _, c := range t.AddedColumns {
+			columns = append(columns, addColumn(table, c))
+		}
+		for _, c := range t.ChangedColumns {
+			col := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", table, QuoteIdentifier(c.To.Name))
+			if c.From.Type != c.To.Type {
+				columns = append(columns, fmt.Sprintf("%s TYPE %s", col, c.To.Type))
+			}
+			// Set the default first, so it is in place when NOT NULL applies
+			if stringPtrValue(c.From.Default) != stringPtrValue(c.To.Default) {
+				if c.To.Default == nil {
+					columns = append(columns, col+" DROP DEFAULT")
+				} else {
+					addSequence(table, c.To)
+					columns = append(columns, col+" SET DEFAULT "+*c.To.Default)
+				}
+			}
+			if c.From.Nullable != c.To.Nullable {
+				if c.To.Nullable {
+					columns = append(columns, col+" DROP NOT NULL")
+				} else {
+					columns = append(columns, col+" SET NOT NULL")
+				}
+			}
+		}
+		for _, c := range t.AddedConstraints {
+			addConstraints = append(addConstraints, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", table, QuoteIdentifier(c.Name), c.Definition))
+		}
+		for _, idx := range t.AddedIndexes {
+			addIndexes = append(addIndexes, idx.Definition)
+		}
+	}
+	var ret []string
+	for _, stmts := range [][]string{dropConstraints, dropIndexes, tables, sequences, columns, owners, addConstraints, addIndexes} {
+		ret = append(ret, stmts...)
+	}
+	return ret
+}
+
+// ownIndexes returns the indexes of a table that are not created by its constraints.
+func ownIndexes(t *TableSnapshot) []IndexSnapshot {
+	constraintIndexes := map[string]bool{}
+	for _, c := range t.Constraints {
+		if c.Type == "p" || c.Type == "u" || c.Type == "x" {
+			constraintIndexes[c.Name] = true
+		}
+	}
+	var ret []IndexSnapshot
+	for _, idx := range t.Indexes {
+		if !idx.Primary && !constraintIndexes[idx.Name] {
+			ret = append(ret, idx)
+		}
+	}
+	return ret
+}
+
+func columnDesc(c ColumnSnapshot) string {
+	desc := c.Type
+	if !c.Nullable {
+		desc = desc + " NOT NULL"
+	}
+	if c.Default != nil {
+		desc = desc + " DEFAULT " + *c.Default
+	}
+	return desc
+}
+
+func hasIndexDef(indexes []IndexSnapshot, idx IndexSnapshot) bool {
+	for _, other := range indexes {
+		if other.Name == idx.Name && other.Definition == idx.Definition {
+			return true
+		}
+	}
+	return false
+}
+
+func hasConstraintDef(constraints []ConstraintSnapshot, c ConstraintSnapshot) bool {
+	for _, other := range constraints {
+		if other.Name == c.Name && other.Definition == c.Definition {
+			return true
+		}
+	}
+	return false
+}
+
+var nextvalDefault = regexp.MustCompile(`^nextval\('((?:[^']|'')+)'::regclass\)$`)
+
+// nextvalSequence returns the sequence name in a nextval column default, as it appears in SQL.
+func nextvalSequence(def *string) (string, bool) {
+	if def == nil {
+		return "", false
+	}
+	m := nextvalDefault.FindStringSubmatch(*def)
+	if m == nil {
+		return "", false
+	}
+	return strings.ReplaceAll(m[1], "''", "'"), true
+}
+
+func stringPtrValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
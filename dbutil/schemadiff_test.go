@@ -0,0 +1,91 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	def := "''::text"
+	a := &SchemaSnapshot{Tables: []*TableSnapshot{
+		{
+			Schema: "public",
+			Name:   "gtfs_stops",
+			Columns: []ColumnSnapshot{
+				{Name: "id", Type: "bigint"},
+				{Name: "stop_name", Type: "text", Nullable: true},
+				{Name: "stop_desc", Type: "text", Nullable: true},
+			},
+			Indexes: []IndexSnapshot{
+				{Name: "gtfs_stops_pkey", Definition: "CREATE UNIQUE INDEX gtfs_stops_pkey ON public.gtfs_stops USING btree (id)", Unique: true, Primary: true},
+			},
+			Constraints: []ConstraintSnapshot{{Name: "gtfs_stops_pkey", Type: "p", Definition: "PRIMARY KEY (id)"}},
+		},
+		{Schema: "public", Name: "old_table", Columns: []ColumnSnapshot{{Name: "id", Type: "bigint"}}},
+	}}
+	b := &SchemaSnapshot{Tables: []*TableSnapshot{
+		{
+			Schema: "public",
+			Name:   "gtfs_stops",
+			Columns: []ColumnSnapshot{
+				{Name: "id", Type: "bigint"},
+				{Name: "stop_name", Type: "text", Default: &def},
+				{Name: "stop_code", Type: "text", Nullable: true},
+			},
+			Indexes: []IndexSnapshot{
+				{Name: "gtfs_stops_pkey", Definition: "CREATE UNIQUE INDEX gtfs_stops_pkey ON public.gtfs_stops USING btree (id)", Unique: true, Primary: true},
+				{Name: "gtfs_stops_stop_code", Definition: "CREATE INDEX gtfs_stops_stop_code ON public.gtfs_stops USING btree (stop_code)"},
+			},
+			Constraints: []ConstraintSnapshot{{Name: "gtfs_stops_pkey", Type: "p", Definition: "PRIMARY KEY (id)"}},
+		},
+		{Schema: "public", Name: "new_table", Columns: []ColumnSnapshot{{Name: "id", Type: "bigint"}}},
+	}}
+	assert.True(t, DiffSnapshots(a, a).Empty())
+	d := DiffSnapshots(a, b)
+	assert.False(t, d.Empty())
+	assert.Equal(t, []string{
+		`DROP TABLE "public"."old_table"`,
+		`CREATE TABLE "public"."new_table" ()`,
+		`ALTER TABLE "public"."new_table" ADD COLUMN "id" bigint NOT NULL`,
+		`ALTER TABLE "public"."gtfs_stops" DROP COLUMN "stop_desc"`,
+		`ALTER TABLE "public"."gtfs_stops" ADD COLUMN "stop_code" text`,
+		`ALTER TABLE "public"."gtfs_stops" ALTER COLUMN "stop_name" SET DEFAULT ''::text`,
+		`ALTER TABLE "public"."gtfs_stops" ALTER COLUMN "stop_name" SET NOT NULL`,
+		"CREATE INDEX gtfs_stops_stop_code ON public.gtfs_stops USING btree (stop_code)",
+	}, d.Statements())
+	assert.Equal(t, `added table: public.new_table
+removed table: public.old_table
+changed table: public.gtfs_stops
+  added column: stop_code text
+  removed column: stop_desc
+  changed column: stop_name text -> text NOT NULL DEFAULT ''::text
+  added index: gtfs_stops_stop_code`, d.String())
+}
+
+func TestDiffSnapshotsSequences(t *testing.T) {
+	seq := "nextval('gtfs_agencies_id_seq'::regclass)"
+	a := &SchemaSnapshot{}
+	b := &SchemaSnapshot{Tables: []*TableSnapshot{
+		{
+			Schema:  "public",
+			Name:    "gtfs_agencies",
+			Columns: []ColumnSnapshot{{Name: "id", Type: "bigint", Default: &seq}},
+		},
+	}}
+	assert.Equal(t, []string{
+		`CREATE TABLE "public"."gtfs_agencies" ()`,
+		`CREATE SEQUENCE IF NOT EXISTS gtfs_agencies_id_seq`,
+		`ALTER TABLE "public"."gtfs_agencies" ADD COLUMN "id" bigint DEFAULT nextval('gtfs_agencies_id_seq'::regclass) NOT NULL`,
+		`ALTER SEQUENCE gtfs_agencies_id_seq OWNED BY "public"."gtfs_agencies"."id"`,
+	}, DiffSnapshots(a, b).Statements())
+	name, ok := nextvalSequence(&seq)
+	assert.True(t, ok)
+	assert.Equal(t, "gtfs_agencies_id_seq", name)
+	quoted := `nextval('public."Stops_id_seq"'::regclass)`
+	name, _ = nextvalSequence(&quoted)
+	assert.Equal(t, `public."Stops_id_seq"`, name)
+	def := "now()"
+	_, ok = nextvalSequence(&def)
+	assert.False(t, ok)
+}
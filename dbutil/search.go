@@ -0,0 +1,45 @@
+package dbutil
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// TSVector returns a to_tsvector expression over one or more text columns, e.g.
+//
+//	to_tsvector('simple', coalesce(stop_name, '') || ' ' || coalesce(stop_desc, ''))
+//
+// Queries can only use an index if they match its expression exactly, so build the index with
+// the same expression, e.g. with TSVectorIndex, or store it in a generated tsvector column.
+func TSVector(config string, cols ...string) string {
+	var parts []string
+	for _, col := range cols {
+		parts = append(parts, fmt.Sprintf("coalesce(%s, '')", col))
+	}
+	return fmt.Sprintf("to_tsvector(%s, %s)", QuoteLiteral(config), strings.Join(parts, " || ' ' || "))
+}
+
+// TSVectorIndex returns a CREATE INDEX statement for a GIN index on a TSVector expression.
+func TSVectorIndex(name string, table string, config string, cols ...string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING gin ((%s))", QuoteIdentifier(name), QuoteIdentifier(table), TSVector(config, cols...))
+}
+
+// TextSearch matches rows where the tsvector expression vector matches a search
+// in web search syntax, e.g. `"market street" -north`.
+func TextSearch(vector string, config string, search string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("%s @@ websearch_to_tsquery(?::regconfig, ?)", vector), config, search)
+}
+
+// TextSearchRank ranks rows by how well vector matches search, for use as a selected
+// column with sq.Alias or with OrderByClause.
+func TextSearchRank(vector string, config string, search string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("ts_rank(%s, websearch_to_tsquery(?::regconfig, ?))", vector), config, search)
+}
+
+// TextSearchHeadline returns the text of doc with matches of search highlighted,
+// using <b> and </b> by default.
+func TextSearchHeadline(doc string, config string, search string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf("ts_headline(?::regconfig, %s, websearch_to_tsquery(?::regconfig, ?))", doc), config, config, search)
+}
@@ -0,0 +1,27 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextSearch(t *testing.T) {
+	vector := TSVector("simple", "stop_name", "stop_desc")
+	assert.Equal(t, "to_tsvector('simple', coalesce(stop_name, '') || ' ' || coalesce(stop_desc, ''))", vector)
+	assert.Equal(t, `CREATE INDEX IF NOT EXISTS "gtfs_stops_search" ON "gtfs_stops" USING gin ((to_tsvector('simple', coalesce(stop_name, ''))))`, TSVectorIndex("gtfs_stops_search", "gtfs_stops", "simple", "stop_name"))
+
+	q := sq.Select("id").
+		Column(sq.Alias(TextSearchHeadline("stop_name", "simple", "market st"), "headline")).
+		From("gtfs_stops").
+		Where(TextSearch(vector, "simple", "market st")).
+		OrderByClause(sq.Expr("? DESC", TextSearchRank(vector, "simple", "market st"))).
+		PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	assert.NoError(t, err)
+	assert.Contains(t, qstr, "(ts_headline($1::regconfig, stop_name, websearch_to_tsquery($2::regconfig, $3))) AS headline")
+	assert.Contains(t, qstr, "WHERE "+vector+" @@ websearch_to_tsquery($4::regconfig, $5)")
+	assert.Contains(t, qstr, "ORDER BY ts_rank("+vector+", websearch_to_tsquery($6::regconfig, $7)) DESC")
+	assert.Equal(t, 7, len(qargs))
+}
@@ -0,0 +1,104 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Selector routes reads to the fastest healthy database from a set of candidates,
+// e.g. replicas of the same database in different regions.
+type Selector struct {
+	dbs       []*sqlx.DB
+	latencies []time.Duration
+	best      int
+	ping      func(context.Context, *sqlx.DB) error
+	lock      sync.RWMutex
+}
+
+// NewSelector returns a Selector for dbs. Returns an error if there are no candidates.
+func NewSelector(dbs ...*sqlx.DB) (*Selector, error) {
+	if len(dbs) == 0 {
+		return nil, errors.New("selector: no candidate databases")
+	}
+	latencies := make([]time.Duration, len(dbs))
+	for i := range latencies {
+		latencies[i] = -1
+	}
+	return &Selector{dbs: dbs, latencies: latencies, ping: selectorPing}, nil
+}
+
+func selectorPing(ctx context.Context, db *sqlx.DB) error {
+	var ret int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&ret)
+}
+
+// DB returns the current fastest healthy database.
+// If no candidate has been measured as healthy, the first candidate is returned.
+func (s *Selector) DB() *sqlx.DB {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.dbs[s.best]
+}
+
+// Latencies returns the most recent latency for each candidate, in order.
+// Unhealthy or unmeasured candidates have a latency of -1.
+func (s *Selector) Latencies() []time.Duration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return append([]time.Duration{}, s.latencies...)
+}
+
+// Check measures the query latency of each candidate and selects the fastest healthy one.
+func (s *Selector) Check(ctx context.Context) error {
+	latencies := make([]time.Duration, len(s.dbs))
+	var wg sync.WaitGroup
+	for i, db := range s.dbs {
+		wg.Add(1)
+		go func(i int, db *sqlx.DB) {
+			defer wg.Done()
+			latencies[i] = -1
+			t := time.Now()
+			if err := s.ping(ctx, db); err != nil {
+				logger(ctx).Error().Err(err).Int("candidate", i).Msg("selector: candidate failed latency check")
+				return
+			}
+			latencies[i] = time.Since(t)
+		}(i, db)
+	}
+	wg.Wait()
+	best := -1
+	for i, d := range latencies {
+		if d >= 0 && (best < 0 || d < latencies[best]) {
+			best = i
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latencies = latencies
+	if best < 0 {
+		return errors.New("selector: no healthy candidates")
+	}
+	s.best = best
+	return nil
+}
+
+// Start re-evaluates candidates every interval until the context is done.
+func (s *Selector) Start(ctx context.Context, interval time.Duration) {
+	s.Check(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Check(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelector(t *testing.T) {
+	_, err := NewSelector()
+	assert.Error(t, err)
+
+	a := sqlx.NewDb(&sql.DB{}, "pgx")
+	b := sqlx.NewDb(&sql.DB{}, "pgx")
+	s, err := NewSelector(a, b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	delays := map[*sqlx.DB]time.Duration{a: 20 * time.Millisecond, b: time.Millisecond}
+	down := map[*sqlx.DB]bool{}
+	s.ping = func(ctx context.Context, db *sqlx.DB) error {
+		if down[db] {
+			return errors.New("connection refused")
+		}
+		time.Sleep(delays[db])
+		return nil
+	}
+	ctx := context.Background()
+	assert.Equal(t, a, s.DB(), "first candidate is used before any check")
+	assert.NoError(t, s.Check(ctx))
+	assert.Equal(t, b, s.DB())
+
+	// Fail over to the slower candidate
+	down[b] = true
+	assert.NoError(t, s.Check(ctx))
+	assert.Equal(t, a, s.DB())
+	assert.Equal(t, time.Duration(-1), s.Latencies()[1])
+
+	// With no healthy candidates the last selection is kept
+	down[a] = true
+	assert.Error(t, s.Check(ctx))
+	assert.Equal(t, a, s.DB())
+}
@@ -0,0 +1,25 @@
+package dbutil
+
+import (
+	"database/sql/driver"
+)
+
+// Sensitive wraps a query argument, such as an API key or email address,
+// whose value is replaced with "[redacted]" in query logs.
+// Struct fields tagged with the sensitive option, e.g. `db:"email,sensitive"`,
+// are wrapped automatically by ChangeSet.
+type Sensitive struct {
+	Val interface{}
+}
+
+// Value implements driver.Valuer.
+func (s Sensitive) Value() (driver.Value, error) {
+	if v, ok := s.Val.(driver.Valuer); ok {
+		return v.Value()
+	}
+	return s.Val, nil
+}
+
+func (s Sensitive) String() string {
+	return "[redacted]"
+}
@@ -0,0 +1,22 @@
+package dbutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensitive(t *testing.T) {
+	assert.Equal(t, []interface{}{"[redacted]", "ok"}, QueryLogOptions{}.logArgs([]interface{}{Sensitive{Val: "secret"}, "ok"}))
+	v, err := Sensitive{Val: "secret"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", v)
+
+	type ent struct {
+		ID    int
+		Email string `db:"email,sensitive"`
+	}
+	vals := entValues(&ent{ID: 1, Email: "a@example.com"})
+	assert.Equal(t, 1, vals["id"])
+	assert.Equal(t, Sensitive{Val: "a@example.com"}, vals["email"])
+}
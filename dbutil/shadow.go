@@ -0,0 +1,134 @@
+package dbutil
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// ShadowResult compares a read on the primary database with the same read mirrored to a shadow database.
+type ShadowResult struct {
+	Query           string
+	Args            []interface{}
+	PrimaryDuration time.Duration
+	ShadowDuration  time.Duration
+	ShadowErr       error
+	Match           bool
+}
+
+// Shadow mirrors a fraction of reads to a second database, such as a new replica or upgraded server,
+// and compares results and latency asynchronously. Callers always receive the primary result.
+type Shadow struct {
+	Primary sqlx.Ext
+	Shadow  sqlx.Ext
+	// Rate is the fraction of reads to mirror, from 0 to 1.
+	Rate float64
+	// Report is called with each comparison. The default logs mismatches and shadow errors.
+	Report func(ShadowResult)
+}
+
+func NewShadow(primary sqlx.Ext, shadow sqlx.Ext, rate float64) *Shadow {
+	return &Shadow{Primary: primary, Shadow: shadow, Rate: rate, Report: logShadowResult}
+}
+
+// Select runs a query on the primary and possibly mirrors it to the shadow.
+func (s *Shadow) Select(ctx context.Context, q sq.SelectBuilder, dest interface{}) error {
+	return s.run(ctx, q, dest, Select)
+}
+
+// Get runs a query on the primary and possibly mirrors it to the shadow.
+func (s *Shadow) Get(ctx context.Context, q sq.SelectBuilder, dest interface{}) error {
+	return s.run(ctx, q, dest, Get)
+}
+
+func (s *Shadow) run(ctx context.Context, q sq.SelectBuilder, dest interface{}, fn func(context.Context, sqlx.Ext, sq.SelectBuilder, interface{}) error) error {
+	t := time.Now()
+	err := fn(ctx, s.Primary, q, dest)
+	if err != nil || rand.Float64() >= s.Rate {
+		return err
+	}
+	result := ShadowResult{PrimaryDuration: time.Since(t)}
+	result.Query, result.Args, _ = q.PlaceholderFormat(sq.Dollar).ToSql()
+	// Copy the result, since the caller may reuse dest before the comparison completes
+	expect := deepCopy(reflect.ValueOf(dest).Elem()).Interface()
+	shadowCtx := context.WithoutCancel(ctx)
+	go func() {
+		shadowDest := reflect.New(reflect.TypeOf(dest).Elem())
+		t := time.Now()
+		result.ShadowErr = fn(shadowCtx, s.Shadow, q, shadowDest.Interface())
+		result.ShadowDuration = time.Since(t)
+		result.Match = result.ShadowErr == nil && reflect.DeepEqual(expect, shadowDest.Elem().Interface())
+		if s.Report != nil {
+			s.Report(result)
+		}
+	}()
+	return nil
+}
+
+func logShadowResult(r ShadowResult) {
+	if r.ShadowErr != nil {
+		logger(context.Background()).Error().Err(r.ShadowErr).Str("query", r.Query).Msg("shadow: query failed")
+	} else if !r.Match {
+		logger(context.Background()).Error().Str("query", r.Query).Interface("args", getQueryLogOptions().logArgs(r.Args)).Msg("shadow: results do not match")
+	}
+}
+
+// deepCopy returns a copy of v that shares no pointers, slices, or maps with it.
+// Unexported struct fields are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
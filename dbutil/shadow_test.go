@@ -0,0 +1,34 @@
+package dbutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepCopy(t *testing.T) {
+	type stop struct {
+		ID       int
+		StopName *string
+		Tags     map[string]string
+		Codes    []string
+		Updated  time.Time
+	}
+	name := "Main St"
+	rows := []stop{{ID: 1, StopName: &name, Tags: map[string]string{"a": "1"}, Codes: []string{"x"}, Updated: time.Unix(0, 0)}}
+	c := deepCopy(reflect.ValueOf(rows)).Interface().([]stop)
+	assert.Equal(t, rows, c)
+	rows[0].ID = 2
+	*rows[0].StopName = "Elm St"
+	rows[0].Tags["a"] = "2"
+	rows[0].Codes[0] = "y"
+	rows = append(rows[:0], stop{ID: 3})
+	assert.Equal(t, 1, c[0].ID)
+	assert.Equal(t, "Main St", *c[0].StopName)
+	assert.Equal(t, map[string]string{"a": "1"}, c[0].Tags)
+	assert.Equal(t, []string{"x"}, c[0].Codes)
+	var empty []stop
+	assert.Nil(t, deepCopy(reflect.ValueOf(empty)).Interface())
+}
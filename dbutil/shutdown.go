@@ -0,0 +1,53 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrShuttingDown is returned for new queries and transactions on a database that is being shut down.
+var ErrShuttingDown = errors.New("database is shutting down")
+
+var shuttingDown sync.Map
+
+// checkShutdown returns ErrShuttingDown if db is being shut down.
+// Queries inside transactions that are already open are allowed to finish.
+func checkShutdown(db sqlx.Ext) error {
+	if d, ok := db.(*sqlx.DB); ok {
+		if _, ok := shuttingDown.Load(d); ok {
+			return ErrShuttingDown
+		}
+	}
+	return nil
+}
+
+// Shutdown stops new queries and transactions on db, waits for in-flight queries and
+// transactions to finish until ctx is done, and then closes db and pool, if given.
+// The databases are closed even if ctx is done first, in which case ctx's error is returned.
+func Shutdown(ctx context.Context, db *sqlx.DB, pool *pgxpool.Pool) error {
+	shuttingDown.Store(db, true)
+	defer shuttingDown.Delete(db)
+	var err error
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for err == nil && db.Stats().InUse > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			logger(ctx).Error().Err(err).Int("in_use", db.Stats().InUse).Msg("closing database with queries in flight")
+		case <-ticker.C:
+		}
+	}
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if pool != nil {
+		pool.Close()
+	}
+	return err
+}
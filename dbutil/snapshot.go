@@ -0,0 +1,193 @@
+package dbutil
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaSnapshot is a machine-readable description of database tables, suitable for
+// encoding as JSON for documentation or code generation.
+type SchemaSnapshot struct {
+	Tables []*TableSnapshot `json:"tables"`
+}
+
+// Table returns the table with the given schema and name, or nil.
+func (s *SchemaSnapshot) Table(schema string, name string) *TableSnapshot {
+	for _, t := range s.Tables {
+		if t.Schema == schema && t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+type TableSnapshot struct {
+	Schema      string               `json:"schema" db:"schema"`
+	Name        string               `json:"name" db:"name"`
+	Comment     string               `json:"comment,omitempty" db:"comment"`
+	Columns     []ColumnSnapshot     `json:"columns"`
+	Indexes     []IndexSnapshot      `json:"indexes"`
+	Constraints []ConstraintSnapshot `json:"constraints"`
+}
+
+// ForeignKeys returns the table's foreign key constraints.
+func (t *TableSnapshot) ForeignKeys() []ConstraintSnapshot {
+	var ret []ConstraintSnapshot
+	for _, c := range t.Constraints {
+		if c.Type == "f" {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+type ColumnSnapshot struct {
+	Name     string  `json:"name" db:"name"`
+	Type     string  `json:"type" db:"type"`
+	Nullable bool    `json:"nullable" db:"nullable"`
+	Default  *string `json:"default,omitempty" db:"default"`
+	Comment  string  `json:"comment,omitempty" db:"comment"`
+	Position int     `json:"position" db:"position"`
+}
+
+type IndexSnapshot struct {
+	Name       string `json:"name" db:"name"`
+	Definition string `json:"definition" db:"definition"`
+	Unique     bool   `json:"unique" db:"unique"`
+	Primary    bool   `json:"primary" db:"primary"`
+}
+
+// ConstraintSnapshot is a table constraint. Type is the pg_constraint contype:
+// p (primary key), u (unique), f (foreign key), c (check), or x (exclusion).
+type ConstraintSnapshot struct {
+	Name       string `json:"name" db:"name"`
+	Type       string `json:"type" db:"type"`
+	Definition string `json:"definition" db:"definition"`
+	RefTable   string `json:"ref_table,omitempty" db:"ref_table"`
+}
+
+// SnapshotSchema introspects the tables in the given schemas, or public if none are given.
+func SnapshotSchema(ctx context.Context, db sqlx.Ext, schemas ...string) (*SchemaSnapshot, error) {
+	if len(schemas) == 0 {
+		schemas = []string{"public"}
+	}
+	snapshot := &SchemaSnapshot{}
+	tableKey := func(schema, name string) string { return schema + "." + name }
+	tables := map[string]*TableSnapshot{}
+
+	// Tables
+	var tableRows []*TableSnapshot
+	q := sq.Select(
+		"n.nspname AS schema",
+		"c.relname AS name",
+		"coalesce(obj_description(c.oid, 'pg_class'), '') AS comment",
+	).
+		From("pg_class c").
+		Join("pg_namespace n ON n.oid = c.relnamespace").
+		Where("c.relkind IN ('r', 'p')").
+		Where("n.nspname = ANY(?)", schemas).
+		OrderBy("n.nspname", "c.relname")
+	if err := Select(ctx, db, q, &tableRows); err != nil {
+		return nil, err
+	}
+	for _, t := range tableRows {
+		tables[tableKey(t.Schema, t.Name)] = t
+		snapshot.Tables = append(snapshot.Tables, t)
+	}
+
+	// Columns
+	var colRows []struct {
+		ColumnSnapshot
+		TableSchema string `db:"table_schema"`
+		TableName   string `db:"table_name"`
+	}
+	q = sq.Select(
+		"n.nspname AS table_schema",
+		"c.relname AS table_name",
+		"a.attname AS name",
+		"format_type(a.atttypid, a.atttypmod) AS type",
+		"NOT a.attnotnull AS nullable",
+		"pg_get_expr(d.adbin, d.adrelid) AS default",
+		"coalesce(col_description(c.oid, a.attnum), '') AS comment",
+		"a.attnum AS position",
+	).
+		From("pg_attribute a").
+		Join("pg_class c ON c.oid = a.attrelid").
+		Join("pg_namespace n ON n.oid = c.relnamespace").
+		LeftJoin("pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum").
+		Where("c.relkind IN ('r', 'p')").
+		Where("a.attnum > 0").
+		Where("NOT a.attisdropped").
+		Where("n.nspname = ANY(?)", schemas).
+		OrderBy("n.nspname", "c.relname", "a.attnum")
+	if err := Select(ctx, db, q, &colRows); err != nil {
+		return nil, err
+	}
+	for _, row := range colRows {
+		if t, ok := tables[tableKey(row.TableSchema, row.TableName)]; ok {
+			t.Columns = append(t.Columns, row.ColumnSnapshot)
+		}
+	}
+
+	// Indexes
+	var indexRows []struct {
+		IndexSnapshot
+		TableSchema string `db:"table_schema"`
+		TableName   string `db:"table_name"`
+	}
+	q = sq.Select(
+		"n.nspname AS table_schema",
+		"t.relname AS table_name",
+		"i.relname AS name",
+		"pg_get_indexdef(i.oid) AS definition",
+		"x.indisunique AS unique",
+		"x.indisprimary AS primary",
+	).
+		From("pg_index x").
+		Join("pg_class i ON i.oid = x.indexrelid").
+		Join("pg_class t ON t.oid = x.indrelid").
+		Join("pg_namespace n ON n.oid = t.relnamespace").
+		Where("n.nspname = ANY(?)", schemas).
+		OrderBy("n.nspname", "t.relname", "i.relname")
+	if err := Select(ctx, db, q, &indexRows); err != nil {
+		return nil, err
+	}
+	for _, row := range indexRows {
+		if t, ok := tables[tableKey(row.TableSchema, row.TableName)]; ok {
+			t.Indexes = append(t.Indexes, row.IndexSnapshot)
+		}
+	}
+
+	// Constraints
+	var conRows []struct {
+		ConstraintSnapshot
+		TableSchema string `db:"table_schema"`
+		TableName   string `db:"table_name"`
+	}
+	q = sq.Select(
+		"n.nspname AS table_schema",
+		"t.relname AS table_name",
+		"con.conname AS name",
+		"con.contype::text AS type",
+		"pg_get_constraintdef(con.oid) AS definition",
+		"coalesce(rn.nspname || '.' || rt.relname, '') AS ref_table",
+	).
+		From("pg_constraint con").
+		Join("pg_class t ON t.oid = con.conrelid").
+		Join("pg_namespace n ON n.oid = t.relnamespace").
+		LeftJoin("pg_class rt ON rt.oid = con.confrelid").
+		LeftJoin("pg_namespace rn ON rn.oid = rt.relnamespace").
+		Where("n.nspname = ANY(?)", schemas).
+		OrderBy("n.nspname", "t.relname", "con.conname")
+	if err := Select(ctx, db, q, &conRows); err != nil {
+		return nil, err
+	}
+	for _, row := range conRows {
+		if t, ok := tables[tableKey(row.TableSchema, row.TableName)]; ok {
+			t.Constraints = append(t.Constraints, row.ConstraintSnapshot)
+		}
+	}
+	return snapshot, nil
+}
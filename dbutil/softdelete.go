@@ -0,0 +1,93 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+type unscopedContextKey struct{}
+
+// Unscoped returns a context in which soft-deleted rows are not filtered out by NotDeleted
+// or by Select and Get, e.g. for admin views or restoring records.
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedContextKey{}, true)
+}
+
+// IsUnscoped returns true if ctx was created by Unscoped.
+func IsUnscoped(ctx context.Context) bool {
+	v, _ := ctx.Value(unscopedContextKey{}).(bool)
+	return v
+}
+
+// SoftDeletable is implemented by entities whose rows are soft deleted.
+// Select and Get, and helpers built on them such as FindEnts, only return rows of these
+// entities where DeletedAtColumn is NULL, unless ctx is Unscoped. The column should be
+// qualified with the table name if queries may join other tables, e.g. "gtfs_stops.deleted_at".
+type SoftDeletable interface {
+	DeletedAtColumn() string
+}
+
+// scopeDeleted filters q to rows that are not soft deleted if the entity type of dest is SoftDeletable.
+func scopeDeleted(ctx context.Context, q sq.SelectBuilder, dest interface{}) sq.SelectBuilder {
+	if dest == nil || IsUnscoped(ctx) {
+		return q
+	}
+	t := reflect.TypeOf(dest)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+	if ent, ok := reflect.New(t).Interface().(SoftDeletable); ok {
+		return q.Where(fmt.Sprintf("%s IS NULL", ent.DeletedAtColumn()))
+	}
+	return q
+}
+
+// NotDeleted filters q to rows where the deleted_at column of table is NULL, unless ctx is Unscoped.
+// If table is empty, the column is not qualified.
+func NotDeleted(ctx context.Context, q sq.SelectBuilder, table string) sq.SelectBuilder {
+	if IsUnscoped(ctx) {
+		return q
+	}
+	col := "deleted_at"
+	if table != "" {
+		col = table + "." + col
+	}
+	return q.Where(fmt.Sprintf("%s IS NULL", col))
+}
+
+// SoftDelete sets deleted_at on rows with the given ids that are not already deleted.
+// Returns the number of rows deleted.
+func SoftDelete(ctx context.Context, db sqlx.Ext, table string, ids []int) (int64, error) {
+	q := sq.Update(QuoteIdentifier(table)).
+		Set("deleted_at", sq.Expr("now()")).
+		Where("id = ANY(?)", ids).
+		Where("deleted_at IS NULL")
+	return execRowsAffected(ctx, db, q)
+}
+
+// Restore clears deleted_at on rows with the given ids. Returns the number of rows restored.
+func Restore(ctx context.Context, db sqlx.Ext, table string, ids []int) (int64, error) {
+	q := sq.Update(QuoteIdentifier(table)).
+		Set("deleted_at", nil).
+		Where("id = ANY(?)", ids).
+		Where("deleted_at IS NOT NULL")
+	return execRowsAffected(ctx, db, q)
+}
+
+func execRowsAffected(ctx context.Context, db sqlx.Ext, q sq.Sqlizer) (int64, error) {
+	res, err := Exec(ctx, db, q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,47 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSoftDeleteEnt struct {
+	ID int
+}
+
+func (testSoftDeleteEnt) DeletedAtColumn() string {
+	return "gtfs_stops.deleted_at"
+}
+
+func TestScopeDeleted(t *testing.T) {
+	var queries []string
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			queries = append(queries, qi.Query)
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := context.Background()
+	q := sq.Select("*").From("gtfs_stops").Where("id = ?", 1)
+
+	var ents []*testSoftDeleteEnt
+	assert.NoError(t, Select(ctx, nil, q, &ents))
+	var ent testSoftDeleteEnt
+	assert.NoError(t, Get(ctx, nil, q, &ent))
+	assert.NoError(t, Select(Unscoped(ctx), nil, q, &ents))
+	var ids []int
+	assert.NoError(t, Select(ctx, nil, q, &ids))
+	_, err := FindEnts[testSoftDeleteEnt](ctx, nil, "gtfs_stops", []int{1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"SELECT * FROM gtfs_stops WHERE id = $1 AND gtfs_stops.deleted_at IS NULL",
+		"SELECT * FROM gtfs_stops WHERE id = $1 AND gtfs_stops.deleted_at IS NULL",
+		"SELECT * FROM gtfs_stops WHERE id = $1",
+		"SELECT * FROM gtfs_stops WHERE id = $1",
+		`SELECT * FROM "gtfs_stops" WHERE id = ANY($1) AND gtfs_stops.deleted_at IS NULL`,
+	}, queries)
+}
@@ -0,0 +1,167 @@
+package dbutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryStat is aggregated statistics for a query fingerprint or table.
+type QueryStat struct {
+	Key           string        `json:"key"`
+	Count         int64         `json:"count"`
+	Errors        int64         `json:"errors"`
+	Rows          int64         `json:"rows"`
+	TotalDuration time.Duration `json:"total_duration"`
+	MeanDuration  time.Duration `json:"mean_duration"`
+	P95Duration   time.Duration `json:"p95_duration"`
+}
+
+// QueryStats aggregates statistics for queries run through Select, Get, and Exec,
+// grouped by query fingerprint and by table.
+type QueryStats struct {
+	// SampleSize is the number of recent durations kept per key for percentiles.
+	SampleSize   int
+	fingerprints map[string]*queryStatAgg
+	tables       map[string]*queryStatAgg
+	lock         sync.Mutex
+}
+
+type queryStatAgg struct {
+	stat    QueryStat
+	samples []time.Duration
+	next    int
+}
+
+func NewQueryStats() *QueryStats {
+	s := &QueryStats{SampleSize: 1000}
+	s.Reset()
+	return s
+}
+
+var queryStats *QueryStats
+var queryStatsLock sync.RWMutex
+
+// SetQueryStats enables statistics collection into s, or disables it if s is nil.
+func SetQueryStats(s *QueryStats) {
+	queryStatsLock.Lock()
+	defer queryStatsLock.Unlock()
+	queryStats = s
+}
+
+func getQueryStats() *QueryStats {
+	queryStatsLock.RLock()
+	defer queryStatsLock.RUnlock()
+	return queryStats
+}
+
+// Reset clears all collected statistics.
+func (s *QueryStats) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.fingerprints = map[string]*queryStatAgg{}
+	s.tables = map[string]*queryStatAgg{}
+}
+
+// Fingerprints returns statistics per query fingerprint, ordered by total duration, descending.
+func (s *QueryStats) Fingerprints() []QueryStat {
+	return s.collect(func() map[string]*queryStatAgg { return s.fingerprints })
+}
+
+// Tables returns statistics per table, ordered by total duration, descending.
+func (s *QueryStats) Tables() []QueryStat {
+	return s.collect(func() map[string]*queryStatAgg { return s.tables })
+}
+
+// ServeHTTP writes the collected statistics as JSON, for use as a debug endpoint.
+func (s *QueryStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]QueryStat{
+		"fingerprints": s.Fingerprints(),
+		"tables":       s.Tables(),
+	})
+}
+
+// Record adds a query execution to the statistics.
+func (s *QueryStats) Record(qstr string, d time.Duration, rows int64, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.record(s.fingerprints, QueryFingerprint(qstr), d, rows, err)
+	for _, table := range queryTables(qstr) {
+		s.record(s.tables, table, d, rows, err)
+	}
+}
+
+func (s *QueryStats) record(m map[string]*queryStatAgg, key string, d time.Duration, rows int64, err error) {
+	agg, ok := m[key]
+	if !ok {
+		agg = &queryStatAgg{stat: QueryStat{Key: key}}
+		m[key] = agg
+	}
+	agg.stat.Count++
+	agg.stat.Rows += rows
+	agg.stat.TotalDuration += d
+	if err != nil {
+		agg.stat.Errors++
+	}
+	if len(agg.samples) < s.SampleSize {
+		agg.samples = append(agg.samples, d)
+	} else if s.SampleSize > 0 {
+		agg.samples[agg.next] = d
+		agg.next = (agg.next + 1) % s.SampleSize
+	}
+}
+
+func (s *QueryStats) collect(get func() map[string]*queryStatAgg) []QueryStat {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret []QueryStat
+	for _, agg := range get() {
+		stat := agg.stat
+		stat.MeanDuration = stat.TotalDuration / time.Duration(stat.Count)
+		if len(agg.samples) > 0 {
+			samples := append([]time.Duration{}, agg.samples...)
+			sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+			stat.P95Duration = samples[(len(samples)*95-1)/100]
+		}
+		ret = append(ret, stat)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].TotalDuration > ret[j].TotalDuration })
+	return ret
+}
+
+var (
+	fingerprintPlaceholder = regexp.MustCompile(`\$\d+`)
+	fingerprintString      = regexp.MustCompile(`'(?:[^']|'')*'`)
+	fingerprintNumber      = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	fingerprintList        = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	queryTablePattern      = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|INTO|UPDATE)\s+((?:"[^"]+"|[A-Za-z_][A-Za-z0-9_$]*)(?:\.(?:"[^"]+"|[A-Za-z_][A-Za-z0-9_$]*))?)`)
+)
+
+// QueryFingerprint normalizes a query by replacing placeholders and literals with ?
+// and collapsing lists of values, so queries differing only in arguments share a fingerprint.
+func QueryFingerprint(qstr string) string {
+	s := strings.Join(strings.Fields(qstr), " ")
+	s = fingerprintString.ReplaceAllString(s, "?")
+	s = fingerprintPlaceholder.ReplaceAllString(s, "?")
+	s = fingerprintNumber.ReplaceAllString(s, "?")
+	s = fingerprintList.ReplaceAllString(s, "(...)")
+	return s
+}
+
+func queryTables(qstr string) []string {
+	seen := map[string]bool{}
+	var ret []string
+	for _, m := range queryTablePattern.FindAllStringSubmatch(qstr, -1) {
+		table := strings.ReplaceAll(m[1], `"`, "")
+		if !seen[table] {
+			seen[table] = true
+			ret = append(ret, table)
+		}
+	}
+	return ret
+}
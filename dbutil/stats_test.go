@@ -0,0 +1,44 @@
+package dbutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryFingerprint(t *testing.T) {
+	tcs := []struct {
+		qstr   string
+		expect string
+	}{
+		{"SELECT * FROM stops WHERE id = $1", "SELECT * FROM stops WHERE id = ?"},
+		{"SELECT *\n  FROM stops WHERE id IN ($1,$2, $3)", "SELECT * FROM stops WHERE id IN (...)"},
+		{"SELECT * FROM stops WHERE name = 'O''Hare' LIMIT 10", "SELECT * FROM stops WHERE name = ? LIMIT ?"},
+	}
+	for _, tc := range tcs {
+		assert.Equal(t, tc.expect, QueryFingerprint(tc.qstr))
+	}
+}
+
+func Test_queryTables(t *testing.T) {
+	assert.Equal(t, []string{"gtfs_stops", "tl.feed_versions"}, queryTables(`SELECT * FROM gtfs_stops JOIN "tl"."feed_versions" ON true`))
+	assert.Equal(t, []string{"gtfs_routes"}, queryTables("INSERT INTO gtfs_routes (id) VALUES ($1)"))
+}
+
+func TestQueryStats(t *testing.T) {
+	s := NewQueryStats()
+	for i := 1; i <= 100; i++ {
+		s.Record("SELECT * FROM stops WHERE id = $1", time.Duration(i)*time.Millisecond, 1, nil)
+	}
+	fps := s.Fingerprints()
+	if assert.Equal(t, 1, len(fps)) {
+		assert.Equal(t, int64(100), fps[0].Count)
+		assert.Equal(t, int64(100), fps[0].Rows)
+		assert.Equal(t, 95*time.Millisecond, fps[0].P95Duration)
+	}
+	tables := s.Tables()
+	if assert.Equal(t, 1, len(tables)) {
+		assert.Equal(t, "stops", tables[0].Key)
+	}
+}
@@ -0,0 +1,148 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StatementClass groups statements for default timeouts.
+type StatementClass int
+
+const (
+	ReadStatement StatementClass = iota
+	WriteStatement
+	DDLStatement
+	CopyStatement
+)
+
+// Timeouts are default timeouts by statement class, applied only when the caller's
+// context has no deadline. A zero value means no default timeout.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	DDL   time.Duration
+	Copy  time.Duration
+}
+
+var defaultTimeouts Timeouts
+var defaultTimeoutsLock sync.RWMutex
+
+// SetDefaultTimeouts sets the default timeouts used by Select, Get, and Exec.
+func SetDefaultTimeouts(t Timeouts) {
+	defaultTimeoutsLock.Lock()
+	defer defaultTimeoutsLock.Unlock()
+	defaultTimeouts = t
+}
+
+// WithDefaultTimeout returns a context with the default timeout for class,
+// unless ctx already has a deadline or no default is configured.
+func WithDefaultTimeout(ctx context.Context, class StatementClass) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	defaultTimeoutsLock.RLock()
+	t := defaultTimeouts
+	defaultTimeoutsLock.RUnlock()
+	var d time.Duration
+	switch class {
+	case ReadStatement:
+		d = t.Read
+	case WriteStatement:
+		d = t.Write
+	case DDLStatement:
+		d = t.DDL
+	case CopyStatement:
+		d = t.Copy
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+type statementTimeoutContextKey struct{}
+
+// WithStatementTimeout returns a context that limits each statement run through Select, Get, and Exec
+// to d, overriding any default timeout. Transactions started by Tx with this context also issue
+// SET LOCAL statement_timeout, so the limit is enforced by the server as well.
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutContextKey{}, d)
+}
+
+func statementTimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(statementTimeoutContextKey{}).(time.Duration)
+	return d, ok && d > 0
+}
+
+// withQueryTimeout applies the context statement timeout, if any, or the default timeout for class.
+// All query paths call this before running a query, so it also marks ctx for QueryTracer
+// to skip queries that are already logged by this package.
+func withQueryTimeout(ctx context.Context, class StatementClass) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, tracedContextKey{}, true)
+	if d, ok := statementTimeoutFromContext(ctx); ok {
+		return context.WithTimeout(ctx, d)
+	}
+	return WithDefaultTimeout(ctx, class)
+}
+
+// setLocalStatementTimeout sets statement_timeout for the remainder of tx, if ctx has a statement timeout.
+func setLocalStatementTimeout(ctx context.Context, tx sqlx.ExecerContext) error {
+	d, ok := statementTimeoutFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, statementTimeoutStatement(d))
+	return err
+}
+
+func statementTimeoutStatement(d time.Duration) string {
+	return fmt.Sprintf("SET LOCAL statement_timeout = %d", d.Milliseconds())
+}
+
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "COMMENT", "REINDEX", "VACUUM", "CLUSTER"}
+
+var (
+	quotedToken  = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"`)
+	rowLockQuery = regexp.MustCompile(`(?i)\bFOR\s+(?:NO\s+KEY\s+)?UPDATE\b`)
+	writeKeyword = regexp.MustCompile(`(?i)\b(?:INSERT|UPDATE|DELETE|MERGE)\b`)
+)
+
+// writableCTE returns true if a WITH statement contains a data-modifying statement,
+// ignoring string literals, quoted identifiers, and FOR UPDATE row locks.
+func writableCTE(qstr string) bool {
+	s := quotedToken.ReplaceAllString(qstr, "")
+	s = rowLockQuery.ReplaceAllString(s, "")
+	return writeKeyword.MatchString(s)
+}
+
+// ClassifyStatement returns the statement class for a SQL string.
+func ClassifyStatement(qstr string) StatementClass {
+	fields := strings.Fields(qstr)
+	if len(fields) == 0 {
+		return WriteStatement
+	}
+	kw := strings.ToUpper(fields[0])
+	switch kw {
+	case "WITH":
+		if writableCTE(qstr) {
+			return WriteStatement
+		}
+		return ReadStatement
+	case "SELECT", "EXPLAIN", "SHOW", "VALUES", "TABLE":
+		return ReadStatement
+	case "COPY":
+		return CopyStatement
+	}
+	for _, ddl := range ddlKeywords {
+		if kw == ddl {
+			return DDLStatement
+		}
+	}
+	return WriteStatement
+}
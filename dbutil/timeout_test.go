@@ -0,0 +1,54 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tcs := []struct {
+		qstr  string
+		class StatementClass
+	}{
+		{"SELECT 1", ReadStatement},
+		{"  with a as (select 1) select * from a", ReadStatement},
+		{"WITH a AS (SELECT id FROM b FOR UPDATE) SELECT 'delete' AS \"update\" FROM a", ReadStatement},
+		{"WITH upd AS (UPDATE a SET b = 1 RETURNING id) SELECT count(*) FROM upd", WriteStatement},
+		{"with d as (delete from a returning *) insert into b select * from d", WriteStatement},
+		{"INSERT INTO a VALUES (1)", WriteStatement},
+		{"delete from a", WriteStatement},
+		{"CREATE INDEX ON a(b)", DDLStatement},
+		{"alter table a add column b int", DDLStatement},
+		{"COPY a FROM STDIN", CopyStatement},
+	}
+	for _, tc := range tcs {
+		assert.Equal(t, tc.class, ClassifyStatement(tc.qstr), tc.qstr)
+	}
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	SetDefaultTimeouts(Timeouts{Read: time.Second})
+	defer SetDefaultTimeouts(Timeouts{})
+	ctx, cancel := WithDefaultTimeout(context.Background(), ReadStatement)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.True(t, ok)
+	ctx, cancel = WithDefaultTimeout(context.Background(), DDLStatement)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestWithStatementTimeout(t *testing.T) {
+	SetDefaultTimeouts(Timeouts{Read: time.Hour})
+	defer SetDefaultTimeouts(Timeouts{})
+	ctx := WithStatementTimeout(context.Background(), time.Second)
+	ctx, cancel := withQueryTimeout(ctx, ReadStatement)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Less(t, time.Until(deadline), time.Minute)
+}
@@ -0,0 +1,91 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type tracedContextKey struct{}
+
+type traceStartContextKey struct{}
+
+type traceStart struct {
+	t     time.Time
+	sql   string
+	args  []interface{}
+	batch *time.Time
+}
+
+// QueryTracer implements the pgx query, batch, and copy tracers, sending queries run
+// directly on a pgx connection or pool to the same logging, journal, and statistics
+// as Select, Get, and Exec. Queries run through those functions are not recorded twice.
+type QueryTracer struct{}
+
+// ConfigureTracer adds a QueryTracer to a pool config, keeping any existing tracer.
+func ConfigureTracer(cfg *pgxpool.Config) {
+	if cfg.ConnConfig.Tracer == nil {
+		cfg.ConnConfig.Tracer = QueryTracer{}
+	} else {
+		cfg.ConnConfig.Tracer = multitracer.New(cfg.ConnConfig.Tracer, QueryTracer{})
+	}
+}
+
+func (QueryTracer) traced(ctx context.Context) bool {
+	v, _ := ctx.Value(tracedContextKey{}).(bool)
+	return v
+}
+
+func (tr QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if tr.traced(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, traceStartContextKey{}, &traceStart{t: time.Now(), sql: data.SQL, args: data.Args})
+}
+
+func (tr QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if s, ok := ctx.Value(traceStartContextKey{}).(*traceStart); ok {
+		logQuery(ctx, s.sql, s.args, s.t, data.CommandTag.RowsAffected(), data.Err)
+	}
+}
+
+func (tr QueryTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	if tr.traced(ctx) {
+		return ctx
+	}
+	t := time.Now()
+	return context.WithValue(ctx, traceStartContextKey{}, &traceStart{t: t, batch: &t})
+}
+
+// TraceBatchQuery records each query in a batch, timed from the end of the previous query.
+func (tr QueryTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if s, ok := ctx.Value(traceStartContextKey{}).(*traceStart); ok && s.batch != nil {
+		logQuery(ctx, data.SQL, data.Args, *s.batch, data.CommandTag.RowsAffected(), data.Err)
+		*s.batch = time.Now()
+	}
+}
+
+func (tr QueryTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	if _, ok := ctx.Value(traceStartContextKey{}).(*traceStart); ok && data.Err != nil {
+		logger(ctx).Error().Err(data.Err).Msg("batch failed")
+	}
+}
+
+func (tr QueryTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	if tr.traced(ctx) {
+		return ctx
+	}
+	qstr := fmt.Sprintf("COPY %s (%s) FROM STDIN", data.TableName.Sanitize(), strings.Join(data.ColumnNames, ", "))
+	return context.WithValue(ctx, traceStartContextKey{}, &traceStart{t: time.Now(), sql: qstr})
+}
+
+func (tr QueryTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	if s, ok := ctx.Value(traceStartContextKey{}).(*traceStart); ok {
+		logQuery(ctx, s.sql, nil, s.t, data.CommandTag.RowsAffected(), data.Err)
+	}
+}
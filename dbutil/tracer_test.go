@@ -0,0 +1,27 @@
+package dbutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryTracer(t *testing.T) {
+	tr := QueryTracer{}
+	ctx, j := WithJournal(context.Background())
+	qctx := tr.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tr.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: errors.New("failed")})
+	if assert.Equal(t, 1, len(j.Entries())) {
+		assert.Equal(t, "SELECT 1", j.Entries()[0].Query)
+	}
+
+	// Queries already logged by Select, Get, and Exec are skipped
+	tctx, cancel := withQueryTimeout(ctx, ReadStatement)
+	defer cancel()
+	qctx = tr.TraceQueryStart(tctx, nil, pgx.TraceQueryStartData{SQL: "SELECT 2"})
+	tr.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{})
+	assert.Equal(t, 1, len(j.Entries()))
+}
@@ -0,0 +1,54 @@
+package dbutil
+
+import (
+	"context"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// TruncateOptions configures TruncateTables.
+type TruncateOptions struct {
+	// Cascade also truncates tables with foreign keys referencing the truncated tables.
+	Cascade bool
+	// RestartIdentity resets sequences owned by the truncated tables.
+	RestartIdentity bool
+}
+
+// TruncateTables truncates the given tables in a single statement.
+func TruncateTables(ctx context.Context, db sqlx.Ext, opts TruncateOptions, tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	var quoted []string
+	for _, table := range tables {
+		quoted = append(quoted, QuoteIdentifier(table))
+	}
+	qstr := "TRUNCATE " + strings.Join(quoted, ", ")
+	if opts.RestartIdentity {
+		qstr = qstr + " RESTART IDENTITY"
+	}
+	if opts.Cascade {
+		qstr = qstr + " CASCADE"
+	}
+	_, err := Exec(ctx, db, RawSQL(qstr))
+	return err
+}
+
+// TruncateAllExcept truncates every table in schema except the named tables,
+// e.g. to reset a test database while keeping migration and reference tables.
+func TruncateAllExcept(ctx context.Context, db sqlx.Ext, opts TruncateOptions, schema string, except ...string) error {
+	var tables []string
+	q := sq.Select("schemaname || '.' || tablename").
+		From("pg_tables").
+		Where("schemaname = ?", schema).
+		OrderBy("tablename")
+	if len(except) > 0 {
+		q = q.Where(sq.NotEq{"tablename": except})
+	}
+	if err := Select(ctx, db, q, &tables); err != nil {
+		return err
+	}
+	return TruncateTables(ctx, db, opts, tables...)
+}
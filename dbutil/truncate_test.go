@@ -0,0 +1,18 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateTables(t *testing.T) {
+	ctx, d := WithDryRun(AllowDDL(context.Background()))
+	assert.NoError(t, TruncateTables(ctx, nil, TruncateOptions{Cascade: true}, "public.gtfs_stops", "what?"))
+	assert.NoError(t, CreatePartition(ctx, nil, "gtfs_stops", "gtfs_stops_?", PartitionBound("FOR VALUES IN ('a?')")))
+	if stmts := d.Statements(); assert.Len(t, stmts, 2) {
+		assert.Equal(t, `TRUNCATE "public"."gtfs_stops", "what?" CASCADE`, stmts[0].Query)
+		assert.Equal(t, `CREATE TABLE "gtfs_stops_?" PARTITION OF "gtfs_stops" FOR VALUES IN ('a?')`, stmts[1].Query)
+	}
+}
@@ -0,0 +1,86 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// NotExpired filters q to rows where the expires_at column of table is NULL or in the future.
+// If table is empty, the column is not qualified.
+func NotExpired(q sq.SelectBuilder, table string) sq.SelectBuilder {
+	col := "expires_at"
+	if table != "" {
+		col = table + "." + col
+	}
+	return q.Where(fmt.Sprintf("(%s IS NULL OR %s > now())", col, col))
+}
+
+// PurgeExpired deletes rows from table whose expires_at has passed, in batches of at most batchSize rows.
+// Returns the total number of rows deleted.
+func PurgeExpired(ctx context.Context, db sqlx.Ext, table string, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("purge batch size must be greater than 0, got %d", batchSize)
+	}
+	total := int64(0)
+	for {
+		t := QuoteIdentifier(table)
+		q := sq.Expr(
+			fmt.Sprintf("DELETE FROM %s WHERE ctid = ANY(ARRAY(SELECT ctid FROM %s WHERE expires_at <= now() LIMIT ?))", t, t),
+			batchSize,
+		)
+		res, err := Exec(ctx, db, q)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// Purger periodically deletes expired rows from a set of tables.
+type Purger struct {
+	Tables    []string
+	BatchSize int
+	Interval  time.Duration
+}
+
+func NewPurger(tables ...string) *Purger {
+	return &Purger{
+		Tables:    tables,
+		BatchSize: 1000,
+		Interval:  time.Minute,
+	}
+}
+
+// Start purges expired rows every Interval until the context is done.
+func (p *Purger) Start(ctx context.Context, db sqlx.Ext) {
+	go func() {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		for {
+			for _, table := range p.Tables {
+				n, err := PurgeExpired(ctx, db, table, p.BatchSize)
+				if err != nil {
+					logger(ctx).Error().Err(err).Str("table", table).Msg("purger: could not purge expired rows")
+				} else if n > 0 {
+					logger(ctx).Trace().Int64("count", n).Str("table", table).Msg("purger: purged expired rows")
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,29 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeExpired(t *testing.T) {
+	// Two full batches, then a partial batch
+	batches := []int64{2, 2, 1}
+	SetMiddleware(func(next QueryHandler) QueryHandler {
+		return func(ctx context.Context, qi *QueryInfo) error {
+			qi.Result, batches = driver.RowsAffected(batches[0]), batches[1:]
+			return nil
+		}
+	})
+	defer SetMiddleware()
+	ctx := context.Background()
+	n, err := PurgeExpired(ctx, nil, "tl_sessions", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Empty(t, batches)
+
+	_, err = PurgeExpired(ctx, nil, "tl_sessions", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package dbutil
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Tx runs cb inside a transaction.
+// The transaction is committed if cb returns nil and rolled back otherwise.
+// If ctx was created by WithJournal, a failed transaction returns a *JournalError.
+// Statement timeouts and schemas from ctx are applied with SET LOCAL.
+func Tx(ctx context.Context, db *sqlx.DB, cb func(*sqlx.Tx) error) error {
+	if err := checkShutdown(db); err != nil {
+		return err
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("could not begin transaction")
+		return err
+	}
+	if err := setLocalStatementTimeout(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := setLocalSearchPath(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer clearSearchPath(tx)
+	if err := cb(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger(ctx).Error().Err(rbErr).Msg("could not rollback transaction")
+		}
+		return wrapJournalError(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger(ctx).Error().Err(err).Msg("could not commit transaction")
+		return wrapJournalError(ctx, err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// EntReport describes differences between an entity struct and its database table.
+type EntReport struct {
+	Table string `json:"table"`
+	Type  string `json:"type"`
+	// MissingColumns are mapped struct fields with no matching column.
+	MissingColumns []string `json:"missing_columns,omitempty"`
+	// ExtraColumns are table columns with no matching struct field.
+	ExtraColumns []string         `json:"extra_columns,omitempty"`
+	Mismatches   []ColumnMismatch `json:"mismatches,omitempty"`
+}
+
+// ColumnMismatch is a struct field whose Go type can not hold the column's values.
+type ColumnMismatch struct {
+	Column string `json:"column"`
+	GoType string `json:"go_type"`
+	DBType string `json:"db_type"`
+	Reason string `json:"reason"`
+}
+
+// OK returns true if every struct field maps to a compatible column.
+// Extra columns are allowed, since entities often map only part of a table.
+func (r EntReport) OK() bool {
+	return len(r.MissingColumns) == 0 && len(r.Mismatches) == 0
+}
+
+func (r EntReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s (%s):", r.Table, r.Type))
+	for _, col := range r.MissingColumns {
+		sb.WriteString(fmt.Sprintf("\n  missing column: %s", col))
+	}
+	for _, m := range r.Mismatches {
+		sb.WriteString(fmt.Sprintf("\n  %s: %s can not hold %s: %s", m.Column, m.GoType, m.DBType, m.Reason))
+	}
+	for _, col := range r.ExtraColumns {
+		sb.WriteString(fmt.Sprintf("\n  unmapped column: %s", col))
+	}
+	return sb.String()
+}
+
+// ValidateEnts checks entity structs, keyed by table name, against the live schema,
+// to catch schema drift at startup instead of at the first failing query.
+func ValidateEnts(ctx context.Context, db sqlx.Ext, ents map[string]interface{}) ([]EntReport, error) {
+	var tables []string
+	for table := range ents {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	var ret []EntReport
+	for _, table := range tables {
+		report, err := ValidateEnt(ctx, db, table, ents[table])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, report)
+	}
+	return ret, nil
+}
+
+// ValidateEnt checks an entity struct against the columns of table in information_schema.
+func ValidateEnt(ctx context.Context, db sqlx.Ext, table string, ent interface{}) (EntReport, error) {
+	t := reflect.TypeOf(ent)
+	report := EntReport{Table: table, Type: reflectx.Deref(t).String()}
+	schema, name := "public", table
+	if i := strings.Index(table, "."); i >= 0 {
+		schema, name = table[:i], table[i+1:]
+	}
+	var cols []struct {
+		ColumnName string
+		DataType   string
+		IsNullable string
+	}
+	q := sq.Select("column_name", "data_type", "is_nullable").
+		From("information_schema.columns").
+		Where(sq.Eq{"table_schema": schema, "table_name": name}).
+		OrderBy("ordinal_position")
+	if err := Select(ctx, db, q, &cols); err != nil {
+		return report, err
+	}
+	if len(cols) == 0 {
+		return report, fmt.Errorf("table %s does not exist", table)
+	}
+	fields := map[string]reflect.Type{}
+	for _, fi := range entColumns(t) {
+		fields[fi.Name] = fi.Field.Type
+	}
+	seen := map[string]bool{}
+	for _, col := range cols {
+		seen[col.ColumnName] = true
+		ft, ok := fields[col.ColumnName]
+		if !ok {
+			report.ExtraColumns = append(report.ExtraColumns, col.ColumnName)
+			continue
+		}
+		if reason := columnTypeMismatch(ft, col.DataType, col.IsNullable == "YES"); reason != "" {
+			report.Mismatches = append(report.Mismatches, ColumnMismatch{
+				Column: col.ColumnName,
+				GoType: ft.String(),
+				DBType: col.DataType,
+				Reason: reason,
+			})
+		}
+	}
+	for _, fi := range entColumns(t) {
+		if !seen[fi.Name] {
+			report.MissingColumns = append(report.MissingColumns, fi.Name)
+		}
+	}
+	return report, nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// columnTypeMismatch returns why a Go type can not hold values of an information_schema data_type,
+// or an empty string if it can. Types implementing sql.Scanner are assumed to be compatible.
+func columnTypeMismatch(ft reflect.Type, dataType string, nullable bool) string {
+	if reflect.PointerTo(ft).Implements(scannerType) || ft.Implements(valuerType) {
+		return ""
+	}
+	if ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+		nullable = false
+		if reflect.PointerTo(ft).Implements(scannerType) {
+			return ""
+		}
+	}
+	if nullable && ft.Kind() != reflect.Slice && ft.Kind() != reflect.Map && ft.Kind() != reflect.Interface {
+		return "nullable column requires a pointer or nullable type"
+	}
+	var ok bool
+	switch {
+	case ft == timeType:
+		ok = strings.HasPrefix(dataType, "timestamp") || dataType == "date"
+	case ft.Kind() == reflect.String:
+		ok = dataType == "text" || dataType == "uuid" || dataType == "USER-DEFINED" ||
+			strings.HasPrefix(dataType, "character") || dataType == "json" || dataType == "jsonb"
+	case ft.Kind() == reflect.Bool:
+		ok = dataType == "boolean"
+	case ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Uint64:
+		ok = dataType == "integer" || dataType == "bigint" || dataType == "smallint"
+	case ft.Kind() == reflect.Float32 || ft.Kind() == reflect.Float64:
+		ok = dataType == "double precision" || dataType == "real" || dataType == "numeric" ||
+			dataType == "integer" || dataType == "bigint" || dataType == "smallint"
+	case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Uint8:
+		ok = dataType == "bytea" || dataType == "json" || dataType == "jsonb" || dataType == "text"
+	case ft.Kind() == reflect.Slice:
+		ok = dataType == "ARRAY"
+	default:
+		ok = true
+	}
+	if !ok {
+		return "incompatible type"
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package dbutil
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnTypeMismatch(t *testing.T) {
+	var s string
+	var ps *string
+	var i int64
+	var tm time.Time
+	var ns sql.NullString
+	var arr Array[int]
+	tcs := []struct {
+		v        interface{}
+		dataType string
+		nullable bool
+		ok       bool
+	}{
+		{s, "text", false, true},
+		{s, "character varying", false, true},
+		{s, "text", true, false},
+		{ps, "text", true, true},
+		{s, "integer", false, false},
+		{i, "bigint", false, true},
+		{i, "text", false, false},
+		{tm, "timestamp with time zone", false, true},
+		{tm, "boolean", false, false},
+		{ns, "text", true, true},
+		{arr, "ARRAY", true, true},
+		{[]int{}, "ARRAY", false, true},
+	}
+	for _, tc := range tcs {
+		reason := columnTypeMismatch(reflect.TypeOf(tc.v), tc.dataType, tc.nullable)
+		assert.Equal(t, tc.ok, reason == "", "%T %s nullable=%t: %s", tc.v, tc.dataType, tc.nullable, reason)
+	}
+}
@@ -0,0 +1,104 @@
+package dbutil
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Values returns a "(VALUES (...), ...) AS alias(cols)" expression for use as a join target,
+// e.g. with sq.ConcatExpr in JoinClause. Each row must have one value per column.
+// Columns may be given as "name type", e.g. "id int", to cast each value to type; otherwise
+// values are untyped parameters, which Postgres resolves as text.
+// Every value is a bind parameter, so large sets should use Unnest instead.
+// Returns an error if there are no rows, since VALUES requires at least one.
+func Values(alias string, cols []string, rows [][]interface{}) (sq.Sqlizer, error) {
+	if len(cols) == 0 {
+		return nil, errors.New("values: no columns")
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("values: no rows")
+	}
+	var names, params []string
+	for _, col := range cols {
+		name, typ := splitColumn(col)
+		names = append(names, QuoteIdentifier(name))
+		if typ == "" {
+			params = append(params, "?")
+		} else {
+			params = append(params, "?::"+typ)
+		}
+	}
+	var sb strings.Builder
+	var args []interface{}
+	sb.WriteString("(VALUES ")
+	for i, row := range rows {
+		if len(row) != len(cols) {
+			return nil, fmt.Errorf("values: row %d has %d values for %d columns", i, len(row), len(cols))
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(" + strings.Join(params, ",") + ")")
+		args = append(args, row...)
+	}
+	sb.WriteString(fmt.Sprintf(") AS %s(%s)", QuoteIdentifier(alias), strings.Join(names, ", ")))
+	return sq.Expr(sb.String(), args...), nil
+}
+
+// Unnest returns an "unnest(?::type[], ...) AS alias(cols)" expression that passes each column
+// as a single array parameter, so sets of any size use one bind parameter per column.
+// Columns are given as "name type", e.g. "id int", "stop_name text", and arrays must be
+// slices of equal length, one per column.
+func Unnest(alias string, cols []string, arrays ...interface{}) (sq.Sqlizer, error) {
+	if len(cols) != len(arrays) {
+		return nil, fmt.Errorf("unnest: %d columns but %d arrays", len(cols), len(arrays))
+	}
+	var names, params []string
+	for _, col := range cols {
+		name, typ := splitColumn(col)
+		if typ == "" {
+			return nil, fmt.Errorf("unnest: column '%s' must be given as 'name type'", col)
+		}
+		names = append(names, QuoteIdentifier(name))
+		params = append(params, fmt.Sprintf("?::%s[]", typ))
+	}
+	return sq.Expr(fmt.Sprintf("unnest(%s) AS %s(%s)", strings.Join(params, ", "), QuoteIdentifier(alias), strings.Join(names, ", ")), arrays...), nil
+}
+
+// splitColumn splits a column given as "name type" into its name and type, which may be empty.
+func splitColumn(col string) (string, string) {
+	parts := strings.Fields(col)
+	if len(parts) == 0 {
+		return "", ""
+	}
+	return parts[0], strings.Join(parts[1:], " ")
+}
+
+// EntUnnest is Unnest with column arrays built from the mapped fields of a slice of entity structs.
+// Columns are given as "name type", where name is the mapped column name.
+func EntUnnest(alias string, ents interface{}, cols ...string) (sq.Sqlizer, error) {
+	v := reflect.ValueOf(ents)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("unnest: expected a slice, got %T", ents)
+	}
+	tm := entMapper.TypeMap(reflectx.Deref(v.Type().Elem()))
+	var arrays []interface{}
+	for _, col := range cols {
+		name, _ := splitColumn(col)
+		fi, ok := tm.Names[name]
+		if !ok {
+			return nil, fmt.Errorf("unnest: %s has no column '%s'", v.Type().Elem(), name)
+		}
+		arr := reflect.MakeSlice(reflect.SliceOf(fi.Field.Type), 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			arr = reflect.Append(arr, reflectx.FieldByIndexesReadOnly(reflect.Indirect(v.Index(i)), fi.Index))
+		}
+		arrays = append(arrays, arr.Interface())
+	}
+	return Unnest(alias, cols, arrays...)
+}
@@ -0,0 +1,49 @@
+package dbutil
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValues(t *testing.T) {
+	v, err := Values("v", []string{"id", "name"}, [][]interface{}{{1, "a"}, {2, "b"}})
+	assert.NoError(t, err)
+	q := sq.Select("s.*").From("gtfs_stops s").JoinClause(sq.ConcatExpr("JOIN ", v, " ON v.id = s.id")).PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT s.* FROM gtfs_stops s JOIN (VALUES ($1,$2), ($3,$4)) AS "v"("id", "name") ON v.id = s.id`, qstr)
+	assert.Equal(t, []interface{}{1, "a", 2, "b"}, qargs)
+
+	v, err = Values("v", []string{"id int", "name"}, [][]interface{}{{1, "a"}, {2, "b"}})
+	assert.NoError(t, err)
+	qstr, _, err = v.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `(VALUES (?::int,?), (?::int,?)) AS "v"("id", "name")`, qstr)
+
+	_, err = Values("v", []string{"id", "name"}, nil)
+	assert.Error(t, err)
+	_, err = Values("v", []string{"id", "name"}, [][]interface{}{{1, "a"}, {2}})
+	assert.Error(t, err)
+	_, err = Values("v", nil, [][]interface{}{{}})
+	assert.Error(t, err)
+}
+
+func TestEntUnnest(t *testing.T) {
+	type ent struct {
+		ID       int
+		StopName string
+	}
+	u, err := EntUnnest("v", []*ent{{ID: 1, StopName: "a"}, {ID: 2, StopName: "b"}}, "id int", "stop_name text")
+	assert.NoError(t, err)
+	qstr, qargs, err := u.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, `unnest(?::int[], ?::text[]) AS "v"("id", "stop_name")`, qstr)
+	assert.Equal(t, []interface{}{[]int{1, 2}, []string{"a", "b"}}, qargs)
+
+	_, err = EntUnnest("v", []ent{}, "missing int")
+	assert.Error(t, err)
+	_, err = Unnest("v", []string{"id"}, []int{1})
+	assert.Error(t, err)
+}
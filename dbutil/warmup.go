@@ -0,0 +1,85 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// WarmupOptions configures Warmup.
+type WarmupOptions struct {
+	// Connections is the number of pool connections to establish.
+	Connections int
+	// Entities are structs, or pointers to structs, whose field mappings are precomputed
+	// and checked with ValidateMapping.
+	Entities []interface{}
+	// Queries are run once each and their results discarded, priming the
+	// driver's per-connection statement cache and the server's caches.
+	Queries []sq.Sqlizer
+}
+
+// Warmup prepares db for traffic so the first requests after a deploy don't pay cold-start latency.
+func Warmup(ctx context.Context, db *sqlx.DB, opts WarmupOptions) error {
+	if err := warmConnections(ctx, db, opts.Connections); err != nil {
+		return err
+	}
+	for _, ent := range opts.Entities {
+		t := reflectx.Deref(reflect.TypeOf(ent))
+		if t.Kind() == reflect.Slice {
+			t = reflectx.Deref(t.Elem())
+		}
+		db.Mapper.TypeMap(t)
+		entMapper.TypeMap(t)
+		if err := ValidateMapping(t); err != nil {
+			return err
+		}
+	}
+	for _, q := range opts.Queries {
+		if _, err := Exec(ctx, db, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmConnections holds n connections at once so the pool must open distinct connections.
+func warmConnections(ctx context.Context, db *sqlx.DB, n int) error {
+	var conns []*sql.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateMapping returns an error if a struct type has db tags that are not valid column names,
+// or more than one field mapped to the same column.
+func ValidateMapping(t reflect.Type) error {
+	seen := map[string]string{}
+	for _, fi := range entColumns(t) {
+		if !identifierPattern.MatchString(fi.Name) {
+			return fmt.Errorf("%s: field %s maps to invalid column name '%s'", t, fi.Field.Name, fi.Name)
+		}
+		if other, ok := seen[fi.Name]; ok {
+			return fmt.Errorf("%s: fields %s and %s both map to column '%s'", t, other, fi.Field.Name, fi.Name)
+		}
+		seen[fi.Name] = fi.Field.Name
+	}
+	return nil
+}
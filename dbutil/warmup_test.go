@@ -0,0 +1,28 @@
+package dbutil
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMapping(t *testing.T) {
+	type good struct {
+		ID        int
+		FeedID    string `db:"feed_id"`
+		Ignored   string `db:"-"`
+		RouteType int
+	}
+	type duplicate struct {
+		ID    int
+		Other int `db:"id"`
+	}
+	type invalid struct {
+		Name string `db:"route name"`
+	}
+	assert.NoError(t, ValidateMapping(reflect.TypeOf(good{})))
+	assert.NoError(t, ValidateMapping(reflect.TypeOf(&good{})))
+	assert.ErrorContains(t, ValidateMapping(reflect.TypeOf(duplicate{})), "both map to column 'id'")
+	assert.ErrorContains(t, ValidateMapping(reflect.TypeOf(invalid{})), "invalid column name")
+}
@@ -0,0 +1,59 @@
+// Package geo provides squirrel Sqlizers for PostGIS predicates and ordering,
+// so spatial filters compose with other query builders and use bind parameters.
+package geo
+
+import (
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Point is a WGS84 longitude and latitude.
+type Point struct {
+	Lon float64
+	Lat float64
+}
+
+// BBox is a WGS84 bounding box.
+type BBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// pointExpr is a SRID 4326 geometry point with two bind parameters.
+const pointExpr = "ST_SetSRID(ST_MakePoint(?, ?), 4326)"
+
+// DWithin matches rows where col is within meters of pt.
+// col must be a geography; cast geometry columns with "col::geography".
+func DWithin(col string, pt Point, meters float64) sq.Sqlizer {
+	return sq.Expr("ST_DWithin("+col+", "+pointExpr+"::geography, ?)", pt.Lon, pt.Lat, meters)
+}
+
+// IntersectsBBox matches rows where the bounding box of col intersects bbox, using the && operator,
+// which can use a GiST index on col.
+func IntersectsBBox(col string, bbox BBox) sq.Sqlizer {
+	return sq.Expr(col+" && ST_MakeEnvelope(?, ?, ?, ?, 4326)", bbox.MinLon, bbox.MinLat, bbox.MaxLon, bbox.MaxLat)
+}
+
+// Intersects matches rows where the geometry col intersects bbox exactly,
+// not only its bounding box.
+func Intersects(col string, bbox BBox) sq.Sqlizer {
+	return sq.Expr("ST_Intersects("+col+", ST_MakeEnvelope(?, ?, ?, ?, 4326))", bbox.MinLon, bbox.MinLat, bbox.MaxLon, bbox.MaxLat)
+}
+
+// Contains matches rows where the geometry col contains pt, e.g. polygons containing a location.
+func Contains(col string, pt Point) sq.Sqlizer {
+	return sq.Expr("ST_Contains("+col+", "+pointExpr+")", pt.Lon, pt.Lat)
+}
+
+// Distance is the distance in meters from col to pt, for use as a selected column with sq.Alias.
+// col must be a geography.
+func Distance(col string, pt Point) sq.Sqlizer {
+	return sq.Expr("ST_Distance("+col+", "+pointExpr+"::geography)", pt.Lon, pt.Lat)
+}
+
+// NearestOrder orders rows by distance from pt using the <-> operator, for use with
+// OrderByClause; it can use a GiST index on col for nearest-neighbor queries with a LIMIT.
+func NearestOrder(col string, pt Point) sq.Sqlizer {
+	return sq.Expr(col+" <-> "+pointExpr+"::geography", pt.Lon, pt.Lat)
+}
@@ -0,0 +1,29 @@
+package geo
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoSqlizers(t *testing.T) {
+	pt := Point{Lon: -122.27, Lat: 37.80}
+	q := sq.Select("id").
+		Column(sq.Alias(Distance("s.geometry", pt), "distance")).
+		From("gtfs_stops s").
+		Where(DWithin("s.geometry", pt, 500)).
+		Where(IntersectsBBox("s.geometry::geometry", BBox{MinLon: -123, MinLat: 37, MaxLon: -122, MaxLat: 38})).
+		OrderByClause(NearestOrder("s.geometry", pt)).
+		Limit(10).
+		PlaceholderFormat(sq.Dollar)
+	qstr, qargs, err := q.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id, (ST_Distance(s.geometry, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography)) AS distance FROM gtfs_stops s WHERE ST_DWithin(s.geometry, ST_SetSRID(ST_MakePoint($3, $4), 4326)::geography, $5) AND s.geometry::geometry && ST_MakeEnvelope($6, $7, $8, $9, 4326) ORDER BY s.geometry <-> ST_SetSRID(ST_MakePoint($10, $11), 4326)::geography LIMIT 10", qstr)
+	assert.Equal(t, []interface{}{-122.27, 37.80, -122.27, 37.80, 500.0, -123.0, 37.0, -122.0, 38.0, -122.27, 37.80}, qargs)
+
+	qstr, qargs, err = Contains("a.geometry", pt).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "ST_Contains(a.geometry, ST_SetSRID(ST_MakePoint(?, ?), 4326))", qstr)
+	assert.Equal(t, 2, len(qargs))
+}
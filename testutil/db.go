@@ -39,3 +39,19 @@ func MustOpenTestDB(t testing.TB) *sqlx.DB {
 	}
 	return testdb
 }
+
+// TestTx runs cb in a transaction that is always rolled back,
+// so tests never leave changes in the shared test database.
+func TestTx(t testing.TB, db *sqlx.DB, cb func(*sqlx.Tx)) {
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			t.Errorf("could not rollback test transaction: %s", err.Error())
+		}
+	}()
+	cb(tx)
+}
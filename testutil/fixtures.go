@@ -0,0 +1,104 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/yaml.v3"
+)
+
+// FixtureRefPrefix marks a fixture value as a reference to the generated id of another named fixture.
+const FixtureRefPrefix = "$ref:"
+
+// Fixture is a row to insert into a table. Values may reference earlier fixtures by name,
+// e.g. {"feed_id": "$ref:caltrain"}. Fixtures are inserted in order.
+type Fixture struct {
+	Table  string                 `json:"table" yaml:"table"`
+	Name   string                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Values map[string]interface{} `json:"values" yaml:"values"`
+}
+
+// ReadFixtures reads a list of fixtures from a .json, .yaml, or .yml file.
+func ReadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []Fixture
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &fixtures)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixtures)
+	default:
+		err = fmt.Errorf("unknown fixture file type: %s", path)
+	}
+	return fixtures, err
+}
+
+// LoadFixtures reads and inserts fixtures from a file, returning generated ids by fixture name.
+func LoadFixtures(ctx context.Context, db sqlx.Ext, path string) (map[string]int, error) {
+	fixtures, err := ReadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	return InsertFixtures(ctx, db, fixtures)
+}
+
+// InsertFixtures inserts fixtures in order, resolving references, and returns generated ids by fixture name.
+// Each table must have an id column.
+func InsertFixtures(ctx context.Context, db sqlx.Ext, fixtures []Fixture) (map[string]int, error) {
+	ids := map[string]int{}
+	for i, f := range fixtures {
+		var cols []string
+		for col := range f.Values {
+			cols = append(cols, col)
+		}
+		sort.Strings(cols)
+		var quotedCols []string
+		var vals []interface{}
+		for _, col := range cols {
+			val := f.Values[col]
+			if ref, ok := val.(string); ok && strings.HasPrefix(ref, FixtureRefPrefix) {
+				id, ok := ids[strings.TrimPrefix(ref, FixtureRefPrefix)]
+				if !ok {
+					return nil, fmt.Errorf("fixture %d (%s): unknown reference '%s'", i, f.Table, ref)
+				}
+				val = id
+			}
+			quotedCols = append(quotedCols, dbutil.QuoteIdentifier(col))
+			vals = append(vals, val)
+		}
+		insert := fmt.Sprintf("INSERT INTO %s DEFAULT VALUES RETURNING id", dbutil.QuoteIdentifier(f.Table))
+		var insertArgs []interface{}
+		if len(cols) > 0 {
+			var err error
+			insert, insertArgs, err = sq.Insert(dbutil.QuoteIdentifier(f.Table)).
+				Columns(quotedCols...).
+				Values(vals...).
+				Suffix("RETURNING id").
+				ToSql()
+			if err != nil {
+				return nil, err
+			}
+		}
+		// Run the insert through dbutil.Get, for cancellation, logging, and middleware
+		q := sq.Select("id").Prefix(fmt.Sprintf("WITH ins AS (%s)", insert), insertArgs...).From("ins")
+		var id int
+		if err := dbutil.Get(ctx, db, q, &id); err != nil {
+			return nil, fmt.Errorf("fixture %d (%s): %w", i, f.Table, err)
+		}
+		if f.Name != "" {
+			ids[f.Name] = id
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,95 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var (
+	updateGoldenSet  bool
+	updateGoldenFlag bool
+	updateGoldenLock sync.Mutex
+)
+
+// SetUpdateGolden sets whether golden files and query recordings are written instead of compared,
+// overriding the UPDATE_GOLDEN environment variable, e.g. from a test package's own -update flag.
+func SetUpdateGolden(update bool) {
+	updateGoldenLock.Lock()
+	defer updateGoldenLock.Unlock()
+	updateGoldenSet, updateGoldenFlag = true, update
+}
+
+// updateGolden returns the SetUpdateGolden value, or true if UPDATE_GOLDEN is set to a true value.
+func updateGolden() bool {
+	updateGoldenLock.Lock()
+	defer updateGoldenLock.Unlock()
+	if updateGoldenSet {
+		return updateGoldenFlag
+	}
+	update, _ := strconv.ParseBool(os.Getenv("UPDATE_GOLDEN"))
+	return update
+}
+
+var goldenClausePattern = regexp.MustCompile(`\s+(FROM|(?:(?:LEFT|RIGHT|FULL|INNER|CROSS)\s+)?JOIN|WHERE|GROUP BY|HAVING|ORDER BY|LIMIT|OFFSET|UNION|VALUES|SET|ON CONFLICT|RETURNING)\s`)
+
+// RenderSQL renders a query to normalized SQL, with Dollar placeholders and one clause per line,
+// followed by its arguments as JSON.
+func RenderSQL(q sq.Sqlizer) (string, error) {
+	qstr, qargs, err := q.ToSql()
+	if err != nil {
+		return "", err
+	}
+	if qstr, err = sq.Dollar.ReplacePlaceholders(qstr); err != nil {
+		return "", err
+	}
+	qstr = strings.Join(strings.Fields(qstr), " ")
+	qstr = goldenClausePattern.ReplaceAllString(qstr, "\n$1 ")
+	var sb strings.Builder
+	sb.WriteString(qstr)
+	sb.WriteString("\n")
+	for i, arg := range qargs {
+		b, err := json.Marshal(arg)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("-- $%d = %s\n", i+1, b))
+	}
+	return sb.String(), nil
+}
+
+// AssertGoldenSQL compares the rendered query with the golden file at path.
+// Run tests with UPDATE_GOLDEN=1, or call SetUpdateGolden, to write the golden file instead.
+func AssertGoldenSQL(t testing.TB, q sq.Sqlizer, path string) {
+	t.Helper()
+	got, err := RenderSQL(q)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expect, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read golden file '%s' (run with UPDATE_GOLDEN=1 to create): %s", path, err.Error())
+		return
+	}
+	if string(expect) != got {
+		t.Errorf("query does not match golden file '%s' (run with UPDATE_GOLDEN=1 to accept)\nexpected:\n%s\ngot:\n%s", path, expect, got)
+	}
+}
@@ -0,0 +1,33 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertGoldenSQL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stops.sql")
+	q := sq.Select("stop_name").From("gtfs_stops").Where("stop_id = ?", "a")
+
+	t.Setenv("UPDATE_GOLDEN", "1")
+	AssertGoldenSQL(t, q, path)
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT stop_name\nFROM gtfs_stops\nWHERE stop_id = $1\n-- $1 = \"a\"\n", string(b))
+
+	t.Setenv("UPDATE_GOLDEN", "")
+	assert.False(t, updateGolden())
+	AssertGoldenSQL(t, q, path)
+
+	// SetUpdateGolden overrides the environment
+	defer func() { updateGoldenSet = false }()
+	SetUpdateGolden(true)
+	assert.True(t, updateGolden())
+	t.Setenv("UPDATE_GOLDEN", "1")
+	SetUpdateGolden(false)
+	assert.False(t, updateGolden())
+}
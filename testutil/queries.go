@@ -0,0 +1,149 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RecordedQuery is a query and its result, as stored in a query recording file.
+type RecordedQuery struct {
+	Query        string          `json:"query"`
+	Args         json.RawMessage `json:"args"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	RowsAffected int64           `json:"rows_affected,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	// PgError is set for errors from Postgres, so SQLSTATE codes are replayed.
+	PgError *pgconn.PgError `json:"pg_error,omitempty"`
+}
+
+// QueryRecording returns middleware that records queries and their results to path when tests
+// are run with UPDATE_GOLDEN=1 or after SetUpdateGolden(true), and otherwise replays the recorded
+// results without running queries, so tests of query code can run without a database.
+// In replay mode, a nil database may be passed to dbutil.Select, Get, and Exec.
+// Queries are matched on their SQL and arguments; repeated queries replay in recorded order.
+// Postgres errors are replayed as *pgconn.PgError with their SQLSTATE code.
+// Results are stored as JSON, so destination types must round trip through encoding/json.
+// Install the middleware with dbutil.SetMiddleware.
+func QueryRecording(t testing.TB, path string) dbutil.Middleware {
+	if updateGolden() {
+		return recordQueries(t, path)
+	}
+	return replayQueries(t, path)
+}
+
+func recordQueries(t testing.TB, path string) dbutil.Middleware {
+	var recorded []RecordedQuery
+	var lock sync.Mutex
+	t.Cleanup(func() {
+		b, err := json.MarshalIndent(recorded, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return func(next dbutil.QueryHandler) dbutil.QueryHandler {
+		return func(ctx context.Context, qi *dbutil.QueryInfo) error {
+			err := next(ctx, qi)
+			rq := RecordedQuery{Query: normalizeQuery(qi.Query)}
+			var merr error
+			if rq.Args, merr = json.Marshal(qi.Args); merr != nil {
+				t.Fatal(merr)
+			}
+			if err != nil {
+				rq.Error = err.Error()
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) {
+					rq.PgError = pgErr
+				}
+			} else if qi.Dest != nil {
+				if rq.Result, merr = json.Marshal(qi.Dest); merr != nil {
+					t.Fatal(merr)
+				}
+			} else if qi.Result != nil {
+				rq.RowsAffected, _ = qi.Result.RowsAffected()
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			recorded = append(recorded, rq)
+			return err
+		}
+	}
+}
+
+func replayQueries(t testing.TB, path string) dbutil.Middleware {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read query recording, run with UPDATE_GOLDEN=1 to record: %s", err.Error())
+	}
+	var recorded []RecordedQuery
+	if err := json.Unmarshal(b, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	queue := map[string][]RecordedQuery{}
+	for _, rq := range recorded {
+		// Args are indented in the recording file, and compact when marshaled for matching
+		var args bytes.Buffer
+		if err := json.Compact(&args, rq.Args); err != nil {
+			t.Fatal(err)
+		}
+		key := rq.Query + "\n" + args.String()
+		queue[key] = append(queue[key], rq)
+	}
+	var lock sync.Mutex
+	return func(next dbutil.QueryHandler) dbutil.QueryHandler {
+		return func(ctx context.Context, qi *dbutil.QueryInfo) error {
+			args, err := json.Marshal(qi.Args)
+			if err != nil {
+				return err
+			}
+			key := normalizeQuery(qi.Query) + "\n" + string(args)
+			lock.Lock()
+			rqs := queue[key]
+			if len(rqs) == 0 {
+				lock.Unlock()
+				return fmt.Errorf("no recorded result for query: %s %s", normalizeQuery(qi.Query), args)
+			}
+			// The last recorded result is reused once earlier ones are consumed
+			rq := rqs[0]
+			if len(rqs) > 1 {
+				queue[key] = rqs[1:]
+			}
+			lock.Unlock()
+			if rq.PgError != nil {
+				pgErr := *rq.PgError
+				return &pgErr
+			} else if rq.Error == sql.ErrNoRows.Error() {
+				return sql.ErrNoRows
+			} else if rq.Error != "" {
+				return errors.New(rq.Error)
+			}
+			if qi.Dest != nil && rq.Result != nil {
+				return json.Unmarshal(rq.Result, qi.Dest)
+			}
+			qi.Result = driver.RowsAffected(rq.RowsAffected)
+			return nil
+		}
+	}
+}
+
+func normalizeQuery(qstr string) string {
+	return strings.Join(strings.Fields(qstr), " ")
+}
@@ -0,0 +1,63 @@
+package testutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/interline-io/transitland-dbutil/dbutil"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.json")
+	ctx := context.Background()
+	insert := sq.Insert("gtfs_stops").Columns("stop_id").Values("a")
+	selectNames := sq.Select("stop_name").From("gtfs_stops").Where("stop_id = ?", "a")
+
+	// Record against a fake database
+	t.Run("record", func(t *testing.T) {
+		db := func(next dbutil.QueryHandler) dbutil.QueryHandler {
+			return func(ctx context.Context, qi *dbutil.QueryInfo) error {
+				if dest, ok := qi.Dest.(*[]string); ok {
+					*dest = []string{"Main St"}
+					return nil
+				}
+				if qi.Args[0] == "b" {
+					return &pgconn.PgError{Severity: "ERROR", Code: "23505", Message: "duplicate key value", ConstraintName: "gtfs_stops_stop_id_key"}
+				}
+				qi.Result = driver.RowsAffected(1)
+				return nil
+			}
+		}
+		dbutil.SetMiddleware(recordQueries(t, path), db)
+		defer dbutil.SetMiddleware()
+		check(t, ctx, insert, selectNames)
+	})
+
+	// Replay without a database
+	dbutil.SetMiddleware(replayQueries(t, path))
+	defer dbutil.SetMiddleware()
+	check(t, ctx, insert, selectNames)
+}
+
+func check(t *testing.T, ctx context.Context, insert sq.InsertBuilder, selectNames sq.SelectBuilder) {
+	res, err := dbutil.Exec(ctx, nil, insert)
+	if assert.NoError(t, err) {
+		n, _ := res.RowsAffected()
+		assert.Equal(t, int64(1), n)
+	}
+	var names []string
+	assert.NoError(t, dbutil.Select(ctx, nil, selectNames, &names))
+	assert.Equal(t, []string{"Main St"}, names)
+	_, err = dbutil.Exec(ctx, nil, sq.Insert("gtfs_stops").Columns("stop_id").Values("b"))
+	var pgErr *pgconn.PgError
+	if assert.True(t, errors.As(err, &pgErr)) {
+		assert.Equal(t, "23505", pgErr.Code)
+		assert.Equal(t, "gtfs_stops_stop_id_key", pgErr.ConstraintName)
+	}
+}